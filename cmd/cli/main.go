@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 	"syscall"
+	"text/tabwriter"
+	"text/template"
 
 	"github.com/deejross/direktor/pkg/formatter"
 	"github.com/deejross/direktor/pkg/ldapcli"
@@ -28,7 +34,10 @@ var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Login creates a state file with login information for convenience.",
 	Run: func(cmd *cobra.Command, args []string) {
-		cli := getClient(cmd)
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		cli := getClient(ctx, cmd)
 		cli.Close()
 
 		if err := viper.WriteConfig(); err != nil {
@@ -41,21 +50,30 @@ var searchCmd = &cobra.Command{
 	Use:   "search",
 	Short: "Search directory",
 	Run: func(cmd *cobra.Command, args []string) {
-		cli := getClient(cmd)
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		cli := getClient(ctx, cmd)
 		defer cli.Close()
 
-		resp, err := search(cmd, cli)
+		req, err := searchRequest(cmd, cli)
 		if err != nil {
 			fatal(err.Error())
 		}
 
-		output, _ := cmd.Flags().GetString("output")
-		b, err := formatter.FormatLDAPSearchResult(output, resp)
+		output := outputFormat(cmd)
+		w, err := formatter.NewLDAPPageWriter(os.Stdout, output, streamOptions(cmd, req.Attributes))
 		if err != nil {
 			fatal(err.Error())
 		}
 
-		fmt.Println(string(b))
+		if err := cli.SearchPagesContext(ctx, req, w.WritePage); err != nil {
+			fatal(err.Error())
+		}
+
+		if err := w.Close(); err != nil {
+			fatal(err.Error())
+		}
 	},
 }
 
@@ -63,10 +81,13 @@ var membersCmd = &cobra.Command{
 	Use:   "members",
 	Short: "List members of a group",
 	Run: func(cmd *cobra.Command, args []string) {
-		cli := getClient(cmd)
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		cli := getClient(ctx, cmd)
 		defer cli.Close()
 
-		resp, err := search(cmd, cli)
+		resp, err := search(ctx, cmd, cli)
 		if err != nil {
 			fatal(err.Error())
 		}
@@ -80,18 +101,20 @@ var membersCmd = &cobra.Command{
 			attributes = []string{ldapcli.AttributeCommonName, ldapcli.AttributeObjectClass}
 		}
 
-		resp, err = cli.GroupMembersExtended(resp.Entries[0].DN, attributes...)
+		resp, err = cli.GroupMembersExtendedContext(ctx, resp.Entries[0].DN, attributes...)
 		if err != nil {
 			fatal(err.Error())
 		}
 
-		output, _ := cmd.Flags().GetString("output")
-		b, err := formatter.FormatLDAPSearchResult(output, resp)
+		output := outputFormat(cmd)
+		w, err := formatter.NewLDAPStreamFormatter(os.Stdout, output, streamOptions(cmd, attributes))
 		if err != nil {
 			fatal(err.Error())
 		}
 
-		fmt.Println(string(b))
+		if err := formatter.WriteLDAPSearchResult(w, resp); err != nil {
+			fatal(err.Error())
+		}
 	},
 }
 
@@ -100,7 +123,10 @@ var listCmd = &cobra.Command{
 	Short: "List members of an Organizational Unit",
 	Args:  cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		cli := getClient(cmd)
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		cli := getClient(ctx, cmd)
 		defer cli.Close()
 
 		attributes, _ := cmd.Flags().GetStringSlice("attributes")
@@ -113,18 +139,52 @@ var listCmd = &cobra.Command{
 			dn = args[0]
 		}
 
-		resp, err := cli.OrganizationalUnitMembers(dn, attributes...)
+		output := outputFormat(cmd)
+		w, err := formatter.NewLDAPPageWriter(os.Stdout, output, streamOptions(cmd, attributes))
 		if err != nil {
 			fatal(err.Error())
 		}
 
-		output, _ := cmd.Flags().GetString("output")
-		b, err := formatter.FormatLDAPSearchResult(output, resp)
+		if err := cli.OrganizationalUnitMembersPages(ctx, dn, attributes, w.WritePage); err != nil {
+			fatal(err.Error())
+		}
+
+		if err := w.Close(); err != nil {
+			fatal(err.Error())
+		}
+	},
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show information about the LDAP server from its RootDSE",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := commandContext(cmd)
+		defer cancel()
+
+		cli := getClient(ctx, cmd)
+		defer cli.Close()
+
+		root, err := cli.RootDSEContext(ctx)
 		if err != nil {
 			fatal(err.Error())
 		}
 
-		fmt.Println(string(b))
+		// other formats output the RootDSE entry as returned by the server
+		output := outputFormat(cmd)
+		if output == "text" {
+			writeRootDSE(os.Stdout, cli, root)
+			return
+		}
+
+		w, err := formatter.NewLDAPStreamFormatter(os.Stdout, output, streamOptions(cmd, nil))
+		if err != nil {
+			fatal(err.Error())
+		}
+
+		if err := formatter.WriteLDAPSearchResult(w, &ldap.SearchResult{Entries: []*ldap.Entry{root.Entry}}); err != nil {
+			fatal(err.Error())
+		}
 	},
 }
 
@@ -140,7 +200,146 @@ func fatal(s string, args ...interface{}) {
 	os.Exit(1)
 }
 
-func getClient(cmd *cobra.Command) *ldapcli.Client {
+// commandContext returns the context for a command, which is cancelled after the duration given by `--timeout`, if any.
+func commandContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	if timeout > 0 {
+		return context.WithTimeout(context.Background(), timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// streamOptions returns the formatter options given by the output flags of the given command.
+func streamOptions(cmd *cobra.Command, attributes []string) *formatter.LDAPStreamOptions {
+	multiValue, _ := cmd.Flags().GetString("multi-value")
+	mode, err := formatter.ParseMultiValueMode(multiValue)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	tmpl, err := outputTemplate(cmd)
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	separator, _ := cmd.Flags().GetString("separator")
+	noHeaders, _ := cmd.Flags().GetBool("no-headers")
+	wrap, _ := cmd.Flags().GetBool("wrap")
+	raw, _ := cmd.Flags().GetBool("raw")
+	interpret, _ := cmd.Flags().GetBool("interpret")
+
+	// additional attributes to redact can be configured by administrators, but not overridden
+	redaction, err := formatter.NewRedactionPolicy(viper.GetStringSlice("redact-mask"), viper.GetStringSlice("redact-drop"))
+	if err != nil {
+		fatal("invalid redaction config: %s", err)
+	}
+
+	return &formatter.LDAPStreamOptions{
+		Attributes: attributes,
+		MultiValue: mode,
+		Separator:  separator,
+		NoHeaders:  noHeaders,
+		Width:      terminalWidth(),
+		Wrap:       wrap,
+		Template:   tmpl,
+		Raw:        raw,
+		Interpret:  interpret,
+		Redaction:  redaction,
+	}
+}
+
+// outputFormat returns the output format given by `--output`, which defaults to `template` if a template is given.
+func outputFormat(cmd *cobra.Command) string {
+	output, _ := cmd.Flags().GetString("output")
+	if !cmd.Flags().Changed("output") && (cmd.Flags().Changed("template") || cmd.Flags().Changed("template-file")) {
+		return "template"
+	}
+	return output
+}
+
+// outputTemplate returns the template given by `--template` or `--template-file`, if any.
+func outputTemplate(cmd *cobra.Command) (*template.Template, error) {
+	text, _ := cmd.Flags().GetString("template")
+	file, _ := cmd.Flags().GetString("template-file")
+
+	if len(text) > 0 && len(file) > 0 {
+		return nil, fmt.Errorf("only one of --template or --template-file can be given")
+	}
+
+	if len(file) > 0 {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+
+	if len(text) == 0 {
+		return nil, nil
+	}
+
+	return formatter.ParseLDAPTemplate(text)
+}
+
+// writeRootDSE writes a summary of the RootDSE of the server the client is connected to.
+func writeRootDSE(w io.Writer, cli *ldapcli.Client, root *ldapcli.RootDSE) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	row := func(label string, values ...string) {
+		for i, v := range values {
+			if len(v) == 0 {
+				continue
+			}
+			if i > 0 {
+				label = ""
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", label, v)
+		}
+	}
+
+	versions := []string{}
+	for _, v := range root.SupportedLDAPVersions {
+		versions = append(versions, strconv.Itoa(v))
+	}
+
+	level := func(l ldapcli.FunctionalLevel) string {
+		if l == ldapcli.FunctionalLevelUnknown {
+			return ""
+		}
+		return fmt.Sprintf("%s (%d)", l, int(l))
+	}
+
+	row("Server:", cli.Address())
+	row("Base DN:", cli.Config().BaseDN)
+	row("DNS host name:", root.DNSHostName)
+	row("Vendor:", strings.TrimSpace(root.VendorName+" "+root.VendorVersion))
+	row("Default naming context:", root.DefaultNamingContext)
+	row("Root domain naming context:", root.RootDomainNamingContext)
+	row("Naming contexts:", root.NamingContexts...)
+	row("LDAP versions:", strings.Join(versions, ", "))
+	row("SASL mechanisms:", strings.Join(root.SupportedSASLMechanisms, ", "))
+	row("Forest functional level:", level(root.ForestFunctionality))
+	row("Domain functional level:", level(root.DomainFunctionality))
+	row("DC functional level:", level(root.DomainControllerFunctionality))
+	row("Supported controls:", root.SupportedControls...)
+	tw.Flush()
+}
+
+// terminalWidth returns the width of the terminal, or zero if stdout is not a terminal.
+func terminalWidth() int {
+	fd := int(os.Stdout.Fd())
+	if !terminal.IsTerminal(fd) {
+		return 0
+	}
+
+	width, _, err := terminal.GetSize(fd)
+	if err != nil {
+		return 0
+	}
+
+	return width
+}
+
+func getClient(ctx context.Context, cmd *cobra.Command) *ldapcli.Client {
 	if err := viper.ReadInConfig(); err != nil {
 		if !strings.Contains(err.Error(), "Not Found") {
 			fatal(err.Error())
@@ -149,26 +348,74 @@ func getClient(cmd *cobra.Command) *ldapcli.Client {
 		viper.SetConfigFile(defaultConfigFile)
 	}
 
-	address := viper.GetString("address")
-	if len(address) == 0 {
-		fatal("no address configured")
-	} else if !strings.HasPrefix(address, "ldap") {
-		fatal("unkown address format: %s", address)
+	// additional addresses are failover servers for the same domain
+	addresses := []string{}
+	for _, addr := range strings.Split(viper.GetString("address"), ",") {
+		if addr = strings.TrimSpace(addr); len(addr) > 0 {
+			addresses = append(addresses, addr)
+		}
 	}
 
+	// without an address, the servers are discovered from the SRV records of the domain
+	domain := viper.GetString("domain")
+	if len(addresses) == 0 && len(domain) == 0 {
+		fatal("no address or domain configured")
+	}
+	for _, addr := range addresses {
+		if !strings.HasPrefix(addr, "ldap") {
+			fatal("unkown address format: %s", addr)
+		}
+	}
+
+	// if not given, the base DN is read from the server when connecting
 	basedn := viper.GetString("basedn")
-	if len(basedn) == 0 {
-		basedn = ldapcli.ParseBaseDNFromDomain(address)
-		viper.Set("basedn", basedn)
+
+	discovery, err := ldapcli.ParseDiscoveryService(viper.GetString("discover"))
+	if err != nil {
+		fatal(err.Error())
 	}
 
-	conf := ldapcli.NewConfig(address, basedn)
+	conf := ldapcli.NewConfig("", basedn)
+	if len(addresses) > 0 {
+		conf.Address = addresses[0]
+		conf.Addresses = addresses[1:]
+	}
+	conf.Domain = domain
+	conf.DiscoveryService = discovery
+	conf.RandomizeAddresses = viper.GetBool("randomize-addresses")
 	conf.BindUsername = viper.GetString("username")
 	conf.BindPassword = viper.GetString("password")
 	conf.StartTLS = viper.GetBool("start-tls")
 	conf.SkipVerify = viper.GetBool("insecure")
 
-	if len(conf.BindUsername) > 0 && len(conf.BindPassword) == 0 {
+	authMode, err := ldapcli.ParseAuthMode(viper.GetString("auth"))
+	if err != nil {
+		fatal(err.Error())
+	}
+	conf.AuthMode = authMode
+	conf.KerberosRealm = viper.GetString("realm")
+	conf.KeytabPath = viper.GetString("keytab")
+	conf.CCachePath = viper.GetString("ccache")
+	conf.Krb5ConfPath = viper.GetString("krb5-conf")
+	conf.ServicePrincipalName = viper.GetString("spn")
+
+	conf.CAFile = viper.GetString("ca-file")
+	conf.CADir = viper.GetString("ca-dir")
+	conf.ClientCertFile = viper.GetString("cert")
+	conf.ClientKeyFile = viper.GetString("key")
+	conf.TLSServerName = viper.GetString("tls-server-name")
+	conf.MinTLSVersion, err = ldapcli.ParseTLSVersion(viper.GetString("tls-min-version"))
+	if err != nil {
+		fatal(err.Error())
+	}
+	conf.CipherPolicy, err = ldapcli.ParseCipherPolicy(viper.GetString("tls-ciphers"))
+	if err != nil {
+		fatal(err.Error())
+	}
+
+	// GSSAPI uses a keytab or the credential cache from kinit, and EXTERNAL uses the client certificate,
+	// instead of prompting for a password
+	if conf.AuthMode == ldapcli.AuthSimple && len(conf.BindUsername) > 0 && len(conf.BindPassword) == 0 {
 		fmt.Print("Enter password: ")
 		bpw, _ := terminal.ReadPassword(int(syscall.Stdin))
 		conf.BindPassword = strings.TrimSpace(string(bpw))
@@ -176,15 +423,29 @@ func getClient(cmd *cobra.Command) *ldapcli.Client {
 		fmt.Print("\n")
 	}
 
-	cli, err := ldapcli.Dial(conf)
+	cli, err := ldapcli.DialContext(ctx, conf)
 	if err != nil {
 		fatal(err.Error())
 	}
 
+	if len(basedn) == 0 {
+		viper.Set("basedn", cli.Config().BaseDN)
+	}
+
 	return cli
 }
 
-func search(cmd *cobra.Command, cli *ldapcli.Client) (*ldap.SearchResult, error) {
+func search(ctx context.Context, cmd *cobra.Command, cli *ldapcli.Client) (*ldap.SearchResult, error) {
+	req, err := searchRequest(cmd, cli)
+	if err != nil {
+		return nil, err
+	}
+
+	return cli.SearchContext(ctx, req)
+}
+
+// searchRequest builds a search request from the search flags of the given command.
+func searchRequest(cmd *cobra.Command, cli *ldapcli.Client) (*ldap.SearchRequest, error) {
 	attributes, _ := cmd.Flags().GetStringSlice("attributes")
 	if len(attributes) == 0 {
 		attributes = []string{ldapcli.AttributeCommonName, ldapcli.AttributeObjectClass}
@@ -195,70 +456,88 @@ func search(cmd *cobra.Command, cli *ldapcli.Client) (*ldap.SearchResult, error)
 	byAttr, _ := cmd.Flags().GetString("by-attr")
 	filter, _ := cmd.Flags().GetString("filter")
 
-	if len(filter) == 0 {
-		if len(dn) > 0 {
-			if !ldapcli.IsDNSanitized(dn) {
-				return nil, fmt.Errorf("dn contains invalid characters: %s", dn)
-			}
-
-			filter = fmt.Sprintf("(distinguishedName=%s)", dn)
-		} else if len(cn) > 0 {
-			if !ldapcli.IsNameSanitized(cn) {
-				return nil, fmt.Errorf("cn contains invalid characters: %s", cn)
-			}
-
-			filter = fmt.Sprintf("(cn=%s)", cn)
-		} else if len(byAttr) > 0 {
-			if !strings.Contains(byAttr, "=") {
-				return nil, fmt.Errorf("by-attr missing value to search for: %s", byAttr)
-			}
-
-			parts := strings.SplitN(byAttr, "=", 2)
-			if !ldapcli.IsNameSanitized(parts[0]) {
-				return nil, fmt.Errorf("by-attr name contains invalid characters: %s", parts[0])
-			}
-			if !ldapcli.IsDNSanitized(parts[1]) {
-				return nil, fmt.Errorf("by-attr value contains invalid characters: %s", parts[1])
-			}
-
-			filter = fmt.Sprintf("(%s=%s)", parts[0], parts[1])
-		}
+	filter, err := ldapcli.BuildFilter(dn, cn, byAttr, filter)
+	if err != nil {
+		return nil, err
 	}
 
 	if len(filter) == 0 {
 		return nil, fmt.Errorf("search requires one of: --dn, --cn, --by-attr, --filter")
 	}
 
-	req := cli.NewSearchRequest(filter, attributes)
-	return cli.Search(req)
+	return cli.NewSearchRequest(filter, attributes), nil
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringP("address", "a", "", "Address to LDAP server in format: ldap://server.local:389 or ldaps://server.local:636")
-	rootCmd.PersistentFlags().StringP("basedn", "b", "", "BaseDN for searching, defaults to auto discovery")
+	rootCmd.PersistentFlags().StringP("address", "a", "", "Address to LDAP server in format: ldap://server.local:389 or ldaps://server.local:636, separate multiple servers for the same domain with commas to fail over between them")
+	rootCmd.PersistentFlags().String("domain", "", "Domain to discover LDAP servers for using DNS SRV records, e.g. example.com, used instead of or in addition to --address")
+	rootCmd.PersistentFlags().String("discover", "ldap", "SRV records used to discover servers for --domain: ldap (_ldap._tcp), dc (_ldap._tcp.dc._msdcs), gc (_gc._tcp)")
+	rootCmd.PersistentFlags().Bool("randomize-addresses", false, "Try the given addresses in random order, discovered addresses keep their SRV order")
+	rootCmd.PersistentFlags().StringP("basedn", "b", "", "BaseDN for searching, defaults to the default naming context of the server")
 	rootCmd.PersistentFlags().StringP("username", "u", "", "Username to use for authentication")
 	rootCmd.PersistentFlags().StringP("password", "p", "", "Password to use for authentication, if not set you will be prompted")
 	rootCmd.PersistentFlags().Bool("start-tls", false, "Start TLS")
+	rootCmd.PersistentFlags().String("auth", "simple", "Authentication mode: simple, gssapi to use Kerberos with --keytab, --password, or the credential cache from kinit, or external to use the --cert client certificate")
+	rootCmd.PersistentFlags().String("keytab", "", "Keytab to authenticate as --username with when using gssapi")
+	rootCmd.PersistentFlags().String("ccache", "", "Kerberos credential cache to use with gssapi when there is no keytab or password, defaults to $KRB5CCNAME or /tmp/krb5cc_<uid>")
+	rootCmd.PersistentFlags().String("krb5-conf", "", "Kerberos configuration file, defaults to $KRB5_CONFIG or /etc/krb5.conf")
+	rootCmd.PersistentFlags().String("realm", "", "Kerberos realm of --username, defaults to the realm in the username or the default realm of the Kerberos configuration")
+	rootCmd.PersistentFlags().String("spn", "", "Service principal name of the LDAP servers, defaults to ldap/<server host name>")
 	rootCmd.PersistentFlags().Bool("insecure", false, "Skip TLS validation errors")
+	rootCmd.PersistentFlags().String("ca-file", "", "PEM file of CA certificates to trust in addition to the system roots")
+	rootCmd.PersistentFlags().String("ca-dir", "", "Directory of PEM files with CA certificates to trust in addition to the system roots")
+	rootCmd.PersistentFlags().String("cert", "", "PEM client certificate to present to the server, required with --auth=external over TLS")
+	rootCmd.PersistentFlags().String("key", "", "PEM private key of --cert")
+	rootCmd.PersistentFlags().String("tls-min-version", "", "Minimum TLS version: 1.0, 1.1, 1.2, or 1.3, defaults to 1.2")
+	rootCmd.PersistentFlags().String("tls-server-name", "", "Name to verify server certificates against, defaults to the host of each address")
+	rootCmd.PersistentFlags().String("tls-ciphers", "default", "Cipher policy for TLS 1.2 and earlier: default, strict, or legacy to allow insecure ciphers for old servers")
+	rootCmd.PersistentFlags().Duration("timeout", 0, "Maximum time to wait for the command to complete, e.g. 30s, defaults to no limit")
 
 	searchCmd.Flags().StringSlice("attributes", []string{}, "Comma-separated list of attributes to return")
-	searchCmd.Flags().StringP("output", "o", "text", "Output format: json, json-pretty, ldif, text, yaml")
+	searchCmd.Flags().StringP("output", "o", "text", "Output format: csv, json, json-pretty, jsonl, ldif, table, template, text, tsv, yaml, yaml-stream")
+	searchCmd.Flags().String("multi-value", "join", "How csv, tsv and table output attributes with multiple values: join, first, explode")
+	searchCmd.Flags().String("separator", "", "Separator for joining multiple values in csv, tsv and table output, defaults to a semicolon")
+	searchCmd.Flags().Bool("no-headers", false, "Omit the header row from csv, tsv and table output")
+	searchCmd.Flags().Bool("wrap", false, "Wrap values that don't fit the terminal in table output, instead of truncating them")
+	searchCmd.Flags().String("template", "", "Go template executed for each entry with the template output format, e.g. '{{.DN}} {{index .Attrs \"mail\" | first}}'")
+	searchCmd.Flags().String("template-file", "", "File containing the Go template for the template output format")
+	searchCmd.Flags().Bool("raw", false, "Output raw attribute values instead of decoding SIDs, GUIDs, certificates and other binary values")
+	searchCmd.Flags().Bool("interpret", false, "Show Active Directory times as RFC3339 and userAccountControl as flag names")
 	searchCmd.Flags().String("dn", "", "Find by distingushedName")
 	searchCmd.Flags().String("cn", "", "Find by common name (CN)")
 	searchCmd.Flags().String("by-attr", "", "Find by attribute, format <attribute>=<value>")
 	searchCmd.Flags().String("filter", "", "Find using LDAP filter")
 
 	membersCmd.Flags().StringSlice("attributes", []string{}, "Comma-separated list of attributes to return")
-	membersCmd.Flags().StringP("output", "o", "text", "Output format: json, json-pretty, ldif, text, yaml")
+	membersCmd.Flags().StringP("output", "o", "text", "Output format: csv, json, json-pretty, jsonl, ldif, table, template, text, tsv, yaml, yaml-stream")
+	membersCmd.Flags().String("multi-value", "join", "How csv, tsv and table output attributes with multiple values: join, first, explode")
+	membersCmd.Flags().String("separator", "", "Separator for joining multiple values in csv, tsv and table output, defaults to a semicolon")
+	membersCmd.Flags().Bool("no-headers", false, "Omit the header row from csv, tsv and table output")
+	membersCmd.Flags().Bool("wrap", false, "Wrap values that don't fit the terminal in table output, instead of truncating them")
+	membersCmd.Flags().String("template", "", "Go template executed for each entry with the template output format, e.g. '{{.DN}} {{index .Attrs \"mail\" | first}}'")
+	membersCmd.Flags().String("template-file", "", "File containing the Go template for the template output format")
+	membersCmd.Flags().Bool("raw", false, "Output raw attribute values instead of decoding SIDs, GUIDs, certificates and other binary values")
+	membersCmd.Flags().Bool("interpret", false, "Show Active Directory times as RFC3339 and userAccountControl as flag names")
 	membersCmd.Flags().String("dn", "", "Find by distingushedName")
 	membersCmd.Flags().String("cn", "", "Find by common name (CN)")
 	membersCmd.Flags().String("by-attr", "", "Find by attribute, format <attribute>=<value>")
 	membersCmd.Flags().String("filter", "", "Find using LDAP filter")
 
 	listCmd.Flags().StringSlice("attributes", []string{}, "Comma-separated list of attributes to return")
-	listCmd.Flags().StringP("output", "o", "text", "Output format: json, json-pretty, ldif, text, yaml")
+	listCmd.Flags().StringP("output", "o", "text", "Output format: csv, json, json-pretty, jsonl, ldif, table, template, text, tsv, yaml, yaml-stream")
+	listCmd.Flags().String("multi-value", "join", "How csv, tsv and table output attributes with multiple values: join, first, explode")
+	listCmd.Flags().String("separator", "", "Separator for joining multiple values in csv, tsv and table output, defaults to a semicolon")
+	listCmd.Flags().Bool("no-headers", false, "Omit the header row from csv, tsv and table output")
+	listCmd.Flags().Bool("wrap", false, "Wrap values that don't fit the terminal in table output, instead of truncating them")
+	listCmd.Flags().String("template", "", "Go template executed for each entry with the template output format, e.g. '{{.DN}} {{index .Attrs \"mail\" | first}}'")
+	listCmd.Flags().String("template-file", "", "File containing the Go template for the template output format")
+	listCmd.Flags().Bool("raw", false, "Output raw attribute values instead of decoding SIDs, GUIDs, certificates and other binary values")
+	listCmd.Flags().Bool("interpret", false, "Show Active Directory times as RFC3339 and userAccountControl as flag names")
+
+	infoCmd.Flags().StringP("output", "o", "text", "Output format: a summary for text, otherwise the RootDSE entry as csv, json, json-pretty, jsonl, ldif, table, template, tsv, yaml, yaml-stream")
+	infoCmd.Flags().String("template", "", "Go template executed for the RootDSE entry with the template output format")
 
-	rootCmd.AddCommand(loginCmd, searchCmd, membersCmd, listCmd)
+	rootCmd.AddCommand(loginCmd, searchCmd, membersCmd, listCmd, infoCmd)
 
 	homeDir, _ := os.UserHomeDir()
 	defaultConfigDir = homeDir + "/.direktor"
@@ -270,9 +549,25 @@ func init() {
 	viper.AddConfigPath(".")
 	viper.AddConfigPath(defaultConfigDir)
 	viper.BindPFlag("address", rootCmd.PersistentFlags().Lookup("address"))
+	viper.BindPFlag("domain", rootCmd.PersistentFlags().Lookup("domain"))
+	viper.BindPFlag("discover", rootCmd.PersistentFlags().Lookup("discover"))
+	viper.BindPFlag("randomize-addresses", rootCmd.PersistentFlags().Lookup("randomize-addresses"))
 	viper.BindPFlag("basedn", rootCmd.PersistentFlags().Lookup("basedn"))
 	viper.BindPFlag("username", rootCmd.PersistentFlags().Lookup("username"))
 	viper.BindPFlag("password", rootCmd.PersistentFlags().Lookup("password"))
 	viper.BindPFlag("start-tls", rootCmd.PersistentFlags().Lookup("start-tls"))
+	viper.BindPFlag("auth", rootCmd.PersistentFlags().Lookup("auth"))
+	viper.BindPFlag("keytab", rootCmd.PersistentFlags().Lookup("keytab"))
+	viper.BindPFlag("ccache", rootCmd.PersistentFlags().Lookup("ccache"))
+	viper.BindPFlag("krb5-conf", rootCmd.PersistentFlags().Lookup("krb5-conf"))
+	viper.BindPFlag("realm", rootCmd.PersistentFlags().Lookup("realm"))
+	viper.BindPFlag("spn", rootCmd.PersistentFlags().Lookup("spn"))
 	viper.BindPFlag("insecure", rootCmd.PersistentFlags().Lookup("insecure"))
+	viper.BindPFlag("ca-file", rootCmd.PersistentFlags().Lookup("ca-file"))
+	viper.BindPFlag("ca-dir", rootCmd.PersistentFlags().Lookup("ca-dir"))
+	viper.BindPFlag("cert", rootCmd.PersistentFlags().Lookup("cert"))
+	viper.BindPFlag("key", rootCmd.PersistentFlags().Lookup("key"))
+	viper.BindPFlag("tls-min-version", rootCmd.PersistentFlags().Lookup("tls-min-version"))
+	viper.BindPFlag("tls-server-name", rootCmd.PersistentFlags().Lookup("tls-server-name"))
+	viper.BindPFlag("tls-ciphers", rootCmd.PersistentFlags().Lookup("tls-ciphers"))
 }
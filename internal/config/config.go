@@ -1,23 +1,314 @@
 package config
 
 import (
+	"crypto"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/deejross/direktor/pkg/authtoken"
+	"github.com/deejross/direktor/pkg/formatter"
+	"github.com/deejross/direktor/pkg/ldapcli"
 	"github.com/fsnotify/fsnotify"
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 )
 
+const (
+	// DefaultPoolMaxIdle is the maximum number of idle LDAP connections kept open if PoolMaxIdle is not set.
+	DefaultPoolMaxIdle = 100
+
+	// DefaultPoolIdleTimeout is how long an idle LDAP connection is kept open if PoolIdleTimeout is not set.
+	DefaultPoolIdleTimeout = 5 * time.Minute
+)
+
 var (
-	mu   = sync.RWMutex{}
-	conf *Config
+	mu          = sync.RWMutex{}
+	conf        *Config
+	privateKeys = map[string]crypto.Signer{}
 )
 
 // Config object from environment configuration.
 type Config struct {
-	ListenPort string // The port number for the server to listen on, defaults to 8000 or value of PORT environment variable
-	SecretKey  string // The secret key used for signing and encrypting authentication tokens
+	ListenPort      string        // The port number for the server to listen on, defaults to 8000 or value of PORT environment variable
+	SecretKey       string        // The secret key used for signing and encrypting authentication tokens
+	SigningKeys     []SigningKey  // Keys used for signing and encrypting authentication tokens, allows keys to be rotated
+	ActiveKeyID     string        // The ID of the signing key used for new tokens, defaults to the first signing key
+	TokenTTL        time.Duration // How long authentication tokens are valid for, defaults to 8h
+	PoolMaxIdle     int           // The maximum number of idle LDAP connections to keep open, defaults to 100
+	PoolIdleTimeout time.Duration // How long an idle LDAP connection is kept open before being closed, defaults to 5m
+	EnableMetrics   bool          // Serve LDAP connection pool metrics at /metrics, which is not authenticated, disabled by default
+	Domains         []Domain      // The LDAP domains users can authenticate against by name
+	AllowedTargets  []string      // Additional LDAP servers tokens may target, see AllowedTargets for the format
+	Roles           []Role        // Roles granted to users by bind username or LDAP group membership
+	Redaction       Redaction     // Attributes that are masked or dropped from responses
+	Krb5Conf        string        // Path to the Kerberos configuration used for gssapi authentication, defaults to $KRB5_CONFIG or /etc/krb5.conf
+	TLS             TLS           // TLS settings for connecting to LDAP servers, domains may override each setting
+}
+
+// Role grants permissions to users by their bind username or LDAP group membership.
+type Role struct {
+	Name   string   // The unique name of the role
+	Users  []string // Bind usernames with the role, compared case-insensitively
+	Groups []string // DNs of LDAP groups whose direct members have the role
+}
+
+// Redaction configures the attributes that are masked or dropped from responses. Well-known password and
+// secret attributes, such as unicodePwd and ms-Mcs-AdmPwd, are always masked. Attributes can be given by name
+// or by a pattern such as `msLAPS-*`. Search filters that test a masked or dropped attribute are rejected unless
+// the user has one of the override roles.
+type Redaction struct {
+	Mask          []string // Additional attributes whose values are replaced with [REDACTED]
+	Drop          []string // Attributes removed from responses entirely
+	OverrideRoles []string // Roles that may request unredacted values with the `unredacted=true` query parameter, or filter on redacted attributes
+}
+
+// Role returns the role with the given name.
+func (c *Config) Role(name string) (*Role, bool) {
+	for i := range c.Roles {
+		if c.Roles[i].Name == name {
+			return &c.Roles[i], true
+		}
+	}
+	return nil, false
+}
+
+// RedactionPolicy returns the policy for redacting attributes from responses.
+func (c *Config) RedactionPolicy() (*formatter.RedactionPolicy, error) {
+	return formatter.NewRedactionPolicy(c.Redaction.Mask, c.Redaction.Drop)
+}
+
+// validateRoles ensures each role has a unique name, and that override roles exist.
+func (c *Config) validateRoles() error {
+	names := map[string]struct{}{}
+	for i, r := range c.Roles {
+		if len(r.Name) == 0 {
+			return fmt.Errorf("role: %d: name is required", i)
+		}
+		if _, ok := names[r.Name]; ok {
+			return fmt.Errorf("role: %s: duplicate role name", r.Name)
+		}
+		names[r.Name] = struct{}{}
+	}
+
+	for _, name := range c.Redaction.OverrideRoles {
+		if _, ok := names[name]; !ok {
+			return fmt.Errorf("redaction: override role not found: %s", name)
+		}
+	}
+	return nil
+}
+
+// Domain is a named LDAP directory with its connection settings.
+type Domain struct {
+	Name               string        // The unique name of the domain, shown to users
+	Addresses          []string      // The LDAP server addresses for the domain in order of preference, e.g. ldaps://dc1.example.com:636
+	RandomizeAddresses bool          // Try the configured addresses in random order to spread the load, discovered addresses keep their SRV order
+	FailoverCooldown   time.Duration // How long an unreachable address is skipped before being tried again, defaults to 1m
+	BaseDN             string        // The base DN for searches
+	StartTLS           bool          // Use StartTLS for ldap:// addresses
+	SkipVerify         bool          // Skip TLS certificate verification, not recommended
+	PageSize           int           // The page size used for searches, defaults to the LDAP client default
+	FollowReferrals    bool          // Follow referrals to other LDAP servers
+	AuthMode           string        // How users authenticate, one of: simple (default), gssapi
+	TLS                TLS           // TLS settings for the domain's addresses, unset fields use the server's TLS settings
+}
+
+// Domain returns the domain with the given name.
+func (c *Config) Domain(name string) (*Domain, bool) {
+	for i := range c.Domains {
+		if c.Domains[i].Name == name {
+			return &c.Domains[i], true
+		}
+	}
+	return nil, false
+}
+
+// validateAllowedTargets ensures each allowed target is a valid host name pattern, host:port, IP address, or CIDR.
+// Tokens may only target the addresses of configured domains, or a server matching one of these entries:
+//   - `*` allows any server, which is not recommended
+//   - `dc1.example.com` or `*.example.com` allows matching host names on any port
+//   - `dc1.example.com:636` allows a host name on a specific port
+//   - `10.0.0.5` allows an IP address on any port
+//   - `10.0.0.0/8` allows servers whose host resolves only to IP addresses within the network
+func (c *Config) validateAllowedTargets() error {
+	for _, target := range c.AllowedTargets {
+		if len(strings.TrimSpace(target)) == 0 {
+			return fmt.Errorf("target cannot be empty")
+		}
+		if strings.Contains(target, "/") {
+			if _, _, err := net.ParseCIDR(target); err != nil {
+				return fmt.Errorf("target: %s: %v", target, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateDomains ensures each domain has a unique name, at least one address, and a base DN.
+func (c *Config) validateDomains() error {
+	names := map[string]struct{}{}
+	for i, d := range c.Domains {
+		if len(d.Name) == 0 {
+			return fmt.Errorf("domain: %d: name is required", i)
+		}
+		if _, ok := names[d.Name]; ok {
+			return fmt.Errorf("domain: %s: duplicate domain name", d.Name)
+		}
+		names[d.Name] = struct{}{}
+
+		if len(d.Addresses) == 0 {
+			return fmt.Errorf("domain: %s: at least one address is required", d.Name)
+		}
+		if len(d.BaseDN) == 0 {
+			return fmt.Errorf("domain: %s: baseDN is required", d.Name)
+		}
+		if d.PageSize < 0 {
+			return fmt.Errorf("domain: %s: pageSize cannot be negative", d.Name)
+		}
+		if d.FailoverCooldown < 0 {
+			return fmt.Errorf("domain: %s: failoverCooldown cannot be negative", d.Name)
+		}
+		mode, err := ldapcli.ParseAuthMode(d.AuthMode)
+		if err != nil {
+			return fmt.Errorf("domain: %s: %v", d.Name, err)
+		}
+		if mode == ldapcli.AuthExternal {
+			return fmt.Errorf("domain: %s: external authentication is not supported by the server", d.Name)
+		}
+		if err := d.TLS.validate(); err != nil {
+			return fmt.Errorf("domain: %s: tls: %v", d.Name, err)
+		}
+	}
+	return nil
+}
+
+// TLS configures how LDAP server certificates are verified, and the client certificate presented to LDAP servers.
+type TLS struct {
+	CAFile       string // PEM file of CA certificates trusted in addition to the system roots
+	CADir        string // Directory of PEM files with CA certificates trusted in addition to the system roots
+	CertFile     string // PEM client certificate presented to LDAP servers that request one
+	KeyFile      string // PEM private key of CertFile
+	MinVersion   string // The minimum TLS version, one of: 1.0, 1.1, 1.2 (default), 1.3
+	ServerName   string // The name server certificates are verified against, defaults to the host of each address
+	CipherPolicy string // The cipher suites offered for TLS 1.2 and earlier, one of: default (default), strict, legacy
+}
+
+// validate ensures the TLS version and cipher policy are known, and that a certificate is given with its key.
+func (t TLS) validate() error {
+	if _, err := ldapcli.ParseTLSVersion(t.MinVersion); err != nil {
+		return err
+	}
+	if _, err := ldapcli.ParseCipherPolicy(t.CipherPolicy); err != nil {
+		return err
+	}
+	if (len(t.CertFile) == 0) != (len(t.KeyFile) == 0) {
+		return fmt.Errorf("certFile and keyFile must be given together")
+	}
+	return nil
+}
+
+// DomainTLS returns the TLS settings for the addresses of the given domain, with unset fields taken from the
+// server's TLS settings. The server's TLS settings are returned if the domain is nil.
+func (c *Config) DomainTLS(domain *Domain) TLS {
+	t := c.TLS
+	if domain == nil {
+		return t
+	}
+
+	override := func(value *string, domainValue string) {
+		if len(domainValue) > 0 {
+			*value = domainValue
+		}
+	}
+	override(&t.CAFile, domain.TLS.CAFile)
+	override(&t.CADir, domain.TLS.CADir)
+	override(&t.MinVersion, domain.TLS.MinVersion)
+	override(&t.ServerName, domain.TLS.ServerName)
+	override(&t.CipherPolicy, domain.TLS.CipherPolicy)
+
+	// the key belongs to the certificate, so they are overridden together
+	if len(domain.TLS.CertFile) > 0 {
+		t.CertFile = domain.TLS.CertFile
+		t.KeyFile = domain.TLS.KeyFile
+	}
+
+	return t
+}
+
+// SigningKey is a key used for signing and encrypting authentication tokens.
+type SigningKey struct {
+	ID             string    // The key ID, sent in the `kid` header of tokens
+	Algorithm      string    // The signing algorithm, one of: HS256 (default), RS256, ES256, EdDSA
+	Secret         string    // The secret used for encrypting claims, and for signing when using HS256
+	PrivateKeyFile string    // Path to the PEM encoded private key used for signing when using RS256, ES256, or EdDSA
+	RetireAt       time.Time // Optional, tokens signed with this key are rejected after this time (RFC3339 format)
+}
+
+// Keyring returns the keyring for signing and validating authentication tokens. If SecretKey is set,
+// it is included with an empty key ID so tokens signed before keys were rotated remain valid.
+func (c *Config) Keyring() (*authtoken.Keyring, error) {
+	keys := []authtoken.Key{}
+	if len(c.SecretKey) > 0 {
+		keys = append(keys, authtoken.Key{Secret: c.SecretKey})
+	}
+
+	for _, k := range c.SigningKeys {
+		key := authtoken.Key{
+			ID:        k.ID,
+			Algorithm: k.Algorithm,
+			Secret:    k.Secret,
+			RetireAt:  k.RetireAt,
+		}
+
+		if len(k.PrivateKeyFile) > 0 {
+			privateKey, err := loadPrivateKey(k.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("key: %s: %v", k.ID, err)
+			}
+			key.PrivateKey = privateKey
+		}
+
+		keys = append(keys, key)
+	}
+
+	active := c.ActiveKeyID
+	if len(active) == 0 && len(c.SigningKeys) > 0 {
+		active = c.SigningKeys[0].ID
+	}
+
+	return authtoken.NewKeyring(active, keys...)
+}
+
+// loadPrivateKey reads and parses the private key at the given path. The result is cached until the
+// config file is modified.
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	mu.RLock()
+	key, ok := privateKeys[path]
+	mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err = authtoken.ParsePrivateKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %s: %v", path, err)
+	}
+
+	mu.Lock()
+	privateKeys[path] = key
+	mu.Unlock()
+
+	return key, nil
 }
 
 // Get reads in the configuration from the config file and returns a Config object.
@@ -36,7 +327,11 @@ func Get() (*Config, error) {
 	}
 
 	config := &Config{}
-	if err := viper.Unmarshal(config); err != nil {
+	if err := viper.Unmarshal(config, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+	))); err != nil {
 		return nil, err
 	}
 
@@ -49,9 +344,46 @@ func Get() (*Config, error) {
 		}
 	}
 
-	// SecretKey is required
-	if len(config.SecretKey) == 0 {
-		return nil, fmt.Errorf("secretKey is a required field")
+	// set the token TTL if not set in config file
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = 8 * time.Hour
+	}
+
+	// set pool defaults if not set in config file
+	if config.PoolMaxIdle <= 0 {
+		config.PoolMaxIdle = DefaultPoolMaxIdle
+	}
+	if config.PoolIdleTimeout <= 0 {
+		config.PoolIdleTimeout = DefaultPoolIdleTimeout
+	}
+
+	// SecretKey or SigningKeys is required
+	if len(config.SecretKey) == 0 && len(config.SigningKeys) == 0 {
+		return nil, fmt.Errorf("one of secretKey or signingKeys is required")
+	}
+
+	if _, err := config.Keyring(); err != nil {
+		return nil, fmt.Errorf("invalid signing keys: %v", err)
+	}
+
+	if err := config.TLS.validate(); err != nil {
+		return nil, fmt.Errorf("invalid tls: %v", err)
+	}
+
+	if err := config.validateDomains(); err != nil {
+		return nil, fmt.Errorf("invalid domains: %v", err)
+	}
+
+	if err := config.validateAllowedTargets(); err != nil {
+		return nil, fmt.Errorf("invalid allowedTargets: %v", err)
+	}
+
+	if err := config.validateRoles(); err != nil {
+		return nil, fmt.Errorf("invalid roles: %v", err)
+	}
+
+	if _, err := config.RedactionPolicy(); err != nil {
+		return nil, fmt.Errorf("invalid redaction: %v", err)
 	}
 
 	Set(config)
@@ -80,6 +412,7 @@ func init() {
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		mu.Lock()
 		conf = nil
+		privateKeys = map[string]crypto.Signer{}
 		mu.Unlock()
 	})
 }
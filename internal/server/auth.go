@@ -2,17 +2,19 @@ package server
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/deejross/direktor/internal/config"
 	"github.com/deejross/direktor/pkg/authtoken"
 	"github.com/deejross/direktor/pkg/ldapcli"
 	"github.com/gin-gonic/gin"
-	"github.com/go-ldap/ldap/v3"
+	"go.uber.org/zap"
 )
 
 // AuthTokenRequest object.
 type AuthTokenRequest struct {
-	Address         string `json:"address"`
+	Domain          string `json:"domain,omitempty"`
+	Address         string `json:"address,omitempty"`
 	BaseDN          string `json:"baseDN"`
 	Username        string `json:"username,omitempty"`
 	Password        string `json:"password,omitempty"`
@@ -20,22 +22,73 @@ type AuthTokenRequest struct {
 	SkipVerify      *bool  `json:"skipVerify,omitempty"`
 	PageSize        *int   `json:"pageSize,omitempty"`
 	FollowReferrals *bool  `json:"followReferrals,omitempty"`
+	AuthMode        string `json:"authMode,omitempty"`
 }
 
 // Validate the request.
 func (r *AuthTokenRequest) Validate() error {
+	mode, err := ldapcli.ParseAuthMode(r.AuthMode)
+	if err != nil {
+		return err
+	}
+
+	// the server's client certificate would identify every caller, so EXTERNAL can't be used to issue tokens
+	if mode == ldapcli.AuthExternal {
+		return fmt.Errorf("external authentication is not supported by the server")
+	}
+
+	if len(r.Domain) > 0 {
+		if len(r.Address) > 0 {
+			return fmt.Errorf("only one of domain or address can be given")
+		}
+		return nil
+	}
 	if len(r.Address) == 0 {
-		return fmt.Errorf("address is a required field")
+		return fmt.Errorf("one of domain or address is a required field")
+	}
+	return nil
+}
+
+// applyDomain fills in any unset connection settings from the domain the address belongs to. The domain's TLS
+// and authentication settings can't be changed by the request, so a conflicting value is an error.
+func (r *AuthTokenRequest) applyDomain(domain *config.Domain) error {
+	if r.StartTLS != nil && *r.StartTLS != domain.StartTLS {
+		return fmt.Errorf("startTLS is set by the domain and cannot be changed")
+	}
+	if r.SkipVerify != nil && *r.SkipVerify != domain.SkipVerify {
+		return fmt.Errorf("skipVerify is set by the domain and cannot be changed")
+	}
+	if len(r.AuthMode) > 0 {
+		// both modes have been validated, and an empty domain mode means simple
+		mode, _ := ldapcli.ParseAuthMode(r.AuthMode)
+		domainMode, _ := ldapcli.ParseAuthMode(domain.AuthMode)
+		if mode != domainMode {
+			return fmt.Errorf("authMode is set by the domain and cannot be changed")
+		}
 	}
+	r.StartTLS = &domain.StartTLS
+	r.SkipVerify = &domain.SkipVerify
+	r.AuthMode = domain.AuthMode
+
 	if len(r.BaseDN) == 0 {
-		return fmt.Errorf("baseDN is a required field")
+		r.BaseDN = domain.BaseDN
+	}
+	if r.PageSize == nil && domain.PageSize > 0 {
+		r.PageSize = &domain.PageSize
+	}
+	if r.FollowReferrals == nil {
+		r.FollowReferrals = &domain.FollowReferrals
 	}
+
 	return nil
 }
 
-// AuthTokenResponse object.
+// AuthTokenResponse object. Address is the LDAP address the token is valid for,
+// and must be sent in the X-Ldap-Address header with the token.
 type AuthTokenResponse struct {
-	Token string `json:"token"`
+	Token     string `json:"token"`
+	Address   string `json:"address"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"`
 }
 
 func handleAuthToken(c *gin.Context) {
@@ -50,6 +103,42 @@ func handleAuthToken(c *gin.Context) {
 		return
 	}
 
+	conf, err := getConfig(c)
+	if err != nil {
+		log.Error("could not get config", zap.Error(err))
+		newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
+		return
+	}
+
+	if len(req.Domain) > 0 {
+		domain, ok := conf.Domain(req.Domain)
+		if !ok {
+			newError(c, 404, fmt.Errorf("domain not found: %s", req.Domain))
+			return
+		}
+
+		req.Address = domain.Addresses[0]
+	}
+
+	// the settings of a domain apply whether it was named or one of its addresses was given
+	if domain, ok := domainByAddress(conf, req.Address); ok {
+		if err := req.applyDomain(domain); err != nil {
+			newError(c, 400, err)
+			return
+		}
+	}
+
+	if !allowTarget(c, conf, req.Address) {
+		return
+	}
+
+	// Kerberos tickets are requested with the password, never with the server's own credential cache
+	authMode, _ := ldapcli.ParseAuthMode(req.AuthMode)
+	if authMode == ldapcli.AuthGSSAPI && (len(req.Username) == 0 || len(req.Password) == 0) {
+		newError(c, 400, fmt.Errorf("username and password are required for gssapi authentication"))
+		return
+	}
+
 	plainClaims := map[string]interface{}{
 		claimBaseDN:       req.BaseDN,
 		claimBindUsername: req.Username,
@@ -58,6 +147,16 @@ func handleAuthToken(c *gin.Context) {
 	ldapConf := ldapcli.NewConfig(req.Address, req.BaseDN)
 	ldapConf.BindUsername = req.Username
 	ldapConf.BindPassword = req.Password
+	applyFailover(ldapConf, conf)
+	applyTLS(ldapConf, conf)
+	ldapConf.AddressPolicy = targetPolicy(conf)
+	ldapConf.DisableCCache = true
+
+	if authMode != ldapcli.AuthSimple {
+		plainClaims[claimAuthMode] = string(authMode)
+		ldapConf.AuthMode = authMode
+		ldapConf.Krb5ConfPath = conf.Krb5Conf
+	}
 
 	if req.FollowReferrals != nil {
 		plainClaims[claimFollowReferrals] = *req.FollowReferrals
@@ -76,50 +175,140 @@ func handleAuthToken(c *gin.Context) {
 		ldapConf.StartTLS = *req.StartTLS
 	}
 
-	cli, err := ldapcli.Dial(ldapConf)
+	cli, err := ldapcli.DialContext(c.Request.Context(), ldapConf)
 	if err != nil {
-		if e, ok := err.(*ldap.Error); ok {
-			if e.ResultCode == ldap.LDAPResultInvalidCredentials {
-				newError(c, 401, e)
-				return
-			}
-
-			newError(c, 400, e)
-			return
-		}
-
-		newError(c, 400, err)
+		newLDAPError(c, err)
 		return
 	}
+
+	// the base DN is read from the server if it wasn't given
+	plainClaims[claimBaseDN] = cli.Config().BaseDN
 	cli.Close()
 
 	encryptedClaims := map[string]interface{}{
 		claimBindPassword: req.Password,
 	}
 
-	conf, err := config.Get()
+	resp, err := signToken(c, req.Address, plainClaims, encryptedClaims)
 	if err != nil {
 		newError(c, 500, err)
 		return
 	}
 
-	token, err := authtoken.SignToken(conf.SecretKey, tokenIssuer, req.Address, plainClaims, encryptedClaims)
-	if err != nil {
-		newError(c, 500, fmt.Errorf("could not sign token: %v", err))
-		return
-	}
-
-	c.JSON(200, &AuthTokenResponse{
-		Token: token,
-	})
+	c.JSON(200, resp)
 }
 
 func handleAuthTokenCheck(c *gin.Context) {
 	cli := ldapClient(c)
 	if cli != nil {
-		cli.Close()
+		pool.put(cli)
 		c.JSON(200, gin.H{
 			"result": "OK",
 		})
 	}
 }
+
+func handleAuthTokenRefresh(c *gin.Context) {
+	token, ldapAddress, claims := requestClaims(c)
+	if claims == nil {
+		return
+	}
+
+	// ensure the credentials in the token are still valid before issuing a new one
+	cli := dialClaims(c, token, ldapAddress, claims)
+	if cli == nil {
+		return
+	}
+	pool.put(cli)
+
+	plainClaims := map[string]interface{}{}
+	encryptedClaims := map[string]interface{}{}
+	for k, v := range claims {
+		if _, ok := registeredClaims[k]; ok {
+			continue
+		} else if k == claimBindPassword {
+			encryptedClaims[k] = v
+		} else {
+			plainClaims[k] = v
+		}
+	}
+
+	resp, err := signToken(c, ldapAddress, plainClaims, encryptedClaims)
+	if err != nil {
+		newError(c, 500, err)
+		return
+	}
+
+	if err := revokeToken(token, claims); err != nil {
+		log.Error("could not revoke token", zap.Error(err))
+		newError(c, 500, fmt.Errorf("could not revoke token, please see server logs for more information"))
+		return
+	}
+
+	c.JSON(200, resp)
+}
+
+func handleAuthTokenRevoke(c *gin.Context) {
+	token, _, claims := requestClaims(c)
+	if claims == nil {
+		return
+	}
+
+	if err := revokeToken(token, claims); err != nil {
+		log.Error("could not revoke token", zap.Error(err))
+		newError(c, 500, fmt.Errorf("could not revoke token, please see server logs for more information"))
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"result": "OK",
+	})
+}
+
+// signToken signs a new token for the given LDAP address using the secret key and TTL of the request's config.
+func signToken(c *gin.Context, ldapAddress string, plainClaims, encryptedClaims map[string]interface{}) (*AuthTokenResponse, error) {
+	conf, err := getConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring, err := conf.Keyring()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := keyring.SignToken(tokenIssuer, ldapAddress, conf.TokenTTL, plainClaims, encryptedClaims)
+	if err != nil {
+		return nil, fmt.Errorf("could not sign token: %v", err)
+	}
+
+	resp := &AuthTokenResponse{
+		Token:   token,
+		Address: ldapAddress,
+	}
+	if conf.TokenTTL > 0 {
+		resp.ExpiresAt = time.Now().Add(conf.TokenTTL).Unix()
+	}
+
+	return resp, nil
+}
+
+// revokeToken adds the token's ID to the revocation store and closes any pooled connections for it.
+func revokeToken(token string, claims authtoken.Claims) error {
+	id, ok := claims.String("jti")
+	if !ok {
+		return fmt.Errorf("token does not contain `jti` claim")
+	}
+
+	var expiresAt time.Time
+	if exp, ok := claims.Int("exp"); ok {
+		expiresAt = time.Unix(int64(exp), 0)
+	}
+
+	if err := revocations().Revoke(id, expiresAt); err != nil {
+		return err
+	}
+
+	pool.closeToken(token)
+	return nil
+}
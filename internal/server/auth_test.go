@@ -1,9 +1,19 @@
 package server
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/deejross/direktor/internal/config"
+	"github.com/deejross/direktor/pkg/authtoken"
 	"github.com/deejross/direktor/pkg/ldapmockserver"
+	"github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -27,4 +37,210 @@ func TestAuthToken(t *testing.T) {
 		require.NoError(t, err)
 		require.Equal(t, 200, w.StatusCode)
 	})
+
+	t.Run("BaseDNFromRootDSE", func(t *testing.T) {
+		req := req
+		req.BaseDN = ""
+
+		resp := &AuthTokenResponse{}
+		w, err := newRequest("POST", "/v1/auth/token", "", "", req, resp)
+		require.NoError(t, err)
+		require.Equal(t, 200, w.StatusCode)
+
+		conf, err := config.Get()
+		require.NoError(t, err)
+		keyring, err := conf.Keyring()
+		require.NoError(t, err)
+
+		claims, err := keyring.ValidateToken(tokenIssuer, ldapAddress, resp.Token)
+		require.NoError(t, err)
+
+		baseDN, _ := claims.String(claimBaseDN)
+		require.Equal(t, ldapmockserver.TestBaseDN, baseDN)
+	})
+}
+
+func TestAuthTokenOptions(t *testing.T) {
+	pageSize := 50
+	followReferrals := true
+	req := AuthTokenRequest{
+		Address:         ldapAddress,
+		BaseDN:          ldapmockserver.TestBaseDN,
+		Username:        ldapmockserver.TestBindDN,
+		Password:        ldapmockserver.TestBindPW,
+		PageSize:        &pageSize,
+		FollowReferrals: &followReferrals,
+	}
+
+	resp := &AuthTokenResponse{}
+	w, err := newRequest("POST", "/v1/auth/token", "", "", req, resp)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+
+	w, err = newRequest("GET", "/v1/auth/token", resp.Token, ldapAddress, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+
+	// options survive a refresh
+	refreshed := &AuthTokenResponse{}
+	w, err = newRequest("POST", "/v1/auth/refresh", resp.Token, ldapAddress, nil, refreshed)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+
+	w, err = newRequest("GET", "/v1/auth/token", refreshed.Token, ldapAddress, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+}
+
+func TestAuthTokenAuthMode(t *testing.T) {
+	req := AuthTokenRequest{
+		Address:  ldapAddress,
+		BaseDN:   ldapmockserver.TestBaseDN,
+		Username: ldapmockserver.TestBindDN,
+		AuthMode: "ntlm",
+	}
+
+	w, err := newRequest("POST", "/v1/auth/token", "", "", req, nil)
+	require.Error(t, err)
+	require.Equal(t, 400, w.StatusCode)
+
+	// the mock server's address belongs to a domain using simple authentication
+	req.AuthMode = "gssapi"
+	w, err = newRequest("POST", "/v1/auth/token", "", "", req, nil)
+	require.Error(t, err)
+	require.Equal(t, 400, w.StatusCode)
+	require.Contains(t, err.Error(), "authMode is set by the domain")
+
+	// Kerberos tickets are requested with the password, so it's required
+	r := newConfigRouter(newTestConfig(config.Domain{
+		Name:      "kerberos",
+		Addresses: []string{"ldap://localhost:10389"},
+		BaseDN:    ldapmockserver.TestBaseDN,
+		AuthMode:  "gssapi",
+	}))
+
+	w, err = newRouterRequest(r, "POST", "/v1/auth/token", "", "", AuthTokenRequest{Domain: "kerberos"}, nil)
+	require.Error(t, err)
+	require.Equal(t, 400, w.StatusCode)
+	require.Contains(t, err.Error(), "username and password are required")
+
+	// the server's client certificate would identify every caller
+	req.AuthMode = "external"
+	w, err = newRequest("POST", "/v1/auth/token", "", "", req, nil)
+	require.Error(t, err)
+	require.Equal(t, 400, w.StatusCode)
+}
+
+func TestAuthTokenRefresh(t *testing.T) {
+	token := newToken(t)
+
+	resp := &AuthTokenResponse{}
+	w, err := newRequest("POST", "/v1/auth/refresh", token, ldapAddress, nil, resp)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+	require.NotEmpty(t, resp.Token)
+	require.NotEqual(t, token, resp.Token)
+	require.InDelta(t, time.Now().Add(time.Hour).Unix(), resp.ExpiresAt, 5)
+
+	w, err = newRequest("GET", "/v1/auth/token", resp.Token, ldapAddress, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+
+	// the original token is revoked once refreshed
+	w, err = newRequest("GET", "/v1/auth/token", token, ldapAddress, nil, nil)
+	require.Error(t, err)
+	require.Equal(t, 401, w.StatusCode)
+}
+
+func TestAuthTokenRevoke(t *testing.T) {
+	token := newToken(t)
+
+	w, err := newRequest("DELETE", "/v1/auth/token", token, ldapAddress, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+
+	w, err = newRequest("GET", "/v1/auth/token", token, ldapAddress, nil, nil)
+	require.Error(t, err)
+	require.Equal(t, 401, w.StatusCode)
+
+	w, err = newRequest("POST", "/v1/auth/refresh", token, ldapAddress, nil, nil)
+	require.Error(t, err)
+	require.Equal(t, 401, w.StatusCode)
+}
+
+func TestAuthTokenKeyRotation(t *testing.T) {
+	orig, err := config.Get()
+	require.NoError(t, err)
+	defer config.Set(orig)
+
+	token := newToken(t)
+
+	// rotate to a new key, keeping the original secret key until its deadline
+	rotated := *orig
+	rotated.SigningKeys = []config.SigningKey{{ID: "2020-02", Secret: "rotated-secret-key"}}
+	config.Set(&rotated)
+
+	w, err := newRequest("GET", "/v1/auth/token", token, ldapAddress, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+
+	newToken := newToken(t)
+	w, err = newRequest("GET", "/v1/auth/token", newToken, ldapAddress, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+
+	// remove the original secret key
+	rotated.SecretKey = ""
+	config.Set(&rotated)
+
+	w, err = newRequest("GET", "/v1/auth/token", token, ldapAddress, nil, nil)
+	require.Error(t, err)
+	require.Equal(t, 401, w.StatusCode)
+
+	w, err = newRequest("GET", "/v1/auth/token", newToken, ldapAddress, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+}
+
+func TestJWKS(t *testing.T) {
+	orig, err := config.Get()
+	require.NoError(t, err)
+	defer config.Set(orig)
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "signing-key.pem")
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+
+	asymmetric := *orig
+	asymmetric.SigningKeys = []config.SigningKey{{
+		ID:             "2020-03",
+		Algorithm:      authtoken.AlgorithmEdDSA,
+		Secret:         "encryption-secret-key",
+		PrivateKeyFile: keyFile,
+	}}
+	config.Set(&asymmetric)
+
+	set := &authtoken.JSONWebKeySet{}
+	w, err := newRequest("GET", "/.well-known/jwks.json", "", "", nil, set)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+	require.Len(t, set.Keys, 1)
+	require.Equal(t, "2020-03", set.Keys[0].KeyID)
+	require.Equal(t, authtoken.AlgorithmEdDSA, set.Keys[0].Algorithm)
+
+	token := newToken(t)
+	parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		return privateKey.Public(), nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "2020-03", parsed.Header["kid"])
+
+	w, err = newRequest("GET", "/v1/auth/token", token, ldapAddress, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
 }
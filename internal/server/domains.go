@@ -0,0 +1,37 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// DomainResponse object describing a configured domain.
+type DomainResponse struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+	BaseDN    string   `json:"baseDN"`
+}
+
+func handleDomains(c *gin.Context) {
+	conf, err := getConfig(c)
+	if err != nil {
+		log.Error("could not get config", zap.Error(err))
+		newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
+		return
+	}
+
+	domains := make([]DomainResponse, 0, len(conf.Domains))
+	for _, d := range conf.Domains {
+		domains = append(domains, DomainResponse{
+			Name:      d.Name,
+			Addresses: d.Addresses,
+			BaseDN:    d.BaseDN,
+		})
+	}
+
+	c.JSON(200, gin.H{
+		"domains": domains,
+	})
+}
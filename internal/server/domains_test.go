@@ -0,0 +1,226 @@
+package server
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/deejross/direktor/internal/config"
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/deejross/direktor/pkg/ldapmockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomains(t *testing.T) {
+	resp := struct {
+		Domains []DomainResponse `json:"domains"`
+	}{}
+
+	w, err := newRequest("GET", "/v1/domains", "", "", nil, &resp)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+	require.Len(t, resp.Domains, 1)
+	require.Equal(t, testDomain, resp.Domains[0].Name)
+	require.Equal(t, []string{ldapAddress}, resp.Domains[0].Addresses)
+	require.Equal(t, ldapmockserver.TestBaseDN, resp.Domains[0].BaseDN)
+}
+
+func TestAuthTokenDomain(t *testing.T) {
+	req := AuthTokenRequest{
+		Domain:   testDomain,
+		Username: ldapmockserver.TestBindDN,
+		Password: ldapmockserver.TestBindPW,
+	}
+
+	resp := &AuthTokenResponse{}
+	w, err := newRequest("POST", "/v1/auth/token", "", "", req, resp)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+	require.NotEmpty(t, resp.Token)
+	require.Equal(t, ldapAddress, resp.Address)
+
+	w, err = newRequest("GET", "/v1/auth/token", resp.Token, resp.Address, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+
+	t.Run("Unknown", func(t *testing.T) {
+		req := AuthTokenRequest{
+			Domain:   "unknown",
+			Username: ldapmockserver.TestBindDN,
+			Password: ldapmockserver.TestBindPW,
+		}
+
+		w, err := newRequest("POST", "/v1/auth/token", "", "", req, nil)
+		require.Error(t, err)
+		require.Equal(t, 404, w.StatusCode)
+	})
+
+	t.Run("DomainAndAddress", func(t *testing.T) {
+		req := AuthTokenRequest{
+			Domain:   testDomain,
+			Address:  ldapAddress,
+			Username: ldapmockserver.TestBindDN,
+			Password: ldapmockserver.TestBindPW,
+		}
+
+		w, err := newRequest("POST", "/v1/auth/token", "", "", req, nil)
+		require.Error(t, err)
+		require.Equal(t, 400, w.StatusCode)
+	})
+	t.Run("Failover", func(t *testing.T) {
+		conf, err := config.Get()
+		require.NoError(t, err)
+
+		defer func(domains []config.Domain) {
+			conf.Domains = domains
+		}(conf.Domains)
+
+		// nothing listens on the first address, so the mock server answers instead
+		deadAddress := "ldap://127.0.0.1:1"
+		conf.Domains = append(conf.Domains, config.Domain{
+			Name:      "failover",
+			Addresses: []string{deadAddress, ldapAddress},
+			BaseDN:    ldapmockserver.TestBaseDN,
+		})
+
+		req := AuthTokenRequest{
+			Domain:   "failover",
+			Username: ldapmockserver.TestBindDN,
+			Password: ldapmockserver.TestBindPW,
+		}
+
+		resp := &AuthTokenResponse{}
+		w, err := newRequest("POST", "/v1/auth/token", "", "", req, resp)
+		require.NoError(t, err)
+		require.Equal(t, 200, w.StatusCode)
+		require.Equal(t, deadAddress, resp.Address)
+
+		w, err = newRequest("GET", "/v1/auth/token", resp.Token, resp.Address, nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, 200, w.StatusCode)
+		require.Equal(t, ldapAddress, w.Header.Get("X-Ldap-Server"))
+	})
+	t.Run("TLS", func(t *testing.T) {
+		conf, err := config.Get()
+		require.NoError(t, err)
+
+		defer func(domains []config.Domain) {
+			conf.Domains = domains
+		}(conf.Domains)
+
+		// the mock server's address already belongs to a domain, so the domain uses another name for it
+		conf.Domains = append(conf.Domains, config.Domain{
+			Name:      "tls",
+			Addresses: []string{"ldap://localhost:10389"},
+			BaseDN:    ldapmockserver.TestBaseDN,
+			TLS: config.TLS{
+				CAFile: filepath.Join(t.TempDir(), "missing-ca.pem"),
+			},
+		})
+
+		req := AuthTokenRequest{
+			Domain:   "tls",
+			Username: ldapmockserver.TestBindDN,
+			Password: ldapmockserver.TestBindPW,
+		}
+
+		// the domain's CA file is used when connecting
+		w, err := newRequest("POST", "/v1/auth/token", "", "", req, nil)
+		require.Error(t, err)
+		require.Equal(t, 400, w.StatusCode)
+		require.Contains(t, err.Error(), "reading CA file")
+	})
+	t.Run("EnforcedTLS", func(t *testing.T) {
+		conf, err := config.Get()
+		require.NoError(t, err)
+
+		defer func(domains []config.Domain) {
+			conf.Domains = domains
+		}(conf.Domains)
+
+		address := "ldap://localhost:10389"
+		conf.Domains = append(conf.Domains, config.Domain{
+			Name:      "verified",
+			Addresses: []string{address},
+			BaseDN:    ldapmockserver.TestBaseDN,
+		})
+
+		skipVerify := true
+		req := AuthTokenRequest{
+			Domain:     "verified",
+			Username:   ldapmockserver.TestBindDN,
+			Password:   ldapmockserver.TestBindPW,
+			SkipVerify: &skipVerify,
+		}
+
+		w, err := newRequest("POST", "/v1/auth/token", "", "", req, nil)
+		require.Error(t, err)
+		require.Equal(t, 400, w.StatusCode)
+		require.Contains(t, err.Error(), "skipVerify is set by the domain")
+
+		// the same applies when the domain's address is given instead of its name
+		req.Domain = ""
+		req.Address = address
+		w, err = newRequest("POST", "/v1/auth/token", "", "", req, nil)
+		require.Error(t, err)
+		require.Equal(t, 400, w.StatusCode)
+		require.Contains(t, err.Error(), "skipVerify is set by the domain")
+
+		// and to connections for tokens whose claims disable verification
+		ldapConf := ldapcli.NewConfig(address, ldapmockserver.TestBaseDN)
+		ldapConf.SkipVerify = true
+		applyDomainTLS(ldapConf, conf)
+		require.False(t, ldapConf.SkipVerify)
+	})
+	t.Run("EnforcedAuthMode", func(t *testing.T) {
+		address := "ldap://localhost:10389"
+		conf := newTestConfig(config.Domain{
+			Name:      "kerberos",
+			Addresses: []string{address},
+			BaseDN:    ldapmockserver.TestBaseDN,
+			AuthMode:  "gssapi",
+		})
+		conf.Krb5Conf = "/etc/krb5.conf"
+		r := newConfigRouter(conf)
+
+		req := AuthTokenRequest{
+			Domain:   "kerberos",
+			Username: ldapmockserver.TestBindDN,
+			Password: ldapmockserver.TestBindPW,
+			AuthMode: "simple",
+		}
+
+		w, err := newRouterRequest(r, "POST", "/v1/auth/token", "", "", req, nil)
+		require.Error(t, err)
+		require.Equal(t, 400, w.StatusCode)
+		require.Contains(t, err.Error(), "authMode is set by the domain")
+
+		req.Domain = ""
+		req.Address = address
+		w, err = newRouterRequest(r, "POST", "/v1/auth/token", "", "", req, nil)
+		require.Error(t, err)
+		require.Equal(t, 400, w.StatusCode)
+		require.Contains(t, err.Error(), "authMode is set by the domain")
+
+		// and to connections for tokens without an auth mode claim
+		ldapConf := ldapcli.NewConfig(address, ldapmockserver.TestBaseDN)
+		applyDomainTLS(ldapConf, conf)
+		require.Equal(t, ldapcli.AuthGSSAPI, ldapConf.AuthMode)
+		require.Equal(t, conf.Krb5Conf, ldapConf.Krb5ConfPath)
+
+		// which need the token's password, such as for a token issued before the domain switched to gssapi, since
+		// the server's own credential cache is never used
+		keyring, err := conf.Keyring()
+		require.NoError(t, err)
+
+		token, err := keyring.SignToken(tokenIssuer, address, time.Hour, map[string]interface{}{
+			claimBaseDN: ldapmockserver.TestBaseDN,
+		}, nil)
+		require.NoError(t, err)
+
+		w, err = newRouterRequest(r, "GET", "/v1/auth/token", token, address, nil, nil)
+		require.Error(t, err)
+		require.Equal(t, 401, w.StatusCode)
+		require.Contains(t, err.Error(), "required for gssapi authentication")
+	})
+}
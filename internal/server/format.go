@@ -0,0 +1,144 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/deejross/direktor/pkg/formatter"
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/gin-gonic/gin"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// mediaTypeFormats maps accepted media types to registered formatters, in order of preference.
+var mediaTypeFormats = []struct {
+	mediaType string
+	format    string
+}{
+	{"application/json", "json"},
+	{"application/ldif", "ldif"},
+	{"text/x-ldif", "ldif"},
+	{"application/yaml", "yaml"},
+	{"application/x-yaml", "yaml"},
+	{"text/yaml", "yaml"},
+	{"text/plain", "text"},
+	{"text/csv", "csv"},
+	{"text/tab-separated-values", "tsv"},
+	{"application/jsonl", "jsonl"},
+	{"application/x-ndjson", "jsonl"},
+}
+
+// negotiateFormat determines the formatter to use from the `format` query parameter, or the Accept header
+// if the parameter is not given. Any errors encountered will be sent back as a JSON response and this
+// function will return an empty string.
+func negotiateFormat(c *gin.Context) string {
+	if format := c.Query("format"); len(format) > 0 {
+		if formatter.LDAPFormatters[format] == nil {
+			newError(c, 400, fmt.Errorf("unrecognized format: %s", format))
+			return ""
+		}
+		return format
+	}
+
+	offered := make([]string, len(mediaTypeFormats))
+	for i, m := range mediaTypeFormats {
+		offered[i] = m.mediaType
+	}
+
+	mediaType := c.NegotiateFormat(offered...)
+	for _, m := range mediaTypeFormats {
+		if m.mediaType == mediaType {
+			return m.format
+		}
+	}
+
+	newError(c, 406, fmt.Errorf("the accepted formats are not offered by the server"))
+	return ""
+}
+
+// sendSearchResult sends the given result in the format requested by the client, writing entries directly to the
+// response body. Attributes are redacted by the given policy, see redactionPolicy.
+func sendSearchResult(c *gin.Context, cli *ldapcli.Client, resp *ldap.SearchResult, attributes []string, redaction *formatter.RedactionPolicy) {
+	format := negotiateFormat(c)
+	if len(format) == 0 {
+		return
+	}
+
+	// tabular formats accept options for attributes with multiple values
+	multiValue, err := formatter.ParseMultiValueMode(c.Query("multiValue"))
+	if err != nil {
+		newError(c, 400, err)
+		return
+	}
+
+	// values are decoded for display unless raw values are requested, and interpreted if requested
+	raw, ok := queryBool(c, "raw")
+	if !ok {
+		return
+	}
+
+	interpret, ok := queryBool(c, "interpret")
+	if !ok {
+		return
+	}
+
+	opts := &formatter.LDAPStreamOptions{
+		Attributes: attributes,
+		MultiValue: multiValue,
+		Separator:  c.Query("separator"),
+		Raw:        raw,
+		Interpret:  interpret,
+		Redaction:  redaction,
+	}
+
+	// JSON is the native format of the API, so entries from other domains are labeled
+	switch format {
+	case "json", "json-pretty", "jsonl":
+		opts.Domain = domainLabeler(cli.Config().BaseDN)
+	}
+
+	s, err := formatter.NewLDAPStreamFormatter(c.Writer, format, opts)
+	if err != nil {
+		newError(c, 500, err)
+		return
+	}
+
+	c.Header("Content-Type", formatter.LDAPContentTypes[format])
+	c.Status(200)
+
+	// the status has already been sent, so errors can only be logged
+	if err := formatter.WriteLDAPSearchResult(s, resp); err != nil {
+		c.Error(err)
+	}
+}
+
+// queryBool returns the value of an optional boolean query parameter. Invalid values will be sent back as a
+// JSON error response, and this function will return false for ok.
+func queryBool(c *gin.Context, name string) (value bool, ok bool) {
+	s := c.Query(name)
+	if len(s) == 0 {
+		return false, true
+	}
+
+	value, err := strconv.ParseBool(s)
+	if err != nil {
+		newError(c, 400, fmt.Errorf("%s must be true or false", name))
+		return false, false
+	}
+
+	return value, true
+}
+
+// domainLabeler returns a function that returns the domain of entries that do not belong to the domain of the
+// given base DN, such as members discovered by following referrals, or an empty string for entries that do.
+func domainLabeler(baseDN string) func(dn string) string {
+	domain := ldapcli.ParseDomainFromDN(baseDN)
+
+	return func(dn string) string {
+		if entryDomain := ldapcli.ParseDomainFromDN(dn); !strings.EqualFold(entryDomain, domain) {
+			return entryDomain
+		}
+		return ""
+	}
+}
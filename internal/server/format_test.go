@@ -0,0 +1,151 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deejross/direktor/pkg/formatter"
+	"github.com/deejross/direktor/pkg/ldapmockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func newFormatRequest(t *testing.T, path, token, accept string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", path, nil)
+	require.NoError(t, err)
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Ldap-Address", ldapAddress)
+	if len(accept) > 0 {
+		req.Header.Set("Accept", accept)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestContentNegotiation(t *testing.T) {
+	token := newToken(t)
+	path := "/v1/search?cn=tesla&attributes=cn,mail"
+
+	t.Run("Default", func(t *testing.T) {
+		w := newFormatRequest(t, path, token, "")
+		require.Equal(t, 200, w.Code)
+		require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/json"))
+	})
+
+	t.Run("AcceptLDIF", func(t *testing.T) {
+		w := newFormatRequest(t, path, token, "application/ldif")
+		require.Equal(t, 200, w.Code)
+		require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/ldif"))
+		require.Contains(t, w.Body.String(), "dn: cn=tesla,ou=scientists,dc=example,dc=com")
+	})
+
+	t.Run("AcceptYAML", func(t *testing.T) {
+		w := newFormatRequest(t, path, token, "text/html;q=0.9, application/yaml")
+		require.Equal(t, 200, w.Code)
+		require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/yaml"))
+		require.Contains(t, w.Body.String(), "distinguishedname: cn=tesla,ou=scientists,dc=example,dc=com")
+	})
+
+	t.Run("AcceptText", func(t *testing.T) {
+		w := newFormatRequest(t, path, token, "text/plain")
+		require.Equal(t, 200, w.Code)
+		require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"))
+		require.Contains(t, w.Body.String(), "Distinguished Name: cn=tesla,ou=scientists,dc=example,dc=com")
+	})
+
+	t.Run("AcceptCSV", func(t *testing.T) {
+		w := newFormatRequest(t, path, token, "text/csv")
+		require.Equal(t, 200, w.Code)
+		require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv"))
+		require.Equal(t, "dn,cn,mail\n\"cn=tesla,ou=scientists,dc=example,dc=com\",tesla,tesla@example.com\n", w.Body.String())
+	})
+
+	t.Run("AcceptTSV", func(t *testing.T) {
+		w := newFormatRequest(t, path+"&multiValue=first", token, "text/tab-separated-values")
+		require.Equal(t, 200, w.Code)
+		require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/tab-separated-values"))
+		require.Equal(t, "dn\tcn\tmail\ncn=tesla,ou=scientists,dc=example,dc=com\ttesla\ttesla@example.com\n", w.Body.String())
+	})
+
+	t.Run("Table", func(t *testing.T) {
+		w := newFormatRequest(t, path+"&format=table", token, "")
+		require.Equal(t, 200, w.Code)
+		require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain"))
+		require.Equal(t, "DN                                        CN     MAIL\n"+
+			"cn=tesla,ou=scientists,dc=example,dc=com  tesla  tesla@example.com\n", w.Body.String())
+	})
+
+	t.Run("UnknownMultiValueMode", func(t *testing.T) {
+		w := newFormatRequest(t, path+"&format=csv&multiValue=all", token, "")
+		require.Equal(t, 400, w.Code)
+	})
+
+	t.Run("JSONLines", func(t *testing.T) {
+		w := newFormatRequest(t, path+"&format=jsonl", token, "")
+		require.Equal(t, 200, w.Code)
+		require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/jsonl"))
+
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		require.Len(t, lines, 1)
+
+		entry := formatter.LDAPEntry{}
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+		require.Equal(t, "cn=tesla,ou=scientists,dc=example,dc=com", entry.DistinguishedName)
+	})
+
+	t.Run("FormatParameter", func(t *testing.T) {
+		w := newFormatRequest(t, path+"&format=ldif", token, "application/json")
+		require.Equal(t, 200, w.Code)
+		require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "application/ldif"))
+	})
+
+	t.Run("UnknownFormat", func(t *testing.T) {
+		w := newFormatRequest(t, path+"&format=xml", token, "")
+		require.Equal(t, 400, w.Code)
+	})
+
+	t.Run("NotAcceptable", func(t *testing.T) {
+		w := newFormatRequest(t, path, token, "image/png")
+		require.Equal(t, 406, w.Code)
+	})
+}
+
+func TestDecodeValues(t *testing.T) {
+	token := newToken(t)
+	path := "/v1/search?cn=tesla&attributes=cn,objectSid,objectGUID&format=csv"
+
+	w := newFormatRequest(t, path, token, "")
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "dn,cn,objectSid,objectGUID\n\"cn=tesla,ou=scientists,dc=example,dc=com\",tesla,S-1-5-21-3623864263-3361045372-30299796-1013,a1b2c3d4-e5f6-a7b8-c9d0-e1f2a3b4c5d6\n", w.Body.String())
+
+	w = newFormatRequest(t, path+"&raw=true", token, "")
+	require.Equal(t, 200, w.Code)
+	require.Contains(t, w.Body.String(), ldapmockserver.TestObjectGUID)
+
+	w = newFormatRequest(t, path+"&raw=maybe", token, "")
+	require.Equal(t, 400, w.Code)
+}
+
+func TestInterpretValues(t *testing.T) {
+	token := newToken(t)
+	path := "/v1/search?cn=tesla&attributes=userAccountControl,pwdLastSet,accountExpires,whenCreated&format=csv"
+
+	w := newFormatRequest(t, path+"&interpret=true", token, "")
+	require.Equal(t, 200, w.Code)
+	require.Equal(t, "dn,userAccountControl,pwdLastSet,accountExpires,whenCreated\n\"cn=tesla,ou=scientists,dc=example,dc=com\",NORMAL_ACCOUNT | DONT_EXPIRE_PASSWORD,2020-01-01T00:00:00Z,never,2020-01-02T15:04:05Z\n", w.Body.String())
+
+	w = newFormatRequest(t, path, token, "")
+	require.Equal(t, 200, w.Code)
+	require.Contains(t, w.Body.String(), ",66048,132223104000000000,9223372036854775807,20200102150405.0Z")
+}
+
+func TestDomainLabeler(t *testing.T) {
+	label := domainLabeler(ldapmockserver.TestBaseDN)
+	require.Empty(t, label("cn=tesla,ou=scientists,dc=example,dc=com"))
+	require.Equal(t, "child.example.com", label("CN=curie,OU=scientists,DC=child,DC=example,DC=com"))
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/deejross/direktor/pkg/formatter"
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/gin-gonic/gin"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ListRequest object for endpoints that return a list of entries.
+type ListRequest struct {
+	Attributes []string `form:"attributes"`
+	Page       int      `form:"page"`
+	PageSize   int      `form:"pageSize"`
+}
+
+// Validate the request.
+func (r *ListRequest) Validate() error {
+	if r.Page < 0 {
+		return fmt.Errorf("page cannot be negative")
+	}
+	if r.PageSize < 0 {
+		return fmt.Errorf("pageSize cannot be negative")
+	}
+	if r.Page == 0 {
+		r.Page = 1
+	}
+	return nil
+}
+
+func handleGroupMembers(c *gin.Context) {
+	dn := c.Param("dn")
+	if !ldapcli.IsDNSanitized(dn) {
+		newError(c, 400, fmt.Errorf("dn contains invalid characters: %s", dn))
+		return
+	}
+
+	req := &ListRequest{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		newError(c, 400, err)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		newError(c, 400, err)
+		return
+	}
+
+	cli := ldapClient(c)
+	if cli == nil {
+		return
+	}
+	defer pool.put(cli)
+
+	redaction := redactionPolicy(c, cli)
+	if redaction == nil {
+		return
+	}
+
+	resp, err := cli.GroupMembersExtendedContext(c.Request.Context(), dn, parseAttributes(req.Attributes)...)
+	if err != nil {
+		newLDAPError(c, err)
+		return
+	}
+
+	sendList(c, cli, req, resp, redaction)
+}
+
+func handleOrganizationalUnitChildren(c *gin.Context) {
+	dn := c.Param("dn")
+	if !ldapcli.IsDNSanitized(dn) {
+		newError(c, 400, fmt.Errorf("dn contains invalid characters: %s", dn))
+		return
+	}
+
+	req := &ListRequest{}
+	if err := c.ShouldBindQuery(req); err != nil {
+		newError(c, 400, err)
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		newError(c, 400, err)
+		return
+	}
+
+	cli := ldapClient(c)
+	if cli == nil {
+		return
+	}
+	defer pool.put(cli)
+
+	redaction := redactionPolicy(c, cli)
+	if redaction == nil {
+		return
+	}
+
+	resp, err := cli.OrganizationalUnitMembersContext(c.Request.Context(), dn, parseAttributes(req.Attributes)...)
+	if err != nil {
+		newLDAPError(c, err)
+		return
+	}
+
+	sendList(c, cli, req, resp, redaction)
+}
+
+// sendList paginates the given result and sends it in the requested format.
+// The total number of entries before pagination is sent in the X-Total-Count header.
+func sendList(c *gin.Context, cli *ldapcli.Client, req *ListRequest, resp *ldap.SearchResult, redaction *formatter.RedactionPolicy) {
+	c.Header("X-Total-Count", strconv.Itoa(len(resp.Entries)))
+	resp.Entries = paginate(resp.Entries, req.Page, req.PageSize)
+	sendSearchResult(c, cli, resp, parseAttributes(req.Attributes), redaction)
+}
+
+// paginate returns the given page of entries. Pages start at 1, and a pageSize of 0 returns all entries.
+func paginate(entries []*ldap.Entry, page, pageSize int) []*ldap.Entry {
+	if pageSize <= 0 {
+		return entries
+	}
+
+	start := (page - 1) * pageSize
+	if start >= len(entries) {
+		return []*ldap.Entry{}
+	}
+
+	end := start + pageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	return entries[start:end]
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/deejross/direktor/pkg/formatter"
+	"github.com/deejross/direktor/pkg/ldapmockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupMembers(t *testing.T) {
+	token := newToken(t)
+	path := "/v1/groups/" + url.PathEscape(ldapmockserver.TestGroupDN) + "/members"
+
+	entries := []formatter.LDAPEntry{}
+	w, err := newRequest("GET", path+"?attributes=cn,mail", token, ldapAddress, nil, &entries)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+	require.Equal(t, "3", w.Header.Get("X-Total-Count"))
+	require.Len(t, entries, 3)
+	require.Len(t, entries[0].Attributes, 2)
+	require.Empty(t, entries[0].Domain)
+
+	t.Run("Paginated", func(t *testing.T) {
+		entries := []formatter.LDAPEntry{}
+		w, err := newRequest("GET", path+"?page=2&pageSize=2", token, ldapAddress, nil, &entries)
+		require.NoError(t, err)
+		require.Equal(t, 200, w.StatusCode)
+		require.Equal(t, "3", w.Header.Get("X-Total-Count"))
+		require.Len(t, entries, 1)
+		require.Equal(t, "cn=newton,ou=scientists,dc=example,dc=com", entries[0].DistinguishedName)
+	})
+
+	t.Run("InvalidDN", func(t *testing.T) {
+		w, err := newRequest("GET", "/v1/groups/"+url.PathEscape("cn=*")+"/members", token, ldapAddress, nil, nil)
+		require.Error(t, err)
+		require.Equal(t, 400, w.StatusCode)
+	})
+}
+
+func TestOrganizationalUnitChildren(t *testing.T) {
+	token := newToken(t)
+	path := "/v1/ous/" + url.PathEscape("ou=scientists,dc=example,dc=com") + "/children"
+
+	entries := []formatter.LDAPEntry{}
+	w, err := newRequest("GET", path, token, ldapAddress, nil, &entries)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+	require.Equal(t, "3", w.Header.Get("X-Total-Count"))
+	require.Len(t, entries, 3)
+
+	t.Run("Paginated", func(t *testing.T) {
+		entries := []formatter.LDAPEntry{}
+		w, err := newRequest("GET", path+"?page=5&pageSize=2", token, ldapAddress, nil, &entries)
+		require.NoError(t, err)
+		require.Equal(t, 200, w.StatusCode)
+		require.Empty(t, entries)
+	})
+
+	t.Run("NegativePage", func(t *testing.T) {
+		w, err := newRequest("GET", path+"?page=-1", token, ldapAddress, nil, nil)
+		require.Error(t, err)
+		require.Equal(t, 400, w.StatusCode)
+	})
+}
@@ -0,0 +1,242 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deejross/direktor/internal/config"
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"go.uber.org/zap"
+)
+
+var pool = newClientPool()
+
+// PoolStats contains metrics for the LDAP connection pool.
+type PoolStats struct {
+	Idle      int    `json:"idle"`
+	InUse     int    `json:"inUse"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// clientPool keeps authenticated LDAP clients open between requests. Clients are lent out to one
+// request at a time and are keyed by token fingerprint and LDAP address so they are never shared
+// between different credentials.
+type clientPool struct {
+	mu          sync.Mutex
+	idle        map[string][]*pooledClient
+	inUse       map[*ldapcli.Client]string
+	idleCount   int
+	maxIdle     int
+	idleTimeout time.Duration
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+}
+
+type pooledClient struct {
+	cli      *ldapcli.Client
+	lastUsed time.Time
+}
+
+func newClientPool() *clientPool {
+	return &clientPool{
+		idle:        map[string][]*pooledClient{},
+		inUse:       map[*ldapcli.Client]string{},
+		maxIdle:     config.DefaultPoolMaxIdle,
+		idleTimeout: config.DefaultPoolIdleTimeout,
+	}
+}
+
+// poolKey returns the pool key for the given token and LDAP address.
+func poolKey(token, address string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:]) + "|" + address
+}
+
+// configure the pool limits. Values less than or equal to zero are ignored.
+func (p *clientPool) configure(maxIdle int, idleTimeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if maxIdle > 0 {
+		p.maxIdle = maxIdle
+	}
+	if idleTimeout > 0 {
+		p.idleTimeout = idleTimeout
+	}
+}
+
+// get an idle client for the given key, or use dial to create a new one if none are available.
+// Clients must be returned to the pool using put when they are no longer needed.
+func (p *clientPool) get(key string, dial func() (*ldapcli.Client, error)) (*ldapcli.Client, error) {
+	stale := []*ldapcli.Client{}
+	defer func() {
+		for _, cli := range stale {
+			cli.Close()
+		}
+	}()
+
+	p.mu.Lock()
+	for clients := p.idle[key]; len(clients) > 0; clients = p.idle[key] {
+		pc := clients[len(clients)-1]
+		p.removeIdle(key, len(clients)-1)
+
+		if time.Since(pc.lastUsed) > p.idleTimeout || pc.cli.IsClosing() {
+			stale = append(stale, pc.cli)
+			p.evictions++
+			continue
+		}
+
+		p.hits++
+		p.inUse[pc.cli] = key
+		p.mu.Unlock()
+		return pc.cli, nil
+	}
+	p.misses++
+	p.mu.Unlock()
+
+	cli, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.inUse[cli] = key
+	p.mu.Unlock()
+
+	return cli, nil
+}
+
+// put returns a client to the pool. If the pool is full, the client is closed instead.
+func (p *clientPool) put(cli *ldapcli.Client) {
+	p.mu.Lock()
+	key, ok := p.inUse[cli]
+	delete(p.inUse, cli)
+
+	if !ok || p.idleCount >= p.maxIdle || cli.IsClosing() {
+		if ok {
+			p.evictions++
+		}
+		p.mu.Unlock()
+		cli.Close()
+		return
+	}
+
+	p.idle[key] = append(p.idle[key], &pooledClient{cli: cli, lastUsed: time.Now()})
+	p.idleCount++
+	p.mu.Unlock()
+}
+
+// closeToken closes all idle clients for the given token, regardless of LDAP address.
+func (p *clientPool) closeToken(token string) {
+	prefix := poolKey(token, "")
+	closing := []*ldapcli.Client{}
+
+	p.mu.Lock()
+	for key, clients := range p.idle {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		for _, pc := range clients {
+			closing = append(closing, pc.cli)
+		}
+
+		p.idleCount -= len(clients)
+		delete(p.idle, key)
+	}
+	p.mu.Unlock()
+
+	for _, cli := range closing {
+		cli.Close()
+	}
+}
+
+// sweep closes idle clients that have expired or fail a health check.
+func (p *clientPool) sweep() {
+	p.mu.Lock()
+	candidates := p.idle
+	idleTimeout := p.idleTimeout
+	p.idle = map[string][]*pooledClient{}
+	p.idleCount = 0
+	p.mu.Unlock()
+
+	healthy := map[string][]*pooledClient{}
+	var evicted uint64
+
+	for key, clients := range candidates {
+		for _, pc := range clients {
+			if time.Since(pc.lastUsed) > idleTimeout {
+				pc.cli.Close()
+				evicted++
+				continue
+			}
+
+			if err := pc.cli.Ping(); err != nil {
+				log.Debug("closing unhealthy pooled LDAP connection", zap.String("address", pc.cli.Address()), zap.Error(err))
+				pc.cli.Close()
+				evicted++
+				continue
+			}
+
+			healthy[key] = append(healthy[key], pc)
+		}
+	}
+
+	p.mu.Lock()
+	p.evictions += evicted
+	for key, clients := range healthy {
+		for _, pc := range clients {
+			if p.idleCount >= p.maxIdle {
+				pc.cli.Close()
+				p.evictions++
+				continue
+			}
+
+			p.idle[key] = append(p.idle[key], pc)
+			p.idleCount++
+		}
+	}
+	p.mu.Unlock()
+}
+
+// run sweeps the pool at the given interval.
+func (p *clientPool) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		p.sweep()
+	}
+}
+
+// stats returns the current pool metrics.
+func (p *clientPool) stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return PoolStats{
+		Idle:      p.idleCount,
+		InUse:     len(p.inUse),
+		Hits:      p.hits,
+		Misses:    p.misses,
+		Evictions: p.evictions,
+	}
+}
+
+// removeIdle removes the idle client at index i for the given key. The caller must hold the lock.
+func (p *clientPool) removeIdle(key string, i int) {
+	clients := p.idle[key]
+	clients = append(clients[:i], clients[i+1:]...)
+	if len(clients) == 0 {
+		delete(p.idle, key)
+	} else {
+		p.idle[key] = clients
+	}
+	p.idleCount--
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deejross/direktor/internal/config"
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/deejross/direktor/pkg/ldapmockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func newPoolTestDialer(dials *int) func() (*ldapcli.Client, error) {
+	return func() (*ldapcli.Client, error) {
+		*dials++
+		conf := ldapcli.NewConfig(ldapAddress, ldapmockserver.TestBaseDN)
+		conf.BindUsername = ldapmockserver.TestBindDN
+		conf.BindPassword = ldapmockserver.TestBindPW
+		return ldapcli.Dial(conf)
+	}
+}
+
+func TestPool(t *testing.T) {
+	p := newClientPool()
+	dials := 0
+	dial := newPoolTestDialer(&dials)
+	key := poolKey("token", ldapAddress)
+
+	cli, err := p.get(key, dial)
+	require.NoError(t, err)
+	require.Equal(t, 1, dials)
+	require.Equal(t, PoolStats{InUse: 1, Misses: 1}, p.stats())
+
+	p.put(cli)
+	require.Equal(t, PoolStats{Idle: 1, Misses: 1}, p.stats())
+
+	cli2, err := p.get(key, dial)
+	require.NoError(t, err)
+	require.Equal(t, 1, dials)
+	require.True(t, cli == cli2)
+	require.Equal(t, PoolStats{InUse: 1, Hits: 1, Misses: 1}, p.stats())
+	p.put(cli2)
+
+	t.Run("DifferentToken", func(t *testing.T) {
+		cli, err := p.get(poolKey("other-token", ldapAddress), dial)
+		require.NoError(t, err)
+		require.Equal(t, 2, dials)
+		require.False(t, cli == cli2)
+		p.put(cli)
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		p.sweep()
+		require.Equal(t, 2, p.stats().Idle)
+
+		p.configure(0, time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		p.sweep()
+		require.Equal(t, 0, p.stats().Idle)
+		require.Equal(t, uint64(2), p.stats().Evictions)
+	})
+}
+
+func TestPoolMaxIdle(t *testing.T) {
+	p := newClientPool()
+	p.configure(1, 0)
+	dials := 0
+	dial := newPoolTestDialer(&dials)
+	key := poolKey("token", ldapAddress)
+
+	cli1, err := p.get(key, dial)
+	require.NoError(t, err)
+	cli2, err := p.get(key, dial)
+	require.NoError(t, err)
+	require.Equal(t, 2, dials)
+
+	p.put(cli1)
+	p.put(cli2)
+	require.True(t, cli2.IsClosing())
+	require.Equal(t, PoolStats{Idle: 1, Misses: 2, Evictions: 1}, p.stats())
+}
+
+func TestPoolClosedClient(t *testing.T) {
+	p := newClientPool()
+	dials := 0
+	dial := newPoolTestDialer(&dials)
+	key := poolKey("token", ldapAddress)
+
+	cli, err := p.get(key, dial)
+	require.NoError(t, err)
+	p.put(cli)
+	cli.Close()
+
+	cli, err = p.get(key, dial)
+	require.NoError(t, err)
+	require.False(t, cli.IsClosing())
+	require.Equal(t, 2, dials)
+	p.put(cli)
+}
+
+func TestPoolRequests(t *testing.T) {
+	token := newToken(t)
+	before := pool.stats()
+
+	for i := 0; i < 3; i++ {
+		w, err := newRequest("GET", "/v1/search?cn=tesla", token, ldapAddress, nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, 200, w.StatusCode)
+	}
+
+	after := pool.stats()
+	require.Equal(t, before.Misses+1, after.Misses)
+	require.Equal(t, before.Hits+2, after.Hits)
+	require.Equal(t, 0, after.InUse)
+}
+
+func TestMetrics(t *testing.T) {
+	// metrics are not authenticated, so they are only served if enabled
+	w, err := newRequest("GET", "/metrics", "", "", nil, nil)
+	require.Error(t, err)
+	require.Equal(t, 404, w.StatusCode)
+
+	conf, err := config.Get()
+	require.NoError(t, err)
+	conf.EnableMetrics = true
+	defer func() {
+		conf.EnableMetrics = false
+	}()
+
+	resp := struct {
+		Pool PoolStats `json:"pool"`
+	}{}
+	w, err = newRequest("GET", "/metrics", "", "", nil, &resp)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/deejross/direktor/internal/config"
+	"github.com/deejross/direktor/pkg/formatter"
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/gin-gonic/gin"
+	"github.com/go-ldap/ldap/v3"
+	"go.uber.org/zap"
+)
+
+// redactionPolicy returns the policy for redacting attributes from the response. Users with one of the
+// configured override roles may request unredacted values with the `unredacted=true` query parameter.
+// Any errors encountered will be sent back as a JSON response and this function will return nil.
+func redactionPolicy(c *gin.Context, cli *ldapcli.Client) *formatter.RedactionPolicy {
+	conf, err := getConfig(c)
+	if err != nil {
+		log.Error("could not get config", zap.Error(err))
+		newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
+		return nil
+	}
+
+	unredacted, ok := queryBool(c, "unredacted")
+	if !ok {
+		return nil
+	}
+
+	if !unredacted {
+		policy, err := conf.RedactionPolicy()
+		if err != nil {
+			log.Error("could not get redaction policy", zap.Error(err))
+			newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
+			return nil
+		}
+		return policy
+	}
+
+	username := cli.Config().BindUsername
+	permitted, err := hasRole(c.Request.Context(), conf, cli, conf.Redaction.OverrideRoles)
+	if err != nil {
+		newLDAPError(c, err)
+		return nil
+	}
+
+	fields := []zap.Field{
+		zap.String("username", username),
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+		zap.String("client", c.ClientIP()),
+	}
+
+	if !permitted {
+		audit.Warn("unredacted values denied", fields...)
+		newError(c, 403, fmt.Errorf("not permitted to request unredacted values"))
+		return nil
+	}
+
+	audit.Info("unredacted values requested", fields...)
+	return formatter.NoRedactionPolicy()
+}
+
+// checkFilterRedaction determines if the client may search with the given filter. A filter that tests masked or
+// dropped attributes would reveal their values one search at a time, so it requires one of the roles that may
+// request unredacted values. Any errors encountered will be sent back as a JSON response and this function will
+// return false.
+func checkFilterRedaction(c *gin.Context, cli *ldapcli.Client, redaction *formatter.RedactionPolicy, filter string) bool {
+	attributes, err := ldapcli.FilterAttributes(filter)
+	if err != nil {
+		newLDAPError(c, err)
+		return false
+	}
+
+	redacted := []string{}
+	for _, attr := range attributes {
+		if redaction.Redacts(attr) {
+			redacted = append(redacted, attr)
+		}
+	}
+
+	if len(redacted) == 0 {
+		return true
+	}
+
+	conf, err := getConfig(c)
+	if err != nil {
+		log.Error("could not get config", zap.Error(err))
+		newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
+		return false
+	}
+
+	permitted, err := hasRole(c.Request.Context(), conf, cli, conf.Redaction.OverrideRoles)
+	if err != nil {
+		newLDAPError(c, err)
+		return false
+	}
+
+	fields := []zap.Field{
+		zap.String("username", cli.Config().BindUsername),
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+		zap.String("client", c.ClientIP()),
+		zap.Strings("attributes", redacted),
+	}
+
+	if !permitted {
+		audit.Warn("filter on redacted attributes denied", fields...)
+		newError(c, 403, fmt.Errorf("not permitted to filter on redacted attributes: %s", strings.Join(redacted, ", ")))
+		return false
+	}
+
+	audit.Info("filter on redacted attributes requested", fields...)
+	return true
+}
+
+// hasRole returns true if the user bound to the client has any of the given roles, either by username or by
+// being a direct member of one of the role's groups.
+func hasRole(ctx context.Context, conf *config.Config, cli *ldapcli.Client, roles []string) (bool, error) {
+	username := cli.Config().BindUsername
+	if len(username) == 0 {
+		return false, nil
+	}
+
+	// the user's groups are only looked up if a role is granted by group
+	var groups []string
+	lookedUp := false
+
+	for _, name := range roles {
+		role, ok := conf.Role(name)
+		if !ok {
+			continue
+		}
+
+		for _, u := range role.Users {
+			if strings.EqualFold(u, username) {
+				return true, nil
+			}
+		}
+
+		if len(role.Groups) == 0 {
+			continue
+		}
+
+		if !lookedUp {
+			var err error
+			if groups, err = userGroups(ctx, cli, username); err != nil {
+				return false, err
+			}
+			lookedUp = true
+		}
+
+		for _, g := range role.Groups {
+			for _, memberOf := range groups {
+				if strings.EqualFold(g, memberOf) {
+					return true, nil
+				}
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// userGroups returns the DNs of the groups the user with the given bind username is a direct member of.
+// The username may be a DN, a userPrincipalName, or a sAMAccountName.
+func userGroups(ctx context.Context, cli *ldapcli.Client, username string) ([]string, error) {
+	var req *ldap.SearchRequest
+
+	if strings.Contains(username, "=") {
+		req = cli.NewSearchRequest("(objectClass=*)", []string{ldapcli.AttributeMemberOf})
+		req.BaseDN = username
+		req.Scope = ldap.ScopeBaseObject
+	} else {
+		upn := ldapcli.CalculateUserPrincipalName(username, cli.Config().BaseDN)
+		filter := fmt.Sprintf("(|(%s=%s)(%s=%s))",
+			ldapcli.AttributeUserPrincipalName, ldap.EscapeFilter(upn),
+			ldapcli.AttributeSAMAccountName, ldap.EscapeFilter(username),
+		)
+		req = cli.NewSearchRequest(filter, []string{ldapcli.AttributeMemberOf})
+	}
+
+	resp, err := cli.SearchContext(ctx, req)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if len(resp.Entries) != 1 {
+		return nil, nil
+	}
+
+	return resp.Entries[0].GetAttributeValues(ldapcli.AttributeMemberOf), nil
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/deejross/direktor/internal/config"
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/deejross/direktor/pkg/ldapmockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedaction(t *testing.T) {
+	conf, err := config.Get()
+	require.NoError(t, err)
+
+	defer func(roles []config.Role, redaction config.Redaction) {
+		conf.Roles = roles
+		conf.Redaction = redaction
+	}(conf.Roles, conf.Redaction)
+
+	token := newToken(t)
+	path := "/v1/search?cn=tesla&attributes=cn,mail,userPassword&format=csv"
+
+	t.Run("Default", func(t *testing.T) {
+		w := newFormatRequest(t, path, token, "")
+		require.Equal(t, 200, w.Code)
+		require.Equal(t, "dn,cn,mail,userPassword\n\"cn=tesla,ou=scientists,dc=example,dc=com\",tesla,tesla@example.com,[REDACTED]\n", w.Body.String())
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		conf.Redaction = config.Redaction{Mask: []string{"ma*"}, Drop: []string{"userPassword"}}
+
+		w := newFormatRequest(t, "/v1/search?cn=tesla&attributes=cn,mail,userPassword&format=jsonl", token, "")
+		require.Equal(t, 200, w.Code)
+		require.Contains(t, w.Body.String(), `{"name":"mail","values":["[REDACTED]"]}`)
+		require.NotContains(t, w.Body.String(), "userPassword")
+	})
+
+	t.Run("DroppedColumn", func(t *testing.T) {
+		conf.Redaction = config.Redaction{Drop: []string{"userPassword"}}
+
+		w := newFormatRequest(t, path, token, "")
+		require.Equal(t, 200, w.Code)
+		require.Equal(t, "dn,cn,mail\n\"cn=tesla,ou=scientists,dc=example,dc=com\",tesla,tesla@example.com\n", w.Body.String())
+	})
+
+	t.Run("FilterNotPermitted", func(t *testing.T) {
+		conf.Redaction = config.Redaction{Drop: []string{"mail"}, OverrideRoles: []string{"helpdesk"}}
+		conf.Roles = []config.Role{{Name: "helpdesk", Users: []string{"someone-else"}}}
+
+		// masked and dropped attributes can't be tested by a filter, including inside other filters
+		for _, filter := range []string{"(userPassword=*)", "(&(cn=tesla)(!(userPassword;binary={SSHA}t*)))", "(mail=tesla@example.com)"} {
+			w := newFormatRequest(t, "/v1/search?format=csv&filter="+url.QueryEscape(filter), token, "")
+			require.Equal(t, 403, w.Code, filter)
+			require.Contains(t, w.Body.String(), "not permitted to filter on redacted attributes", filter)
+		}
+
+		w := newFormatRequest(t, "/v1/search?format=csv&filter="+url.QueryEscape("(cn=tesla"), token, "")
+		require.Equal(t, 400, w.Code)
+	})
+
+	t.Run("FilterPermitted", func(t *testing.T) {
+		conf.Redaction = config.Redaction{OverrideRoles: []string{"helpdesk"}}
+		conf.Roles = []config.Role{{Name: "helpdesk", Users: []string{ldapmockserver.TestBindDN}}}
+
+		// the values are still redacted unless requested otherwise
+		w := newFormatRequest(t, "/v1/search?format=csv&attributes=cn,userPassword&filter="+url.QueryEscape("(userPassword=*)"), token, "")
+		require.Equal(t, 200, w.Code)
+		require.NotContains(t, w.Body.String(), "{SSHA}")
+	})
+
+	t.Run("OverrideNotPermitted", func(t *testing.T) {
+		conf.Redaction = config.Redaction{OverrideRoles: []string{"helpdesk"}}
+		conf.Roles = []config.Role{{Name: "helpdesk", Users: []string{"someone-else"}}}
+
+		w := newFormatRequest(t, path+"&unredacted=true", token, "")
+		require.Equal(t, 403, w.Code)
+	})
+
+	t.Run("OverridePermitted", func(t *testing.T) {
+		conf.Redaction = config.Redaction{OverrideRoles: []string{"helpdesk"}}
+		conf.Roles = []config.Role{{Name: "helpdesk", Users: []string{ldapmockserver.TestBindDN}}}
+
+		w := newFormatRequest(t, path+"&unredacted=true", token, "")
+		require.Equal(t, 200, w.Code)
+		require.Contains(t, w.Body.String(), "{SSHA}tesla")
+	})
+}
+
+func TestUserGroups(t *testing.T) {
+	conf := ldapcli.NewConfig(ldapAddress, ldapmockserver.TestBaseDN)
+	conf.BindUsername = ldapmockserver.TestBindDN
+	conf.BindPassword = ldapmockserver.TestBindPW
+
+	cli, err := ldapcli.Dial(conf)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	groups, err := userGroups(context.Background(), cli, "tesla@example.com")
+	require.NoError(t, err)
+	require.Equal(t, []string{ldapmockserver.TestGroupDN}, groups)
+
+	groups, err = userGroups(context.Background(), cli, "cn=tesla,ou=scientists,dc=example,dc=com")
+	require.NoError(t, err)
+	require.Equal(t, []string{ldapmockserver.TestGroupDN}, groups)
+
+	groups, err = userGroups(context.Background(), cli, "nobody")
+	require.NoError(t, err)
+	require.Empty(t, groups)
+
+	roles := &config.Config{Roles: []config.Role{{Name: "physicists", Groups: []string{ldapmockserver.TestGroupDN}}}}
+	ok, err := hasRole(context.Background(), roles, cli, []string{"physicists"})
+	require.NoError(t, err)
+	require.False(t, ok, "the bind user is not a member of the group")
+}
@@ -20,14 +20,38 @@ const (
 	claimBaseDN          = "bdn"
 	claimPageSize        = "psz"
 	claimFollowReferrals = "fref"
+	claimAuthMode        = "amode"
 )
 
+// registeredClaims are set by the authtoken package and are not copied when refreshing a token.
+var registeredClaims = map[string]struct{}{
+	"iss": {},
+	"aud": {},
+	"nbf": {},
+	"iat": {},
+	"exp": {},
+	"jti": {},
+}
+
 func registerRoutes(router *gin.Engine) {
 	v1 := router.Group("/v1")
 
+	// domain endpoints
+	v1.GET("/domains", handleDomains)
+
 	// auth endpoints
 	v1.GET("/auth/token", handleAuthTokenCheck)
 	v1.POST("/auth/token", handleAuthToken)
+	v1.DELETE("/auth/token", handleAuthTokenRevoke)
+	v1.POST("/auth/refresh", handleAuthTokenRefresh)
+
+	// search endpoints
+	v1.GET("/search", handleSearch)
+	v1.POST("/search", handleSearch)
+
+	// directory endpoints
+	v1.GET("/groups/:dn/members", handleGroupMembers)
+	v1.GET("/ous/:dn/children", handleOrganizationalUnitChildren)
 }
 
 func newError(c *gin.Context, code int, err error) {
@@ -37,82 +61,220 @@ func newError(c *gin.Context, code int, err error) {
 	})
 }
 
-// ldapClient retrieves the requested LDAP client via the Authorization header.
+// newLDAPError sends back an error response with a status code appropriate for the given LDAP error.
+func newLDAPError(c *gin.Context, err error) {
+	if e, ok := err.(*ldap.Error); ok {
+		switch e.ResultCode {
+		case ldap.LDAPResultInvalidCredentials:
+			newError(c, 401, e)
+		case ldap.LDAPResultInsufficientAccessRights:
+			newError(c, 403, e)
+		case ldap.LDAPResultNoSuchObject:
+			newError(c, 404, e)
+		default:
+			newError(c, 400, e)
+		}
+		return
+	}
+
+	newError(c, 400, err)
+}
+
+// ldapClient retrieves the requested LDAP client via the Authorization header. Clients are taken from
+// the connection pool, and must be returned using `pool.put` once the request is finished.
 // Any errors encountered will be sent back as a JSON response and this function will return nil.
 func ldapClient(c *gin.Context) *ldapcli.Client {
+	token, ldapAddress, claims := requestClaims(c)
+	if claims == nil {
+		return nil
+	}
+
+	return dialClaims(c, token, ldapAddress, claims)
+}
+
+// requestClaims validates the token in the Authorization header against the X-Ldap-Address header and
+// returns the token, LDAP address, and the token's claims. Any errors encountered will be sent back as
+// a JSON response and the returned claims will be nil.
+func requestClaims(c *gin.Context) (string, string, authtoken.Claims) {
 	authHeader := c.GetHeader("Authorization")
 	if len(authHeader) == 0 {
 		newError(c, 401, fmt.Errorf("Authorization header required"))
-		return nil
+		return "", "", nil
 	}
 
 	if !strings.HasPrefix(authHeader, "Bearer ") {
 		newError(c, 400, fmt.Errorf("unknown Authorization method"))
-		return nil
+		return "", "", nil
 	}
 
 	ldapAddress := c.GetHeader("X-Ldap-Address")
 	if len(ldapAddress) == 0 {
 		newError(c, 400, fmt.Errorf("X-Ldap-Address header required"))
-		return nil
+		return "", "", nil
 	}
 
 	token := strings.TrimPrefix(authHeader, "Bearer ")
 
-	conf, err := config.Get()
+	conf, err := getConfig(c)
 	if err != nil {
 		log.Error("could not get config", zap.Error(err))
 		newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
-		return nil
+		return "", "", nil
+	}
+
+	if !allowTarget(c, conf, ldapAddress) {
+		return "", "", nil
+	}
+
+	keyring, err := conf.Keyring()
+	if err != nil {
+		log.Error("could not get signing keys", zap.Error(err))
+		newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
+		return "", "", nil
 	}
 
-	claims, err := authtoken.ValidateToken(conf.SecretKey, tokenIssuer, ldapAddress, token)
+	claims, err := keyring.ValidateToken(tokenIssuer, ldapAddress, token)
 	if err != nil {
 		newError(c, 401, err)
+		return "", "", nil
+	}
+
+	if id, ok := claims.String("jti"); ok {
+		revoked, err := revocations().IsRevoked(id)
+		if err != nil {
+			log.Error("could not check token revocation", zap.Error(err))
+			newError(c, 500, fmt.Errorf("could not check token revocation, please see server logs for more information"))
+			return "", "", nil
+		}
+
+		if revoked {
+			newError(c, 401, fmt.Errorf("token has been revoked"))
+			return "", "", nil
+		}
+	}
+
+	return token, ldapAddress, claims
+}
+
+// dialClaims retrieves an LDAP client from the connection pool using the configuration in the given claims.
+// Any errors encountered will be sent back as a JSON response and this function will return nil.
+func dialClaims(c *gin.Context, token, ldapAddress string, claims authtoken.Claims) *ldapcli.Client {
+	conf, err := getConfig(c)
+	if err != nil {
+		log.Error("could not get config", zap.Error(err))
+		newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
 		return nil
 	}
 
-	bindBaseDN, ok := claims[claimBaseDN]
+	bindBaseDN, ok := claims.String(claimBaseDN)
 	if !ok {
 		newError(c, 400, fmt.Errorf("token does not contain `%s` claim", claimBaseDN))
+		return nil
 	}
 
-	ldapConf := ldapcli.NewConfig(ldapAddress, bindBaseDN.(string))
+	ldapConf := ldapcli.NewConfig(ldapAddress, bindBaseDN)
 
-	if val, ok := claims[claimBindUsername]; ok {
-		ldapConf.BindUsername = val.(string)
+	if val, ok := claims.String(claimBindUsername); ok {
+		ldapConf.BindUsername = val
 	}
-	if val, ok := claims[claimBindPassword]; ok {
-		ldapConf.BindPassword = val.(string)
+	if val, ok := claims.String(claimBindPassword); ok {
+		ldapConf.BindPassword = val
 	}
-	if val, ok := claims[claimFollowReferrals]; ok {
-		ldapConf.FollowReferrals = val.(bool)
+	if val, ok := claims.Bool(claimFollowReferrals); ok {
+		ldapConf.FollowReferrals = val
 	}
-	if val, ok := claims[claimStartTLS]; ok {
-		ldapConf.StartTLS = val.(bool)
+	if val, ok := claims.Bool(claimStartTLS); ok {
+		ldapConf.StartTLS = val
 	}
-	if val, ok := claims[claimSkipVerify]; ok {
-		ldapConf.SkipVerify = val.(bool)
+	if val, ok := claims.Bool(claimSkipVerify); ok {
+		ldapConf.SkipVerify = val
 	}
-	if val, ok := claims[claimPageSize]; ok {
-		ldapConf.PageSize = val.(int)
+	if val, ok := claims.Int(claimPageSize); ok {
+		ldapConf.PageSize = val
 	}
+	if val, ok := claims.String(claimAuthMode); ok {
+		ldapConf.AuthMode = ldapcli.AuthMode(val)
+		ldapConf.Krb5ConfPath = conf.Krb5Conf
+	}
+	applyDomainTLS(ldapConf, conf)
+	applyFailover(ldapConf, conf)
+	applyTLS(ldapConf, conf)
+	ldapConf.AddressPolicy = targetPolicy(conf)
+	ldapConf.DisableCCache = true
 
-	cli, err := ldapcli.Dial(ldapConf)
-	if err != nil {
-		if e, ok := err.(*ldap.Error); ok {
-			if e.ResultCode == ldap.LDAPResultInvalidCredentials {
-				newError(c, 401, e)
-				return nil
-			}
-
-			newError(c, 400, e)
-			return nil
-		}
+	// Kerberos tickets are requested with the token's password, never with the server's own credential cache, and
+	// tokens issued before their domain switched to gssapi may not have one
+	if ldapConf.AuthMode == ldapcli.AuthGSSAPI && (len(ldapConf.BindUsername) == 0 || len(ldapConf.BindPassword) == 0) {
+		newError(c, 401, fmt.Errorf("token does not contain the username and password required for gssapi authentication"))
+		return nil
+	}
 
-		newError(c, 400, err)
+	pool.configure(conf.PoolMaxIdle, conf.PoolIdleTimeout)
+	cli, err := pool.get(poolKey(token, ldapAddress), func() (*ldapcli.Client, error) {
+		return ldapcli.DialContext(c.Request.Context(), ldapConf)
+	})
+	if err != nil {
+		newLDAPError(c, err)
 		return nil
 	}
 
+	// the server that answered may be a failover address of the token's domain
+	c.Header("X-Ldap-Server", cli.Address())
 	return cli
 }
+
+// applyDomainTLS enforces the StartTLS, certificate verification and authentication settings of the domain the LDAP
+// address belongs to, if any, so tokens can't weaken the connections the operator configured. Settings are looked up by
+// address, so they apply to tokens for any of the domain's addresses and follow changes to the config.
+func applyDomainTLS(ldapConf *ldapcli.Config, conf *config.Config) {
+	domain, ok := domainByAddress(conf, ldapConf.Address)
+	if !ok {
+		return
+	}
+
+	ldapConf.StartTLS = domain.StartTLS
+	ldapConf.SkipVerify = domain.SkipVerify
+
+	// the domain's mode was validated when the config was loaded
+	mode, _ := ldapcli.ParseAuthMode(domain.AuthMode)
+	ldapConf.AuthMode = mode
+	if mode == ldapcli.AuthGSSAPI {
+		ldapConf.Krb5ConfPath = conf.Krb5Conf
+	}
+}
+
+// applyFailover adds the other addresses of the domain the LDAP address belongs to, if any, so connections fail
+// over to them when the server at the address can't be reached. Tokens remain bound to the address they were
+// issued for, whichever server answers.
+func applyFailover(ldapConf *ldapcli.Config, conf *config.Config) {
+	domain, ok := domainByAddress(conf, ldapConf.Address)
+	if !ok {
+		return
+	}
+
+	// the requested address may be spelled differently than the domain's, so it isn't tried twice
+	ldapConf.Addresses = []string{}
+	for _, addr := range domain.Addresses {
+		if !sameTarget(addr, ldapConf.Address) {
+			ldapConf.Addresses = append(ldapConf.Addresses, addr)
+		}
+	}
+	ldapConf.RandomizeAddresses = domain.RandomizeAddresses
+	ldapConf.FailoverCooldown = domain.FailoverCooldown
+}
+
+// applyTLS applies the TLS settings for the LDAP address, from the domain it belongs to or the server defaults.
+func applyTLS(ldapConf *ldapcli.Config, conf *config.Config) {
+	domain, _ := domainByAddress(conf, ldapConf.Address)
+	t := conf.DomainTLS(domain)
+
+	ldapConf.CAFile = t.CAFile
+	ldapConf.CADir = t.CADir
+	ldapConf.ClientCertFile = t.CertFile
+	ldapConf.ClientKeyFile = t.KeyFile
+	ldapConf.TLSServerName = t.ServerName
+
+	// the version and policy were validated when the config was loaded
+	ldapConf.MinTLSVersion, _ = ldapcli.ParseTLSVersion(t.MinVersion)
+	ldapConf.CipherPolicy, _ = ldapcli.ParseCipherPolicy(t.CipherPolicy)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/gin-gonic/gin"
+)
+
+// SearchRequest object.
+type SearchRequest struct {
+	Filter     string   `json:"filter,omitempty" form:"filter"`
+	DN         string   `json:"dn,omitempty" form:"dn"`
+	CN         string   `json:"cn,omitempty" form:"cn"`
+	ByAttr     string   `json:"byAttr,omitempty" form:"byAttr"`
+	Attributes []string `json:"attributes,omitempty" form:"attributes"`
+	Scope      string   `json:"scope,omitempty" form:"scope"`
+	BaseDN     string   `json:"baseDN,omitempty" form:"baseDN"`
+}
+
+// Validate the request and return the LDAP filter to search with.
+func (r *SearchRequest) Validate() (string, error) {
+	filter, err := ldapcli.BuildFilter(r.DN, r.CN, r.ByAttr, r.Filter)
+	if err != nil {
+		return "", err
+	}
+
+	if len(filter) == 0 {
+		return "", fmt.Errorf("search requires one of: dn, cn, byAttr, filter")
+	}
+
+	if _, err := ldapcli.ParseScope(r.Scope); err != nil {
+		return "", err
+	}
+
+	if len(r.BaseDN) > 0 && !ldapcli.IsDNSanitized(r.BaseDN) {
+		return "", fmt.Errorf("baseDN contains invalid characters: %s", r.BaseDN)
+	}
+
+	return filter, nil
+}
+
+func handleSearch(c *gin.Context) {
+	req := &SearchRequest{}
+	if err := c.ShouldBind(req); err != nil {
+		newError(c, 400, err)
+		return
+	}
+
+	filter, err := req.Validate()
+	if err != nil {
+		newError(c, 400, err)
+		return
+	}
+
+	cli := ldapClient(c)
+	if cli == nil {
+		return
+	}
+	defer pool.put(cli)
+
+	redaction := redactionPolicy(c, cli)
+	if redaction == nil || !checkFilterRedaction(c, cli, redaction, filter) {
+		return
+	}
+
+	searchReq := cli.NewSearchRequest(filter, parseAttributes(req.Attributes))
+	searchReq.Scope, _ = ldapcli.ParseScope(req.Scope)
+	if len(req.BaseDN) > 0 {
+		searchReq.BaseDN = req.BaseDN
+	}
+
+	resp, err := cli.SearchContext(c.Request.Context(), searchReq)
+	if err != nil {
+		newLDAPError(c, err)
+		return
+	}
+
+	sendSearchResult(c, cli, resp, searchReq.Attributes, redaction)
+}
+
+// parseAttributes accepts attributes as repeated values, comma-separated values, or both.
+// If no attributes are given, the defaults used by the CLI are returned.
+func parseAttributes(attributes []string) []string {
+	attrs := []string{}
+	for _, a := range attributes {
+		for _, attr := range strings.Split(a, ",") {
+			if attr = strings.TrimSpace(attr); len(attr) > 0 {
+				attrs = append(attrs, attr)
+			}
+		}
+	}
+
+	if len(attrs) == 0 {
+		attrs = []string{ldapcli.AttributeCommonName, ldapcli.AttributeObjectClass}
+	}
+
+	return attrs
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/deejross/direktor/pkg/formatter"
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/deejross/direktor/pkg/ldapmockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch(t *testing.T) {
+	token := newToken(t)
+
+	t.Run("Filter", func(t *testing.T) {
+		req := SearchRequest{
+			Filter:     "(cn=*)",
+			Attributes: []string{ldapcli.AttributeCommonName, ldapcli.AttributeMail},
+		}
+
+		entries := []formatter.LDAPEntry{}
+		w, err := newRequest("POST", "/v1/search", token, ldapAddress, req, &entries)
+		require.NoError(t, err)
+		require.Equal(t, 200, w.StatusCode)
+		require.Len(t, entries, ldapmockserver.Size())
+		require.Len(t, entries[0].Attributes, 2)
+	})
+
+	t.Run("CN", func(t *testing.T) {
+		entries := []formatter.LDAPEntry{}
+		w, err := newRequest("GET", "/v1/search?cn=tesla&attributes=cn,mail", token, ldapAddress, nil, &entries)
+		require.NoError(t, err)
+		require.Equal(t, 200, w.StatusCode)
+		require.Len(t, entries, 1)
+		require.Equal(t, "cn=tesla,ou=scientists,dc=example,dc=com", entries[0].DistinguishedName)
+		require.Equal(t, ldapcli.AttributeMail, entries[0].Attributes[1].Name)
+		require.Equal(t, []string{"tesla@example.com"}, entries[0].Attributes[1].Values)
+	})
+
+	t.Run("ByAttr", func(t *testing.T) {
+		entries := []formatter.LDAPEntry{}
+		w, err := newRequest("GET", "/v1/search?byAttr=mail=newton@example.com", token, ldapAddress, nil, &entries)
+		require.NoError(t, err)
+		require.Equal(t, 200, w.StatusCode)
+		require.Len(t, entries, 1)
+		require.Equal(t, "cn=newton,ou=scientists,dc=example,dc=com", entries[0].DistinguishedName)
+	})
+
+	t.Run("MissingFilter", func(t *testing.T) {
+		w, err := newRequest("GET", "/v1/search", token, ldapAddress, nil, nil)
+		require.Error(t, err)
+		require.Equal(t, 400, w.StatusCode)
+	})
+
+	t.Run("InvalidScope", func(t *testing.T) {
+		w, err := newRequest("GET", "/v1/search?cn=tesla&scope=everything", token, ldapAddress, nil, nil)
+		require.Error(t, err)
+		require.Equal(t, 400, w.StatusCode)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		w, err := newRequest("GET", "/v1/search?cn=tesla", "", ldapAddress, nil, nil)
+		require.Error(t, err)
+		require.Equal(t, 401, w.StatusCode)
+	})
+}
@@ -1,9 +1,12 @@
 package server
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/deejross/direktor/internal/config"
+	"github.com/deejross/direktor/pkg/authtoken"
 	"github.com/deejross/direktor/pkg/logger"
 	"github.com/gin-gonic/contrib/static"
 	"github.com/gin-gonic/gin"
@@ -13,7 +16,26 @@ import (
 
 const tokenIssuer = "direktor"
 
-var log = logger.New("internal/server")
+var (
+	log                                        = logger.New("internal/server")
+	audit                                      = logger.New("audit")
+	revocationsMu                              = sync.RWMutex{}
+	revocationsStore authtoken.RevocationStore = authtoken.NewMemoryRevocationStore()
+)
+
+// SetRevocationStore replaces the default in-memory store used to keep track of revoked tokens.
+func SetRevocationStore(store authtoken.RevocationStore) {
+	revocationsMu.Lock()
+	defer revocationsMu.Unlock()
+	revocationsStore = store
+}
+
+// revocations returns the store used to keep track of revoked tokens.
+func revocations() authtoken.RevocationStore {
+	revocationsMu.RLock()
+	defer revocationsMu.RUnlock()
+	return revocationsStore
+}
 
 // Start the web server.
 func Start() error {
@@ -26,21 +48,28 @@ func Start() error {
 	// setup the router
 	router := setupRouter()
 
+	// periodically close expired and unhealthy pooled LDAP connections
+	go pool.run(time.Minute)
+
 	// start listening
 	log.Info("listening on port " + conf.ListenPort)
 	return router.Run(":" + conf.ListenPort)
 }
 
-func setupRouter() *gin.Engine {
+// setupRouter returns the router for the API, with the given middleware run before every endpoint.
+func setupRouter(middleware ...gin.HandlerFunc) *gin.Engine {
 	// configure router
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 	router.Use(loggingMiddleware())
 	router.Use(gin.Recovery())
+	router.Use(middleware...)
 	router.Use(static.Serve("/", static.LocalFile("./ui", true)))
 
 	// configure basic endpoints
 	router.GET("/health", routeHealth)
+	router.GET("/metrics", routeMetrics)
+	router.GET("/.well-known/jwks.json", routeJWKS)
 
 	// register other endpoints
 	registerRoutes(router)
@@ -48,10 +77,64 @@ func setupRouter() *gin.Engine {
 	return router
 }
 
+// configKey is the key of the config set by configMiddleware in the request context.
+const configKey = "config"
+
+// configMiddleware serves requests with the given config instead of the current configuration.
+func configMiddleware(conf *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(configKey, conf)
+	}
+}
+
+// getConfig returns the config for the request, which is the current configuration unless the router was set up
+// with configMiddleware.
+func getConfig(c *gin.Context) (*config.Config, error) {
+	if conf, ok := c.Get(configKey); ok {
+		return conf.(*config.Config), nil
+	}
+	return config.Get()
+}
+
 func routeHealth(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "OK"})
 }
 
+// routeMetrics serves the pool metrics if enabled in the config, since they are not authenticated.
+func routeMetrics(c *gin.Context) {
+	conf, err := getConfig(c)
+	if err != nil {
+		log.Error("could not get config", zap.Error(err))
+		newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
+		return
+	}
+
+	if !conf.EnableMetrics {
+		newError(c, 404, fmt.Errorf("metrics are not enabled"))
+		return
+	}
+
+	c.JSON(200, gin.H{"pool": pool.stats()})
+}
+
+func routeJWKS(c *gin.Context) {
+	conf, err := getConfig(c)
+	if err != nil {
+		log.Error("could not get config", zap.Error(err))
+		newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
+		return
+	}
+
+	keyring, err := conf.Keyring()
+	if err != nil {
+		log.Error("could not get signing keys", zap.Error(err))
+		newError(c, 500, fmt.Errorf("configuration error, please see server logs for more information"))
+		return
+	}
+
+	c.JSON(200, keyring.JWKS())
+}
+
 func loggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// start timer
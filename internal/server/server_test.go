@@ -8,15 +8,18 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/deejross/direktor/internal/config"
 	"github.com/deejross/direktor/pkg/ldapmockserver"
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
 )
 
 const (
 	ldapAddress   = "ldap://127.0.0.1:10389"
 	testSecretKey = "super-secret-test-key"
+	testDomain    = "example"
 )
 
 var (
@@ -31,9 +34,7 @@ func TestMain(m *testing.M) {
 	}
 
 	// setup the server config
-	config.Set(&config.Config{
-		SecretKey: testSecretKey,
-	})
+	config.Set(newTestConfig())
 
 	// setup the API server
 	router = setupRouter()
@@ -46,7 +47,34 @@ func TestMain(m *testing.M) {
 	os.Exit(code)
 }
 
+// newTestConfig returns a new server config for the tests, with the given domains in addition to the test domain.
+func newTestConfig(domains ...config.Domain) *config.Config {
+	return &config.Config{
+		SecretKey: testSecretKey,
+		TokenTTL:  time.Hour,
+		Domains: append([]config.Domain{
+			{
+				Name:      testDomain,
+				Addresses: []string{ldapAddress},
+				BaseDN:    ldapmockserver.TestBaseDN,
+				PageSize:  100,
+			},
+		}, domains...),
+	}
+}
+
+// newConfigRouter returns a router that serves requests with the given config instead of the global config, so
+// tests can change settings without affecting other tests.
+func newConfigRouter(conf *config.Config) *gin.Engine {
+	return setupRouter(configMiddleware(conf))
+}
+
 func newRequest(method, path, token, ldapAddress string, body interface{}, v interface{}) (*http.Response, error) {
+	return newRouterRequest(router, method, path, token, ldapAddress, body, v)
+}
+
+// newRouterRequest is the same as newRequest, but serves the request with the given router.
+func newRouterRequest(r *gin.Engine, method, path, token, ldapAddress string, body interface{}, v interface{}) (*http.Response, error) {
 	var req *http.Request
 	var err error
 	var bodyBuf *bytes.Buffer
@@ -78,7 +106,7 @@ func newRequest(method, path, token, ldapAddress string, body interface{}, v int
 		req.Header.Set("X-Ldap-Address", ldapAddress)
 	}
 
-	router.ServeHTTP(w, req)
+	r.ServeHTTP(w, req)
 	resp := w.Result()
 	defer resp.Body.Close()
 
@@ -101,3 +129,20 @@ func newRequest(method, path, token, ldapAddress string, body interface{}, v int
 
 	return resp, nil
 }
+
+func newToken(t *testing.T) string {
+	req := AuthTokenRequest{
+		Address:  ldapAddress,
+		BaseDN:   ldapmockserver.TestBaseDN,
+		Username: ldapmockserver.TestBindDN,
+		Password: ldapmockserver.TestBindPW,
+	}
+
+	resp := &AuthTokenResponse{}
+	w, err := newRequest("POST", "/v1/auth/token", "", "", req, resp)
+	require.NoError(t, err)
+	require.Equal(t, 200, w.StatusCode)
+	require.NotEmpty(t, resp.Token)
+
+	return resp.Token
+}
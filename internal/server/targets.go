@@ -0,0 +1,233 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/deejross/direktor/internal/config"
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// lookupIP resolves host names for CIDR targets, replaced in tests.
+var lookupIP = net.LookupIP
+
+// ldapTarget is a parsed LDAP address.
+type ldapTarget struct {
+	scheme string
+	host   string
+	port   string
+}
+
+// parseTarget parses the given LDAP address, filling in the default port for the scheme if not given.
+func parseTarget(address string) (*ldapTarget, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP address: %s", address)
+	}
+
+	t := &ldapTarget{
+		scheme: strings.ToLower(u.Scheme),
+		host:   strings.ToLower(u.Hostname()),
+		port:   u.Port(),
+	}
+
+	switch t.scheme {
+	case "ldap":
+		if len(t.port) == 0 {
+			t.port = "389"
+		}
+	case "ldaps":
+		if len(t.port) == 0 {
+			t.port = "636"
+		}
+	default:
+		return nil, fmt.Errorf("invalid LDAP address: %s: scheme must be ldap or ldaps", address)
+	}
+
+	if len(t.host) == 0 {
+		return nil, fmt.Errorf("invalid LDAP address: %s: host is required", address)
+	}
+
+	return t, nil
+}
+
+// sameTarget determines if the given LDAP addresses are the same server, ignoring the case of the scheme and host
+// and whether the default port for the scheme is given.
+func sameTarget(a, b string) bool {
+	ta, err := parseTarget(a)
+	if err != nil {
+		return false
+	}
+	tb, err := parseTarget(b)
+	if err != nil {
+		return false
+	}
+	return *ta == *tb
+}
+
+// domainByAddress returns the domain the given LDAP server address belongs to. Addresses are compared as parsed
+// targets, so differently spelled addresses of a domain's server still belong to the domain.
+func domainByAddress(conf *config.Config, address string) (*config.Domain, bool) {
+	for i := range conf.Domains {
+		for _, addr := range conf.Domains[i].Addresses {
+			if sameTarget(addr, address) {
+				return &conf.Domains[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// checkTarget returns an error if the given LDAP address is not the address of a configured domain,
+// and does not match any of the configured allowed targets. If the address is a host name only allowed by CIDR
+// targets, the returned function checks that each IP address it is dialed on is within one of the networks.
+func checkTarget(conf *config.Config, address string) (func(ip net.IP) error, error) {
+	target, err := parseTarget(address)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := domainByAddress(conf, address); ok {
+		return nil, nil
+	}
+
+	networks := []string{}
+	for _, allowed := range conf.AllowedTargets {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if strings.Contains(allowed, "/") {
+			networks = append(networks, allowed)
+		} else if target.matches(allowed) {
+			return nil, nil
+		}
+	}
+
+	for _, network := range networks {
+		if target.matches(network) {
+			if net.ParseIP(target.host) != nil {
+				return nil, nil
+			}
+
+			// the host could resolve to a different address when dialed, so the dialed address is checked too
+			return func(ip net.IP) error {
+				return checkNetworks(networks, ip, address)
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("LDAP address is not allowed: %s", address)
+}
+
+// checkNetworks returns an error if the IP address the given LDAP address was dialed on is not within any of
+// the given CIDR networks.
+func checkNetworks(networks []string, ip net.IP, address string) error {
+	for _, cidr := range networks {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return nil
+		}
+	}
+	return fmt.Errorf("LDAP address is not allowed: %s: resolved to %s", address, ip)
+}
+
+// targetPolicy returns the policy that restricts the LDAP servers dialed for a token, including failover
+// addresses and referrals, to the allowed targets. Pooled clients outlive the request they were dialed for,
+// so rejections are written to the audit log without the request details.
+func targetPolicy(conf *config.Config) ldapcli.AddressPolicy {
+	reject := func(address string, err error) error {
+		audit.Warn("LDAP target rejected", zap.String("address", address), zap.Error(err))
+		return err
+	}
+
+	return func(address string) (func(ip net.IP) error, error) {
+		checkIP, err := checkTarget(conf, address)
+		if err != nil {
+			return nil, reject(address, err)
+		}
+		if checkIP == nil {
+			return nil, nil
+		}
+
+		return func(ip net.IP) error {
+			if err := checkIP(ip); err != nil {
+				return reject(address, err)
+			}
+			return nil
+		}, nil
+	}
+}
+
+// matches determines if the target matches the given allowed target entry.
+func (t *ldapTarget) matches(allowed string) bool {
+	allowed = strings.ToLower(strings.TrimSpace(allowed))
+
+	if allowed == "*" {
+		return true
+	}
+
+	if strings.Contains(allowed, "/") {
+		return t.inNetwork(allowed)
+	}
+
+	host := allowed
+	if h, port, err := net.SplitHostPort(allowed); err == nil {
+		if port != t.port {
+			return false
+		}
+		host = h
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.Equal(net.ParseIP(t.host))
+	}
+
+	if strings.HasPrefix(host, "*.") {
+		return strings.HasSuffix(t.host, host[1:])
+	}
+
+	return host == t.host
+}
+
+// inNetwork determines if the target's host resolves only to IP addresses within the given CIDR.
+func (t *ldapTarget) inNetwork(cidr string) bool {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+
+	ips := []net.IP{net.ParseIP(t.host)}
+	if ips[0] == nil {
+		if ips, err = lookupIP(t.host); err != nil || len(ips) == 0 {
+			return false
+		}
+	}
+
+	for _, ip := range ips {
+		if !network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowTarget checks the given LDAP address against the configuration. If it is not allowed, the rejection is
+// written to the audit log, a 403 response is sent back, and this function will return false.
+func allowTarget(c *gin.Context, conf *config.Config, address string) bool {
+	_, err := checkTarget(conf, address)
+	if err == nil {
+		return true
+	}
+
+	audit.Warn("LDAP target rejected",
+		zap.String("address", address),
+		zap.String("method", c.Request.Method),
+		zap.String("path", c.Request.URL.Path),
+		zap.String("client", c.ClientIP()),
+		zap.Error(err),
+	)
+
+	newError(c, 403, err)
+	return false
+}
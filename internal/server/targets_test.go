@@ -0,0 +1,174 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/deejross/direktor/internal/config"
+	"github.com/deejross/direktor/pkg/authtoken"
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/deejross/direktor/pkg/ldapmockserver"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckTarget(t *testing.T) {
+	origLookupIP := lookupIP
+	defer func() { lookupIP = origLookupIP }()
+
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "internal.example.com":
+			return []net.IP{net.ParseIP("10.1.2.3")}, nil
+		case "mixed.example.com":
+			return []net.IP{net.ParseIP("10.1.2.4"), net.ParseIP("192.168.1.1")}, nil
+		}
+		return nil, fmt.Errorf("no such host: %s", host)
+	}
+
+	conf := &config.Config{
+		Domains: []config.Domain{
+			{Name: "example", Addresses: []string{"ldaps://DC1.example.com"}, BaseDN: "dc=example,dc=com"},
+		},
+		AllowedTargets: []string{"*.corp.example.com", "dc2.example.com:3269", "172.16.0.5", "10.0.0.0/8"},
+	}
+
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"ldaps://dc1.example.com:636", true},
+		{"ldaps://dc1.example.com:3269", false},
+		{"ldap://dc1.example.com", false},
+		{"ldap://ad.corp.example.com", true},
+		{"ldap://corp.example.com", false},
+		{"ldaps://dc2.example.com:3269", true},
+		{"ldaps://dc2.example.com", false},
+		{"ldap://172.16.0.5:10389", true},
+		{"ldap://172.16.0.6", false},
+		{"ldap://10.20.30.40", true},
+		{"ldap://internal.example.com", true},
+		{"ldap://mixed.example.com", false},
+		{"ldap://unresolvable.example.com", false},
+		{"ldap://127.0.0.1:10389", false},
+		{"http://dc1.example.com", false},
+		{"dc1.example.com", false},
+	}
+
+	for _, test := range tests {
+		_, err := checkTarget(conf, test.address)
+		if test.allowed {
+			require.NoError(t, err, test.address)
+		} else {
+			require.Error(t, err, test.address)
+		}
+	}
+
+	// host names allowed by a CIDR target are checked again against the address they are dialed on, so a host
+	// can't pass the check and then resolve to another address
+	checkIP, err := checkTarget(conf, "ldap://internal.example.com")
+	require.NoError(t, err)
+	require.NotNil(t, checkIP)
+	require.NoError(t, checkIP(net.ParseIP("10.1.2.3")))
+	require.Error(t, checkIP(net.ParseIP("127.0.0.1")))
+
+	checkIP, err = checkTarget(conf, "ldap://10.20.30.40")
+	require.NoError(t, err)
+	require.Nil(t, checkIP)
+
+	conf.AllowedTargets = []string{"*"}
+	checkIP, err = checkTarget(conf, "ldap://127.0.0.1:10389")
+	require.NoError(t, err)
+	require.Nil(t, checkIP)
+}
+
+func TestDomainByAddress(t *testing.T) {
+	conf := &config.Config{
+		Domains: []config.Domain{{
+			Name:      "example",
+			Addresses: []string{"ldaps://dc1.example.com", "ldaps://dc2.example.com"},
+			BaseDN:    "dc=example,dc=com",
+			AuthMode:  "gssapi",
+		}},
+	}
+
+	// addresses are compared the same way as allowed targets
+	for _, address := range []string{"ldaps://dc1.example.com", "ldaps://DC1.example.com", "LDAPS://dc1.example.com:636"} {
+		domain, ok := domainByAddress(conf, address)
+		require.True(t, ok, address)
+		require.Equal(t, "example", domain.Name)
+	}
+
+	for _, address := range []string{"ldap://dc1.example.com", "ldaps://dc1.example.com:3269", "dc1.example.com"} {
+		_, ok := domainByAddress(conf, address)
+		require.False(t, ok, address)
+	}
+
+	// so the domain's settings apply to a differently spelled address, which isn't tried again on failover
+	ldapConf := ldapcli.NewConfig("ldaps://DC1.example.com:636", "dc=example,dc=com")
+	ldapConf.SkipVerify = true
+	applyDomainTLS(ldapConf, conf)
+	applyFailover(ldapConf, conf)
+	require.False(t, ldapConf.SkipVerify)
+	require.Equal(t, ldapcli.AuthGSSAPI, ldapConf.AuthMode)
+	require.Equal(t, []string{"ldaps://dc2.example.com"}, ldapConf.Addresses)
+}
+
+func TestTargetPolicy(t *testing.T) {
+	origLookupIP := lookupIP
+	defer func() { lookupIP = origLookupIP }()
+
+	// the host resolves to an allowed address when checked, and to the mock server when dialed
+	lookupIP = func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("10.1.2.3")}, nil
+	}
+
+	conf := &config.Config{AllowedTargets: []string{"10.0.0.0/8"}}
+
+	ldapConf := ldapcli.NewConfig("ldap://localhost:10389", ldapmockserver.TestBaseDN)
+	ldapConf.BindUsername = ldapmockserver.TestBindDN
+	ldapConf.BindPassword = ldapmockserver.TestBindPW
+	ldapConf.AddressPolicy = targetPolicy(conf)
+
+	_, err := ldapcli.Dial(ldapConf)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "LDAP address is not allowed")
+
+	// addresses that aren't allowed at all, such as referrals to other servers, are never dialed
+	ldapConf.Address = "ldap://192.0.2.1:389"
+	_, err = ldapcli.Dial(ldapConf)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "LDAP address is not allowed")
+
+	conf.AllowedTargets = append(conf.AllowedTargets, "localhost")
+	ldapConf.Address = "ldap://localhost:10389"
+	cli, err := ldapcli.Dial(ldapConf)
+	require.NoError(t, err)
+	cli.Close()
+}
+
+func TestTargetNotAllowed(t *testing.T) {
+	address := "ldap://192.0.2.1:389"
+
+	req := AuthTokenRequest{
+		Address:  address,
+		BaseDN:   ldapmockserver.TestBaseDN,
+		Username: ldapmockserver.TestBindDN,
+		Password: ldapmockserver.TestBindPW,
+	}
+
+	w, err := newRequest("POST", "/v1/auth/token", "", "", req, nil)
+	require.Error(t, err)
+	require.Equal(t, 403, w.StatusCode)
+
+	// tokens signed for a target that is not allowed are rejected as well
+	token, err := authtoken.SignToken(testSecretKey, tokenIssuer, address, time.Hour, map[string]interface{}{
+		claimBaseDN: ldapmockserver.TestBaseDN,
+	}, nil)
+	require.NoError(t, err)
+
+	w, err = newRequest("GET", "/v1/auth/token", token, address, nil, nil)
+	require.Error(t, err)
+	require.Equal(t, 403, w.StatusCode)
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -14,7 +15,7 @@ const (
 )
 
 func TestToken(t *testing.T) {
-	token, err := SignToken(testKey, testIssuer, testAudience, map[string]interface{}{
+	token, err := SignToken(testKey, testIssuer, testAudience, 0, map[string]interface{}{
 		"iam": "me",
 	}, map[string]interface{}{
 		"pw": "super-secret-password",
@@ -30,13 +31,129 @@ func TestToken(t *testing.T) {
 	require.Equal(t, "me", claims["iam"].(string))
 	require.Equal(t, testIssuer, claims["iss"].(string))
 	require.Equal(t, "super-secret-password", claims["pw"].(string))
-	require.Equal(t, "3.14e+00", claims["pi"].(string))
-	require.Equal(t, "42", claims["hg"].(string))
-	require.Equal(t, "some-bytes", claims["bs"].(string))
+	require.Equal(t, 3.14, claims["pi"].(float64))
+	require.Equal(t, 42, claims["hg"].(int))
+	require.Equal(t, []byte("some-bytes"), claims["bs"].([]byte))
+}
+
+func TestClaimTypes(t *testing.T) {
+	values := map[string]interface{}{
+		"bool":   true,
+		"int":    42,
+		"int64":  int64(1) << 40,
+		"float":  2.5,
+		"whole":  float64(7),
+		"string": "hello",
+		"bytes":  []byte{0, 1, 2, 255},
+		"null":   nil,
+		"map": map[string]interface{}{
+			"int":   3,
+			"bytes": []byte("nested"),
+			"inner": map[string]interface{}{
+				"bool":  false,
+				"float": 0.5,
+			},
+		},
+	}
+
+	token, err := SignToken(testKey, testIssuer, testAudience, 0, values, values)
+	require.NoError(t, err)
+
+	plain, err := ValidateToken(testKey, testIssuer, testAudience, token)
+	require.NoError(t, err)
+	require.NotContains(t, plain, claimTypesClaim)
+
+	for name, expected := range values {
+		require.Equal(t, expected, plain[name], "plain claim: %s", name)
+	}
+
+	token, err = SignToken(testKey, testIssuer, testAudience, 0, nil, values)
+	require.NoError(t, err)
+
+	encrypted, err := ValidateToken(testKey, testIssuer, testAudience, token)
+	require.NoError(t, err)
+
+	for name, expected := range values {
+		require.Equal(t, expected, encrypted[name], "encrypted claim: %s", name)
+	}
+}
+
+func TestClaimAccessors(t *testing.T) {
+	claims := Claims{
+		"bool":   true,
+		"int":    42,
+		"int64":  int64(43),
+		"float":  2.5,
+		"whole":  float64(7),
+		"string": "hello",
+		"bytes":  []byte("bytes"),
+		"map":    map[string]interface{}{"a": 1},
+	}
+
+	b, ok := claims.Bool("bool")
+	require.True(t, ok)
+	require.True(t, b)
+	_, ok = claims.Bool("string")
+	require.False(t, ok)
+
+	i, ok := claims.Int("int")
+	require.True(t, ok)
+	require.Equal(t, 42, i)
+	i, ok = claims.Int("int64")
+	require.True(t, ok)
+	require.Equal(t, 43, i)
+	i, ok = claims.Int("whole")
+	require.True(t, ok)
+	require.Equal(t, 7, i)
+	_, ok = claims.Int("float")
+	require.False(t, ok)
+	_, ok = claims.Int("missing")
+	require.False(t, ok)
+
+	f, ok := claims.Float("float")
+	require.True(t, ok)
+	require.Equal(t, 2.5, f)
+	f, ok = claims.Float("int")
+	require.True(t, ok)
+	require.Equal(t, 42.0, f)
+
+	s, ok := claims.String("string")
+	require.True(t, ok)
+	require.Equal(t, "hello", s)
+	_, ok = claims.String("bytes")
+	require.False(t, ok)
+
+	bs, ok := claims.Bytes("bytes")
+	require.True(t, ok)
+	require.Equal(t, []byte("bytes"), bs)
+
+	m, ok := claims.Map("map")
+	require.True(t, ok)
+	require.Equal(t, 1, m["a"])
+}
+
+func TestReservedClaimTypesClaim(t *testing.T) {
+	token, err := SignToken(testKey, testIssuer, testAudience, 0, map[string]interface{}{
+		claimTypesClaim: "should-fail",
+	}, nil)
+
+	require.Error(t, err)
+	require.Empty(t, token)
+}
+
+func TestLegacyEncryptedClaim(t *testing.T) {
+	// claims encrypted before types were preserved are returned as strings
+	v, err := decodeTypedValue([]byte("42"))
+	require.NoError(t, err)
+	require.Equal(t, "42", v)
+
+	v, err = decodeTypedValue([]byte("some-bytes"))
+	require.NoError(t, err)
+	require.Equal(t, "some-bytes", v)
 }
 
 func TestPlainClaimWithEncPrefix(t *testing.T) {
-	token, err := SignToken(testKey, testIssuer, testAudience, map[string]interface{}{
+	token, err := SignToken(testKey, testIssuer, testAudience, 0, map[string]interface{}{
 		encryptedClaimPrefix + "iam": "should-fail",
 	}, map[string]interface{}{
 		"pw": "super-secret-password",
@@ -47,7 +164,7 @@ func TestPlainClaimWithEncPrefix(t *testing.T) {
 }
 
 func TestUnsupportedType(t *testing.T) {
-	token, err := SignToken(testKey, testIssuer, testAudience, map[string]interface{}{
+	token, err := SignToken(testKey, testIssuer, testAudience, 0, map[string]interface{}{
 		"iam": "me",
 	}, map[string]interface{}{
 		"ohno": uint8(4),
@@ -58,7 +175,7 @@ func TestUnsupportedType(t *testing.T) {
 }
 
 func TestInvalidIssuer(t *testing.T) {
-	token, err := SignToken(testKey, testIssuer, testAudience, map[string]interface{}{
+	token, err := SignToken(testKey, testIssuer, testAudience, 0, map[string]interface{}{
 		"iam": "me",
 	}, map[string]interface{}{
 		"pw": "super-secret-password",
@@ -75,7 +192,7 @@ func TestInvalidIssuer(t *testing.T) {
 }
 
 func TestInvalidAudience(t *testing.T) {
-	token, err := SignToken(testKey, testIssuer, testAudience, map[string]interface{}{
+	token, err := SignToken(testKey, testIssuer, testAudience, 0, map[string]interface{}{
 		"iam": "me",
 	}, map[string]interface{}{
 		"pw": "super-secret-password",
@@ -91,20 +208,117 @@ func TestInvalidAudience(t *testing.T) {
 	require.Empty(t, claims)
 }
 
+// signTestClaims signs the given claims as they are, bypassing the checks of SignToken.
+func signTestClaims(t *testing.T, claims jwt.MapClaims) string {
+	keyring, err := NewKeyring("", Key{Secret: testKey})
+	require.NoError(t, err)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(keyring.keys[""].signingKey())
+	require.NoError(t, err)
+	return token
+}
+
 func TestInvalidNBF(t *testing.T) {
-	token, err := SignToken(testKey, testIssuer, testAudience, map[string]interface{}{
-		"iam": "me",
+	token := signTestClaims(t, jwt.MapClaims{
+		"iss": testIssuer,
+		"aud": testAudience,
 		"nbf": time.Now().Unix() + 1000,
-	}, map[string]interface{}{
-		"pw": "super-secret-password",
-		"pi": 3.14,
-		"hg": 42,
-		"bs": []byte("some-bytes"),
+		"iam": "me",
 	})
 
+	claims, err := ValidateToken(testKey, testIssuer, testAudience, token)
+	require.Error(t, err)
+	require.Empty(t, claims)
+
+	// claims of the wrong type are rejected rather than trusted
+	for name, value := range map[string]interface{}{"iss": 42, "aud": 42, "nbf": "yesterday"} {
+		tokenClaims := jwt.MapClaims{
+			"iss": testIssuer,
+			"aud": testAudience,
+			"nbf": time.Now().Unix(),
+		}
+		tokenClaims[name] = value
+
+		claims, err = ValidateToken(testKey, testIssuer, testAudience, signTestClaims(t, tokenClaims))
+		require.Error(t, err, name)
+		require.Empty(t, claims, name)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	token, err := SignToken(testKey, testIssuer, testAudience, time.Hour, map[string]interface{}{
+		"iam": "me",
+	}, nil)
+
 	require.NoError(t, err)
 
 	claims, err := ValidateToken(testKey, testIssuer, testAudience, token)
+	require.NoError(t, err)
+	require.NotEmpty(t, claims["jti"])
+	require.InDelta(t, time.Now().Unix(), claims["iat"], 5)
+	require.InDelta(t, time.Now().Add(time.Hour).Unix(), claims["exp"], 5)
+
+	// the token is rejected once it expires
+	jwt.TimeFunc = func() time.Time {
+		return time.Now().Add(2 * time.Hour)
+	}
+	defer func() {
+		jwt.TimeFunc = time.Now
+	}()
+
+	claims, err = ValidateToken(testKey, testIssuer, testAudience, token)
 	require.Error(t, err)
 	require.Empty(t, claims)
 }
+
+func TestReservedGeneratedClaims(t *testing.T) {
+	for _, name := range []string{"iss", "aud", "exp", "iat", "nbf", "jti"} {
+		token, err := SignToken(testKey, testIssuer, testAudience, time.Hour, map[string]interface{}{
+			name: time.Now().Add(24 * time.Hour).Unix(),
+		}, nil)
+
+		require.Error(t, err, name)
+		require.Empty(t, token)
+	}
+}
+
+func TestUniqueID(t *testing.T) {
+	token1, err := SignToken(testKey, testIssuer, testAudience, 0, nil, nil)
+	require.NoError(t, err)
+	token2, err := SignToken(testKey, testIssuer, testAudience, 0, nil, nil)
+	require.NoError(t, err)
+
+	claims1, err := ValidateToken(testKey, testIssuer, testAudience, token1)
+	require.NoError(t, err)
+	claims2, err := ValidateToken(testKey, testIssuer, testAudience, token2)
+	require.NoError(t, err)
+
+	require.NotEqual(t, claims1["jti"], claims2["jti"])
+	require.NotContains(t, claims1, "exp")
+}
+
+func TestMemoryRevocationStore(t *testing.T) {
+	store := NewMemoryRevocationStore()
+
+	revoked, err := store.IsRevoked("abc")
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	require.NoError(t, store.Revoke("abc", time.Now().Add(time.Hour)))
+	require.NoError(t, store.Revoke("forever", time.Time{}))
+	require.NoError(t, store.Revoke("expired", time.Now().Add(-time.Hour)))
+
+	revoked, err = store.IsRevoked("abc")
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	revoked, err = store.IsRevoked("forever")
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	// expired revocations are purged on the next call to Revoke
+	require.NoError(t, store.Revoke("def", time.Now().Add(time.Hour)))
+	revoked, err = store.IsRevoked("expired")
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
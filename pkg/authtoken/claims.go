@@ -0,0 +1,244 @@
+package authtoken
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+const (
+	// claimTypesClaim is a reserved claim that records the original types of plain claims
+	// whose type can't be determined from JSON alone, such as int and []byte.
+	claimTypesClaim = "ctyp"
+
+	typeBool   = "bool"
+	typeInt    = "int"
+	typeInt64  = "int64"
+	typeFloat  = "float"
+	typeString = "string"
+	typeBytes  = "bytes"
+	typeNull   = "null"
+)
+
+// Claims from a validated token. Claims keep the type they were signed with, which can be one of:
+// bool, int, int64, float64, string, []byte, nil, or map[string]interface{} containing any of these types.
+type Claims map[string]interface{}
+
+// String returns the named claim if it exists and is a string.
+func (c Claims) String(name string) (string, bool) {
+	v, ok := c[name].(string)
+	return v, ok
+}
+
+// Bool returns the named claim if it exists and is a bool.
+func (c Claims) Bool(name string) (bool, bool) {
+	v, ok := c[name].(bool)
+	return v, ok
+}
+
+// Int returns the named claim if it exists and is a whole number.
+func (c Claims) Int(name string) (int, bool) {
+	switch v := c[name].(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		// tokens signed without type information return numbers as float64
+		if v == math.Trunc(v) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// Float returns the named claim if it exists and is a number.
+func (c Claims) Float(name string) (float64, bool) {
+	switch v := c[name].(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// Bytes returns the named claim if it exists and is a []byte.
+func (c Claims) Bytes(name string) ([]byte, bool) {
+	v, ok := c[name].([]byte)
+	return v, ok
+}
+
+// Map returns the named claim if it exists and is a map.
+func (c Claims) Map(name string) (map[string]interface{}, bool) {
+	v, ok := c[name].(map[string]interface{})
+	return v, ok
+}
+
+// typedValue is the envelope used to encrypt claims with their type.
+type typedValue struct {
+	Type  interface{}     `json:"t"`
+	Value json.RawMessage `json:"v"`
+}
+
+// claimType returns the type descriptor of the given value. Scalar types are described by name,
+// while maps are described by a map of their keys to type descriptors.
+func claimType(iv interface{}) (interface{}, error) {
+	switch v := iv.(type) {
+	case nil:
+		return typeNull, nil
+	case bool:
+		return typeBool, nil
+	case int:
+		return typeInt, nil
+	case int64:
+		return typeInt64, nil
+	case float64:
+		return typeFloat, nil
+	case string:
+		return typeString, nil
+	case []byte:
+		return typeBytes, nil
+	case map[string]interface{}:
+		types := map[string]interface{}{}
+		for k, child := range v {
+			t, err := claimType(child)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+			types[k] = t
+		}
+		return types, nil
+	}
+
+	return nil, fmt.Errorf("unsupported type: %T", iv)
+}
+
+// isNativeJSONType determines if the given type descriptor round-trips through JSON without type information.
+func isNativeJSONType(t interface{}) bool {
+	switch t {
+	case typeNull, typeBool, typeFloat, typeString:
+		return true
+	}
+	return false
+}
+
+// fromJSON converts a value decoded from JSON back into the type described by the given type descriptor.
+func fromJSON(iv interface{}, t interface{}) (interface{}, error) {
+	if types, ok := t.(map[string]interface{}); ok {
+		m, ok := iv.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected map, got %T", iv)
+		}
+
+		result := map[string]interface{}{}
+		for k, child := range m {
+			childType, ok := types[k]
+			if !ok {
+				result[k] = child
+				continue
+			}
+
+			v, err := fromJSON(child, childType)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+			result[k] = v
+		}
+		return result, nil
+	}
+
+	switch t {
+	case typeNull:
+		return nil, nil
+	case typeBool:
+		if v, ok := iv.(bool); ok {
+			return v, nil
+		}
+	case typeString:
+		if v, ok := iv.(string); ok {
+			return v, nil
+		}
+	case typeBytes:
+		if v, ok := iv.(string); ok {
+			return base64.StdEncoding.DecodeString(v)
+		}
+	case typeInt, typeInt64, typeFloat:
+		return numberFromJSON(iv, t.(string))
+	default:
+		return nil, fmt.Errorf("unknown type: %v", t)
+	}
+
+	return nil, fmt.Errorf("expected %v, got %T", t, iv)
+}
+
+// numberFromJSON converts a JSON number, either float64 or json.Number, into the given numeric type.
+func numberFromJSON(iv interface{}, t string) (interface{}, error) {
+	var n json.Number
+	switch v := iv.(type) {
+	case json.Number:
+		n = v
+	case float64:
+		if t == typeFloat {
+			return v, nil
+		}
+		if v != math.Trunc(v) {
+			return nil, fmt.Errorf("expected %s, got %v", t, v)
+		}
+		if t == typeInt {
+			return int(v), nil
+		}
+		return int64(v), nil
+	default:
+		return nil, fmt.Errorf("expected %s, got %T", t, iv)
+	}
+
+	switch t {
+	case typeFloat:
+		return n.Float64()
+	case typeInt:
+		i, err := n.Int64()
+		return int(i), err
+	default:
+		return n.Int64()
+	}
+}
+
+// encodeTypedValue returns the JSON envelope for the given value, including its type.
+func encodeTypedValue(v interface{}) ([]byte, error) {
+	t, err := claimType(v)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(&typedValue{Type: t, Value: b})
+}
+
+// decodeTypedValue decodes the JSON envelope created by encodeTypedValue. If the given bytes are not an
+// envelope, they are returned as a string, which is how claims were encrypted before types were preserved.
+func decodeTypedValue(b []byte) (interface{}, error) {
+	tv := &typedValue{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(tv); err != nil || tv.Type == nil || tv.Value == nil {
+		return string(b), nil
+	}
+
+	var v interface{}
+	dec = json.NewDecoder(bytes.NewReader(tv.Value))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return fromJSON(v, tv.Type)
+}
@@ -0,0 +1,55 @@
+package authtoken
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// SigningMethodEdDSA implements the EdDSA signing method using Ed25519 keys, which is not
+// provided by the jwt package.
+type SigningMethodEdDSA struct{}
+
+// signingMethodEdDSA is the registered instance of SigningMethodEdDSA.
+var signingMethodEdDSA = &SigningMethodEdDSA{}
+
+// Alg returns the name of the signing method.
+func (m *SigningMethodEdDSA) Alg() string {
+	return "EdDSA"
+}
+
+// Verify the signature of the signing string. The key must be an ed25519.PublicKey.
+func (m *SigningMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return fmt.Errorf("EdDSA verification failed")
+	}
+
+	return nil
+}
+
+// Sign the signing string and return the encoded signature. The key must be an ed25519.PrivateKey.
+func (m *SigningMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+func init() {
+	jwt.RegisterSigningMethod(signingMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return signingMethodEdDSA
+	})
+}
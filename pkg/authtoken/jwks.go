@@ -0,0 +1,152 @@
+package authtoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// JSONWebKey is the public part of an asymmetric signing key, as defined by RFC 7517.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is a set of JSONWebKey objects, as defined by RFC 7517.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys of all asymmetric keys in the keyring that have not been retired.
+// Keys using HS256 are never included since they can't be verified without the shared secret.
+func (k *Keyring) JWKS() *JSONWebKeySet {
+	set := &JSONWebKeySet{
+		Keys: []JSONWebKey{},
+	}
+
+	now := time.Now()
+	for _, key := range k.keys {
+		if key.Algorithm == AlgorithmHS256 || key.isRetired(now) {
+			continue
+		}
+
+		set.Keys = append(set.Keys, publicJSONWebKey(key.ID, key.Algorithm, key.PrivateKey.Public()))
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+
+	return set
+}
+
+// ParsePrivateKey parses a PEM encoded RSA, ECDSA, or Ed25519 private key in PKCS #1, SEC 1, or PKCS #8 form.
+func ParsePrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type: %T", key)
+		}
+
+		return signer, nil
+	}
+
+	return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+}
+
+func publicJSONWebKey(id, algorithm string, publicKey crypto.PublicKey) JSONWebKey {
+	jwk := JSONWebKey{
+		KeyID:     id,
+		Use:       "sig",
+		Algorithm: algorithm,
+	}
+
+	switch pub := publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeBase64URL(pub.N.Bytes())
+		jwk.E = encodeBase64URL(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = encodeBase64URL(padBytes(pub.X.Bytes(), size))
+		jwk.Y = encodeBase64URL(padBytes(pub.Y.Bytes(), size))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encodeBase64URL(pub)
+	}
+
+	return jwk
+}
+
+// checkPrivateKey determines if the given private key can be used with the given algorithm.
+func checkPrivateKey(algorithm string, key crypto.Signer) error {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if algorithm == AlgorithmRS256 {
+			return nil
+		}
+	case *ecdsa.PrivateKey:
+		if algorithm == AlgorithmES256 {
+			if k.Curve != elliptic.P256() {
+				return fmt.Errorf("%s requires a P-256 key", algorithm)
+			}
+			return nil
+		}
+	case ed25519.PrivateKey:
+		if algorithm == AlgorithmEdDSA {
+			return nil
+		}
+	case nil:
+		return fmt.Errorf("%s requires a private key", algorithm)
+	}
+
+	return fmt.Errorf("%s cannot be used with private key type: %T", algorithm, key)
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
@@ -0,0 +1,198 @@
+package authtoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeys(t *testing.T) map[string]crypto.Signer {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return map[string]crypto.Signer{
+		AlgorithmRS256: rsaKey,
+		AlgorithmES256: ecKey,
+		AlgorithmEdDSA: edKey,
+	}
+}
+
+func TestAsymmetricKeys(t *testing.T) {
+	for alg, privateKey := range newTestKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			keyring, err := NewKeyring("asym", Key{
+				ID:         "asym",
+				Algorithm:  alg,
+				Secret:     testKey,
+				PrivateKey: privateKey,
+			})
+			require.NoError(t, err)
+
+			token, err := keyring.SignToken(testIssuer, testAudience, 0, map[string]interface{}{
+				"iam": "me",
+			}, map[string]interface{}{
+				"pw": "super-secret-password",
+			})
+			require.NoError(t, err)
+
+			claims, err := keyring.ValidateToken(testIssuer, testAudience, token)
+			require.NoError(t, err)
+			require.Equal(t, "me", claims["iam"])
+			require.Equal(t, "super-secret-password", claims["pw"])
+
+			// the token can be validated by others using only the public key, but encrypted claims remain encrypted
+			parsed, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+				return privateKey.Public(), nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, alg, parsed.Method.Alg())
+			require.NotContains(t, parsed.Claims.(jwt.MapClaims), "pw")
+			require.NotEqual(t, "super-secret-password", parsed.Claims.(jwt.MapClaims)[encryptedClaimPrefix+"pw"])
+		})
+	}
+}
+
+func TestAlgorithmMismatch(t *testing.T) {
+	keys := newTestKeys(t)
+
+	// a token signed with HS256 using the shared secret must not be accepted for an asymmetric key
+	hsKeyring, err := NewKeyring("asym", Key{ID: "asym", Secret: testKey})
+	require.NoError(t, err)
+
+	token, err := hsKeyring.SignToken(testIssuer, testAudience, 0, nil, nil)
+	require.NoError(t, err)
+
+	keyring, err := NewKeyring("asym", Key{
+		ID:         "asym",
+		Algorithm:  AlgorithmRS256,
+		Secret:     testKey,
+		PrivateKey: keys[AlgorithmRS256],
+	})
+	require.NoError(t, err)
+
+	claims, err := keyring.ValidateToken(testIssuer, testAudience, token)
+	require.Error(t, err)
+	require.Empty(t, claims)
+}
+
+func TestAsymmetricKeysInvalid(t *testing.T) {
+	keys := newTestKeys(t)
+
+	_, err := NewKeyring("asym", Key{ID: "asym", Algorithm: AlgorithmRS256, Secret: testKey})
+	require.Error(t, err)
+
+	_, err = NewKeyring("asym", Key{ID: "asym", Algorithm: AlgorithmRS256, Secret: testKey, PrivateKey: keys[AlgorithmEdDSA]})
+	require.Error(t, err)
+
+	_, err = NewKeyring("", Key{Algorithm: AlgorithmEdDSA, Secret: testKey, PrivateKey: keys[AlgorithmEdDSA]})
+	require.Error(t, err)
+
+	_, err = NewKeyring("asym", Key{ID: "asym", Algorithm: "PS512", Secret: testKey})
+	require.Error(t, err)
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewKeyring("asym", Key{ID: "asym", Algorithm: AlgorithmES256, Secret: testKey, PrivateKey: p384Key})
+	require.Error(t, err)
+}
+
+func TestJWKS(t *testing.T) {
+	keys := newTestKeys(t)
+
+	keyring, err := NewKeyring("rsa",
+		Key{ID: "hmac", Secret: testKey},
+		Key{ID: "rsa", Algorithm: AlgorithmRS256, Secret: testKey, PrivateKey: keys[AlgorithmRS256]},
+		Key{ID: "ec", Algorithm: AlgorithmES256, Secret: testKey, PrivateKey: keys[AlgorithmES256]},
+		Key{ID: "ed", Algorithm: AlgorithmEdDSA, Secret: testKey, PrivateKey: keys[AlgorithmEdDSA]},
+	)
+	require.NoError(t, err)
+
+	set := keyring.JWKS()
+	require.Len(t, set.Keys, 3)
+
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		require.NoError(t, err)
+		return b
+	}
+
+	ec := set.Keys[0]
+	require.Equal(t, "ec", ec.KeyID)
+	require.Equal(t, "EC", ec.KeyType)
+	require.Equal(t, "P-256", ec.Curve)
+	ecPub := keys[AlgorithmES256].Public().(*ecdsa.PublicKey)
+	require.Equal(t, ecPub.X, new(big.Int).SetBytes(decode(ec.X)))
+	require.Equal(t, ecPub.Y, new(big.Int).SetBytes(decode(ec.Y)))
+
+	ed := set.Keys[1]
+	require.Equal(t, "ed", ed.KeyID)
+	require.Equal(t, "OKP", ed.KeyType)
+	require.Equal(t, "Ed25519", ed.Curve)
+	require.Equal(t, []byte(keys[AlgorithmEdDSA].Public().(ed25519.PublicKey)), decode(ed.X))
+
+	rsaJWK := set.Keys[2]
+	require.Equal(t, "rsa", rsaJWK.KeyID)
+	require.Equal(t, "RSA", rsaJWK.KeyType)
+	require.Equal(t, AlgorithmRS256, rsaJWK.Algorithm)
+	require.Equal(t, "sig", rsaJWK.Use)
+
+	// a verifier using only the published key can validate tokens
+	rsaPub := &rsa.PublicKey{
+		N: new(big.Int).SetBytes(decode(rsaJWK.N)),
+		E: int(new(big.Int).SetBytes(decode(rsaJWK.E)).Int64()),
+	}
+
+	token, err := keyring.SignToken(testIssuer, testAudience, 0, nil, nil)
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		return rsaPub, nil
+	})
+	require.NoError(t, err)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	for alg, privateKey := range newTestKeys(t) {
+		t.Run(alg, func(t *testing.T) {
+			der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+			require.NoError(t, err)
+
+			parsed, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+			require.NoError(t, err)
+			require.Equal(t, privateKey.Public(), parsed.Public())
+		})
+	}
+
+	keys := newTestKeys(t)
+
+	der := x509.MarshalPKCS1PrivateKey(keys[AlgorithmRS256].(*rsa.PrivateKey))
+	parsed, err := ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	require.Equal(t, keys[AlgorithmRS256].Public(), parsed.Public())
+
+	der, err = x509.MarshalECPrivateKey(keys[AlgorithmES256].(*ecdsa.PrivateKey))
+	require.NoError(t, err)
+	parsed, err = ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+	require.Equal(t, keys[AlgorithmES256].Public(), parsed.Public())
+
+	_, err = ParsePrivateKey([]byte("not a key"))
+	require.Error(t, err)
+}
@@ -0,0 +1,300 @@
+package authtoken
+
+import (
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// AlgorithmHS256 signs tokens with HMAC SHA-256 using the key's secret.
+	AlgorithmHS256 = "HS256"
+
+	// AlgorithmRS256 signs tokens with RSA PKCS #1 v1.5 SHA-256 using the key's RSA private key.
+	AlgorithmRS256 = "RS256"
+
+	// AlgorithmES256 signs tokens with ECDSA P-256 SHA-256 using the key's ECDSA private key.
+	AlgorithmES256 = "ES256"
+
+	// AlgorithmEdDSA signs tokens with Ed25519 using the key's Ed25519 private key.
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// generatedClaims are the registered claims set by SignToken, which can't be overwritten by plain claims so
+// callers can't extend a token's lifetime or reuse another token's ID.
+var generatedClaims = map[string]struct{}{
+	"iss": {},
+	"aud": {},
+	"exp": {},
+	"iat": {},
+	"nbf": {},
+	"jti": {},
+}
+
+var signingMethods = map[string]jwt.SigningMethod{
+	AlgorithmHS256: jwt.SigningMethodHS256,
+	AlgorithmRS256: jwt.SigningMethodRS256,
+	AlgorithmES256: jwt.SigningMethodES256,
+	AlgorithmEdDSA: signingMethodEdDSA,
+}
+
+// Key used for signing and encrypting tokens.
+type Key struct {
+	ID         string        // the key ID, sent in the `kid` header of tokens signed with this key
+	Algorithm  string        // the signing algorithm, one of: HS256 (default), RS256, ES256, EdDSA
+	Secret     string        // the secret used for encrypting claims, and for signing when using HS256
+	PrivateKey crypto.Signer // the private key used for signing when using RS256, ES256, or EdDSA
+	RetireAt   time.Time     // optional, tokens signed with this key are rejected after this time
+}
+
+// Keyring holds the keys used for signing and validating tokens. New tokens are signed with the active key,
+// while tokens signed with any other key in the keyring are accepted until that key's RetireAt deadline.
+// This allows keys to be rotated without invalidating existing tokens immediately.
+type Keyring struct {
+	active string
+	keys   map[string]*keyringKey
+}
+
+type keyringKey struct {
+	Key
+	hash []byte
+}
+
+// NewKeyring returns a new Keyring with the given keys. The active key ID must match one of the given keys.
+func NewKeyring(active string, keys ...Key) (*Keyring, error) {
+	k := &Keyring{
+		active: active,
+		keys:   map[string]*keyringKey{},
+	}
+
+	for _, key := range keys {
+		if len(key.Secret) == 0 {
+			return nil, fmt.Errorf("key: %s: secret is required", key.ID)
+		}
+
+		if len(key.Algorithm) == 0 {
+			key.Algorithm = AlgorithmHS256
+		}
+
+		if _, ok := signingMethods[key.Algorithm]; !ok {
+			return nil, fmt.Errorf("key: %s: unsupported algorithm: %s", key.ID, key.Algorithm)
+		}
+
+		if key.Algorithm != AlgorithmHS256 {
+			if len(key.ID) == 0 {
+				return nil, fmt.Errorf("key: ID is required when using %s", key.Algorithm)
+			}
+
+			if err := checkPrivateKey(key.Algorithm, key.PrivateKey); err != nil {
+				return nil, fmt.Errorf("key: %s: %v", key.ID, err)
+			}
+		}
+
+		if _, ok := k.keys[key.ID]; ok {
+			return nil, fmt.Errorf("key: %s: duplicate key ID", key.ID)
+		}
+
+		k.keys[key.ID] = &keyringKey{
+			Key:  key,
+			hash: hashKey(key.Secret),
+		}
+	}
+
+	activeKey, ok := k.keys[active]
+	if !ok {
+		return nil, fmt.Errorf("active key not found: %s", active)
+	}
+
+	if activeKey.isRetired(time.Now()) {
+		return nil, fmt.Errorf("active key has been retired: %s", active)
+	}
+
+	return k, nil
+}
+
+// ActiveKeyID returns the ID of the key used to sign new tokens.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// SignToken signs a new JWT token with the active key. See the SignToken function for more information.
+func (k *Keyring) SignToken(issuer, audience string, ttl time.Duration, plainClaims, encryptedClaims map[string]interface{}) (string, error) {
+	key := k.keys[k.active]
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+		"iss": issuer,
+		"aud": audience,
+		"jti": hex.EncodeToString(randBytes(16)),
+	}
+
+	if ttl > 0 {
+		claims["exp"] = now.Add(ttl).Unix()
+	}
+
+	if plainClaims != nil {
+		types := map[string]interface{}{}
+
+		for k, v := range plainClaims {
+			if strings.HasPrefix(k, encryptedClaimPrefix) {
+				return "", fmt.Errorf("claim: %s: cannot have '%s' prefix: this is reserved for encrypted claims", k, encryptedClaimPrefix)
+			}
+
+			if _, ok := generatedClaims[k]; k == claimTypesClaim || ok {
+				return "", fmt.Errorf("claim: %s: this claim name is reserved", k)
+			}
+
+			claims[k] = v
+
+			// unsupported types are signed as-is and come back as their JSON equivalent
+			if t, err := claimType(v); err == nil && !isNativeJSONType(t) {
+				types[k] = t
+			}
+		}
+
+		if len(types) > 0 {
+			claims[claimTypesClaim] = types
+		}
+	}
+
+	if encryptedClaims != nil {
+		for k, v := range encryptedClaims {
+			b, err := encodeTypedValue(v)
+			if err != nil {
+				return "", fmt.Errorf("unable to encrypt claim: %s: %v", k, err)
+			}
+
+			encStr, err := encrypt(key.hash, b)
+			if err != nil {
+				return "", fmt.Errorf("unable to encrypt claim: %s: %v", k, err)
+			}
+
+			claims[encryptedClaimPrefix+k] = encStr
+		}
+	}
+
+	token := jwt.NewWithClaims(signingMethods[key.Algorithm], claims)
+	if len(key.ID) > 0 {
+		token.Header["kid"] = key.ID
+	}
+
+	return token.SignedString(key.signingKey())
+}
+
+// ValidateToken validates the given JWT using the key indicated by its `kid` header, and returns its claims.
+// Tokens without a `kid` header are validated with the key that has an empty ID, if there is one.
+// See the ValidateToken function for more information.
+func (k *Keyring) ValidateToken(issuer, audience, token string) (Claims, error) {
+	var key *keyringKey
+
+	tokenObj, err := jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key = k.keys[kid]
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key: %s", kid)
+		}
+
+		// the algorithm is determined by the key, never by the token
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
+		}
+
+		if key.isRetired(time.Now()) {
+			return nil, fmt.Errorf("signing key has been retired: %s", kid)
+		}
+
+		return key.verificationKey(), nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokenClaims, ok := tokenObj.Claims.(jwt.MapClaims)
+	if !ok || !tokenObj.Valid {
+		return nil, fmt.Errorf("token validation failed")
+	}
+
+	if iss, _ := tokenClaims["iss"].(string); iss != issuer {
+		return nil, fmt.Errorf("token issuer invalid")
+	}
+
+	nbf, ok := tokenClaims["nbf"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("token not before invalid")
+	}
+	if int64(nbf) > time.Now().Unix() {
+		return nil, fmt.Errorf("token not yet valid")
+	}
+
+	if exp, ok := tokenClaims["exp"].(float64); ok && int64(exp) <= time.Now().Unix() {
+		return nil, fmt.Errorf("token expired")
+	}
+
+	if iat, ok := tokenClaims["iat"].(float64); ok && int64(iat) > time.Now().Unix() {
+		return nil, fmt.Errorf("token used before issued")
+	}
+
+	if aud, _ := tokenClaims["aud"].(string); aud != audience {
+		return nil, fmt.Errorf("token invalid audience")
+	}
+
+	types, _ := tokenClaims[claimTypesClaim].(map[string]interface{})
+	claims := Claims{}
+
+	for k, v := range tokenClaims {
+		if k == claimTypesClaim {
+			continue
+		}
+
+		if strings.HasPrefix(k, encryptedClaimPrefix) {
+			bs, err := decrypt(key.hash, v.(string))
+			if err != nil {
+				return nil, fmt.Errorf("unable to decrypt claim: %s: %v", k, err)
+			}
+
+			if v, err = decodeTypedValue(bs); err != nil {
+				return nil, fmt.Errorf("unable to decode claim: %s: %v", k, err)
+			}
+
+			claims[strings.TrimPrefix(k, encryptedClaimPrefix)] = v
+		} else if t, ok := types[k]; ok {
+			if v, err = fromJSON(v, t); err != nil {
+				return nil, fmt.Errorf("unable to decode claim: %s: %v", k, err)
+			}
+
+			claims[k] = v
+		} else {
+			claims[k] = v
+		}
+	}
+
+	return claims, nil
+}
+
+// signingKey returns the key used for signing tokens.
+func (k *keyringKey) signingKey() interface{} {
+	if k.Algorithm == AlgorithmHS256 {
+		return k.hash
+	}
+	return k.PrivateKey
+}
+
+// verificationKey returns the key used for validating token signatures.
+func (k *keyringKey) verificationKey() interface{} {
+	if k.Algorithm == AlgorithmHS256 {
+		return k.hash
+	}
+	return k.PrivateKey.Public()
+}
+
+// isRetired determines if the key has been retired as of the given time.
+func (k *keyringKey) isRetired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && now.After(k.RetireAt)
+}
@@ -0,0 +1,103 @@
+package authtoken
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring(t *testing.T) {
+	oldKey := Key{ID: "2020-01", Secret: "old-secret-key"}
+	newKey := Key{ID: "2020-02", Secret: "new-secret-key"}
+
+	keyring, err := NewKeyring(oldKey.ID, oldKey)
+	require.NoError(t, err)
+
+	oldToken, err := keyring.SignToken(testIssuer, testAudience, 0, nil, map[string]interface{}{
+		"pw": "super-secret-password",
+	})
+	require.NoError(t, err)
+
+	parsed, _, err := new(jwt.Parser).ParseUnverified(oldToken, jwt.MapClaims{})
+	require.NoError(t, err)
+	require.Equal(t, oldKey.ID, parsed.Header["kid"])
+
+	t.Run("Rotated", func(t *testing.T) {
+		keyring, err := NewKeyring(newKey.ID, oldKey, newKey)
+		require.NoError(t, err)
+		require.Equal(t, newKey.ID, keyring.ActiveKeyID())
+
+		claims, err := keyring.ValidateToken(testIssuer, testAudience, oldToken)
+		require.NoError(t, err)
+		require.Equal(t, "super-secret-password", claims["pw"])
+
+		newToken, err := keyring.SignToken(testIssuer, testAudience, 0, nil, nil)
+		require.NoError(t, err)
+
+		parsed, _, err := new(jwt.Parser).ParseUnverified(newToken, jwt.MapClaims{})
+		require.NoError(t, err)
+		require.Equal(t, newKey.ID, parsed.Header["kid"])
+	})
+
+	t.Run("Retired", func(t *testing.T) {
+		retired := oldKey
+		retired.RetireAt = time.Now().Add(-time.Minute)
+
+		keyring, err := NewKeyring(newKey.ID, retired, newKey)
+		require.NoError(t, err)
+
+		claims, err := keyring.ValidateToken(testIssuer, testAudience, oldToken)
+		require.Error(t, err)
+		require.Empty(t, claims)
+	})
+
+	t.Run("RetiringSoon", func(t *testing.T) {
+		retiring := oldKey
+		retiring.RetireAt = time.Now().Add(time.Hour)
+
+		keyring, err := NewKeyring(newKey.ID, retiring, newKey)
+		require.NoError(t, err)
+
+		_, err = keyring.ValidateToken(testIssuer, testAudience, oldToken)
+		require.NoError(t, err)
+	})
+
+	t.Run("Removed", func(t *testing.T) {
+		keyring, err := NewKeyring(newKey.ID, newKey)
+		require.NoError(t, err)
+
+		claims, err := keyring.ValidateToken(testIssuer, testAudience, oldToken)
+		require.Error(t, err)
+		require.Empty(t, claims)
+	})
+}
+
+func TestKeyringLegacyToken(t *testing.T) {
+	token, err := SignToken(testKey, testIssuer, testAudience, 0, map[string]interface{}{
+		"iam": "me",
+	}, nil)
+	require.NoError(t, err)
+
+	keyring, err := NewKeyring("2020-01", Key{Secret: testKey}, Key{ID: "2020-01", Secret: "new-secret-key"})
+	require.NoError(t, err)
+
+	claims, err := keyring.ValidateToken(testIssuer, testAudience, token)
+	require.NoError(t, err)
+	require.Equal(t, "me", claims["iam"])
+}
+
+func TestKeyringInvalid(t *testing.T) {
+	_, err := NewKeyring("missing", Key{ID: "2020-01", Secret: "secret"})
+	require.Error(t, err)
+
+	_, err = NewKeyring("2020-01", Key{ID: "2020-01"})
+	require.Error(t, err)
+
+	_, err = NewKeyring("2020-01", Key{ID: "2020-01", Secret: "a"}, Key{ID: "2020-01", Secret: "b"})
+	require.Error(t, err)
+
+	_, err = NewKeyring("2020-01", Key{ID: "2020-01", Secret: "secret", RetireAt: time.Now().Add(-time.Minute)})
+	require.Error(t, err)
+}
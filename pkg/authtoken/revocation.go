@@ -0,0 +1,58 @@
+package authtoken
+
+import (
+	"sync"
+	"time"
+)
+
+// RevocationStore keeps track of revoked token IDs (the `jti` claim).
+type RevocationStore interface {
+	// Revoke the given token ID. The ID only needs to be remembered until expiresAt, after which the
+	// token is no longer valid anyway. A zero expiresAt means the token does not expire.
+	Revoke(id string, expiresAt time.Time) error
+
+	// IsRevoked determines if the given token ID has been revoked.
+	IsRevoked(id string) (bool, error)
+}
+
+// MemoryRevocationStore is an in-memory RevocationStore. Revocations are lost when the process exits.
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryRevocationStore returns a new MemoryRevocationStore.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		revoked: map[string]time.Time{},
+	}
+}
+
+// Revoke the given token ID.
+func (s *MemoryRevocationStore) Revoke(id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.purge()
+	s.revoked[id] = expiresAt
+	return nil
+}
+
+// IsRevoked determines if the given token ID has been revoked.
+func (s *MemoryRevocationStore) IsRevoked(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revoked[id]
+	return ok, nil
+}
+
+// purge removes revocations for tokens that have expired. The caller must hold the lock.
+func (s *MemoryRevocationStore) purge() {
+	now := time.Now()
+	for id, expiresAt := range s.revoked {
+		if !expiresAt.IsZero() && expiresAt.Before(now) {
+			delete(s.revoked, id)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package formatter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// MultiValueMode determines how tabular formats output attributes with multiple values.
+type MultiValueMode string
+
+const (
+	// MultiValueJoin joins all values into a single field using a separator.
+	MultiValueJoin MultiValueMode = "join"
+
+	// MultiValueFirst outputs only the first value.
+	MultiValueFirst MultiValueMode = "first"
+
+	// MultiValueExplode outputs one row per value. When several attributes have multiple values, the values are
+	// paired up by position, and single-valued attributes are repeated on every row.
+	MultiValueExplode MultiValueMode = "explode"
+)
+
+// ParseMultiValueMode parses a MultiValueMode. An empty string returns MultiValueJoin.
+func ParseMultiValueMode(s string) (MultiValueMode, error) {
+	switch MultiValueMode(strings.ToLower(s)) {
+	case "", MultiValueJoin:
+		return MultiValueJoin, nil
+	case MultiValueFirst:
+		return MultiValueFirst, nil
+	case MultiValueExplode:
+		return MultiValueExplode, nil
+	}
+
+	return "", fmt.Errorf("unrecognized multi-value mode: %s, must be one of: join, first, explode", s)
+}
+
+// columnValues returns the values of the given attributes of an entry.
+func columnValues(e *ldap.Entry, columns []string) [][]string {
+	values := make([][]string, len(columns))
+	for i, col := range columns {
+		values[i] = e.GetEqualFoldAttributeValues(col)
+	}
+	return values
+}
+
+// multiValueRows returns the number of rows needed to output the given attribute values of an entry.
+func multiValueRows(values [][]string, mode MultiValueMode) int {
+	rows := 1
+	if mode == MultiValueExplode {
+		for _, v := range values {
+			if len(v) > rows {
+				rows = len(v)
+			}
+		}
+	}
+	return rows
+}
+
+// multiValueField returns the field for the given attribute values on the given row of an entry.
+func multiValueField(values []string, row int, mode MultiValueMode, separator string) string {
+	switch {
+	case len(values) == 0:
+		return ""
+	case mode == MultiValueFirst:
+		return values[0]
+	case mode == MultiValueExplode:
+		if len(values) == 1 {
+			return values[0]
+		} else if row < len(values) {
+			return values[row]
+		}
+		return ""
+	}
+
+	return strings.Join(values, separator)
+}
+
+// sortColumns sorts attribute names alphabetically, ignoring case.
+func sortColumns(columns []string) {
+	sort.Slice(columns, func(i, j int) bool {
+		return strings.ToLower(columns[i]) < strings.ToLower(columns[j])
+	})
+}
+
+// isWildcardColumn determines if the attribute is `*` or `+`, which request all user or all operational attributes.
+func isWildcardColumn(attribute string) bool {
+	return attribute == "*" || attribute == "+"
+}
+
+// entryColumns returns the columns for the requested attributes, in the order requested and without duplicates.
+// If no attributes were requested, or `*` or `+` was requested, the attributes of the given entry that weren't
+// requested by name follow in alphabetical order. The entry is nil if there were no entries.
+func entryColumns(attributes []string, e *ldap.Entry) []string {
+	columns := []string{}
+	seen := map[string]bool{}
+	wildcard := len(attributes) == 0
+
+	for _, attr := range attributes {
+		if isWildcardColumn(attr) {
+			wildcard = true
+			continue
+		}
+
+		if !seen[strings.ToLower(attr)] {
+			seen[strings.ToLower(attr)] = true
+			columns = append(columns, attr)
+		}
+	}
+
+	if !wildcard || e == nil {
+		return columns
+	}
+
+	rest := []string{}
+	for _, attr := range e.Attributes {
+		if !seen[strings.ToLower(attr.Name)] {
+			seen[strings.ToLower(attr.Name)] = true
+			rest = append(rest, attr.Name)
+		}
+	}
+	sortColumns(rest)
+
+	return append(columns, rest...)
+}
+
+// needsEntryColumns determines if the columns for the requested attributes depend on the first entry.
+func needsEntryColumns(attributes []string) bool {
+	if len(attributes) == 0 {
+		return true
+	}
+
+	for _, attr := range attributes {
+		if isWildcardColumn(attr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LDAPFormatterCSV outputs CSV with a header row, using the attributes of the first entry as columns.
+func LDAPFormatterCSV(resp *ldap.SearchResult) ([]byte, error) {
+	return formatStream(newCSVStreamFormatter, resp)
+}
+
+// LDAPFormatterTSV outputs tab-separated values with a header row, using the attributes of the first entry as columns.
+func LDAPFormatterTSV(resp *ldap.SearchResult) ([]byte, error) {
+	return formatStream(newTSVStreamFormatter, resp)
+}
+
+// csvStreamFormatter writes delimited values with a header row, the DN in the first column, and one column for
+// each requested attribute in the order requested. If no attributes were requested, the attributes of the first
+// entry are used in alphabetical order, as they are in place of `*` and `+`. The header row can be omitted with the
+// NoHeaders option. Fields are quoted as needed by encoding/csv.
+type csvStreamFormatter struct {
+	w         *csv.Writer
+	columns   []string
+	header    bool
+	noHeaders bool
+	mode      MultiValueMode
+	separator string
+}
+
+func newCSVStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	return newDelimitedStreamFormatter(w, ',', opts)
+}
+
+func newTSVStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	return newDelimitedStreamFormatter(w, '\t', opts)
+}
+
+func newDelimitedStreamFormatter(w io.Writer, comma rune, opts *LDAPStreamOptions) *csvStreamFormatter {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+
+	s := &csvStreamFormatter{
+		w:         cw,
+		columns:   opts.Attributes,
+		noHeaders: opts.NoHeaders,
+		mode:      opts.MultiValue,
+		separator: opts.Separator,
+	}
+
+	if len(s.mode) == 0 {
+		s.mode = MultiValueJoin
+	}
+	if len(s.separator) == 0 {
+		s.separator = ";"
+	}
+
+	return s
+}
+
+func (s *csvStreamFormatter) Begin() error {
+	if needsEntryColumns(s.columns) {
+		// the columns are taken from the first entry
+		return nil
+	}
+
+	s.columns = entryColumns(s.columns, nil)
+	return s.writeHeader()
+}
+
+func (s *csvStreamFormatter) Entry(e *ldap.Entry) error {
+	if !s.header {
+		s.columns = entryColumns(s.columns, e)
+		if err := s.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	values := columnValues(e, s.columns)
+	rows := multiValueRows(values, s.mode)
+
+	for r := 0; r < rows; r++ {
+		row := []string{e.DN}
+		for _, v := range values {
+			row = append(row, multiValueField(v, r, s.mode, s.separator))
+		}
+
+		if err := s.w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func (s *csvStreamFormatter) End() error {
+	if !s.header {
+		s.columns = entryColumns(s.columns, nil)
+		if err := s.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func (s *csvStreamFormatter) writeHeader() error {
+	s.header = true
+	if s.noHeaders {
+		return nil
+	}
+	return s.w.Write(append([]string{"dn"}, s.columns...))
+}
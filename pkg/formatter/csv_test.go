@@ -0,0 +1,99 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVStreamFormatter(t *testing.T) {
+	resp := testSearchResult()
+
+	// without requested attributes, the attributes of the first entry are used in alphabetical order
+	require.Equal(t, "dn,cn,mail\n"+
+		"\"cn=newton,dc=example,dc=com\",newton,newton@example.com\n"+
+		"\"cn=einstein,dc=example,dc=com\",einstein,einstein@example.com;albert@example.com\n",
+		streamFormat(t, "csv", nil, resp))
+
+	opts := &LDAPStreamOptions{Attributes: []string{"mail", "description"}, NoHeaders: true}
+	require.Equal(t, "cn=newton,dc=example,dc=com\tnewton@example.com\t\n"+
+		"cn=einstein,dc=example,dc=com\teinstein@example.com;albert@example.com\tphysicist\n",
+		streamFormat(t, "tsv", opts, resp))
+
+	// the header is written even without entries when the attributes were requested
+	opts = &LDAPStreamOptions{Attributes: []string{"cn", "mail"}}
+	require.Equal(t, "dn,cn,mail\n", streamFormat(t, "csv", opts, &ldap.SearchResult{}))
+}
+
+func TestCSVQuoting(t *testing.T) {
+	resp := &ldap.SearchResult{Entries: []*ldap.Entry{
+		ldap.NewEntry("cn=Newton\\, Isaac,dc=example,dc=com", map[string][]string{
+			"description": {"said \"hello\"", "line one\nline two"},
+		}),
+	}}
+
+	opts := &LDAPStreamOptions{Attributes: []string{"description"}, MultiValue: MultiValueExplode}
+	require.Equal(t, "dn,description\n"+
+		"\"cn=Newton\\, Isaac,dc=example,dc=com\",\"said \"\"hello\"\"\"\n"+
+		"\"cn=Newton\\, Isaac,dc=example,dc=com\",\"line one\nline two\"\n",
+		streamFormat(t, "csv", opts, resp))
+}
+
+func TestCSVMultiValue(t *testing.T) {
+	resp := &ldap.SearchResult{Entries: []*ldap.Entry{
+		ldap.NewEntry("cn=einstein", map[string][]string{
+			"cn":          {"einstein"},
+			"mail":        {"einstein@example.com", "albert@example.com"},
+			"memberOf":    {"cn=a", "cn=b", "cn=c"},
+			"description": {},
+		}),
+	}}
+	attributes := []string{"cn", "mail", "memberOf", "description"}
+
+	opts := &LDAPStreamOptions{Attributes: attributes, NoHeaders: true, Separator: "|"}
+	require.Equal(t, "cn=einstein,einstein,einstein@example.com|albert@example.com,cn=a|cn=b|cn=c,\n",
+		streamFormat(t, "csv", opts, resp))
+
+	opts = &LDAPStreamOptions{Attributes: attributes, NoHeaders: true, MultiValue: MultiValueFirst}
+	require.Equal(t, "cn=einstein,einstein,einstein@example.com,cn=a,\n", streamFormat(t, "csv", opts, resp))
+
+	// values are paired up by position, and single values are repeated on every row
+	opts = &LDAPStreamOptions{Attributes: attributes, NoHeaders: true, MultiValue: MultiValueExplode}
+	require.Equal(t, "cn=einstein,einstein,einstein@example.com,cn=a,\n"+
+		"cn=einstein,einstein,albert@example.com,cn=b,\n"+
+		"cn=einstein,einstein,,cn=c,\n",
+		streamFormat(t, "csv", opts, resp))
+}
+
+func TestCSVWildcardAttributes(t *testing.T) {
+	resp := testSearchResult()
+
+	// wildcards are replaced by the attributes of the first entry that weren't requested by name
+	for _, attributes := range [][]string{{"*"}, {"+"}, {"*", "+"}} {
+		opts := &LDAPStreamOptions{Attributes: attributes}
+		require.Equal(t, streamFormat(t, "csv", nil, resp), streamFormat(t, "csv", opts, resp), attributes)
+	}
+
+	opts := &LDAPStreamOptions{Attributes: []string{"mail", "*", "MAIL"}}
+	require.Equal(t, "dn,mail,cn\n"+
+		"\"cn=newton,dc=example,dc=com\",newton@example.com,newton\n"+
+		"\"cn=einstein,dc=example,dc=com\",einstein@example.com;albert@example.com,einstein\n",
+		streamFormat(t, "csv", opts, resp))
+
+	// without entries, only the attributes requested by name are used
+	require.Equal(t, "dn,mail\n", streamFormat(t, "csv", opts, &ldap.SearchResult{}))
+}
+
+func TestParseMultiValueMode(t *testing.T) {
+	mode, err := ParseMultiValueMode("")
+	require.NoError(t, err)
+	require.Equal(t, MultiValueJoin, mode)
+
+	mode, err = ParseMultiValueMode("Explode")
+	require.NoError(t, err)
+	require.Equal(t, MultiValueExplode, mode)
+
+	_, err = ParseMultiValueMode("split")
+	require.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package formatter
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPValueDecoder decodes a binary attribute value into text.
+type LDAPValueDecoder func(value []byte) (string, error)
+
+// LDAPValueDecoders maps lower case attribute names to the decoder for their values. Values that a decoder fails
+// to decode are base64-encoded instead.
+var LDAPValueDecoders = map[string]LDAPValueDecoder{
+	"objectsid":              ldapcli.ParseSID,
+	"sidhistory":             ldapcli.ParseSID,
+	"securityidentifier":     ldapcli.ParseSID,
+	"tokengroups":            ldapcli.ParseSID,
+	"msexchmasteraccountsid": ldapcli.ParseSID,
+	"ms-ds-creatorsid":       ldapcli.ParseSID,
+	"objectguid":             ldapcli.ParseGUID,
+	"msexchmailboxguid":      ldapcli.ParseGUID,
+	"msexcharchiveguid":      ldapcli.ParseGUID,
+	"ms-ds-consistencyguid":  ldapcli.ParseGUID,
+	"schemaidguid":           ldapcli.ParseGUID,
+	"attributesecurityguid":  ldapcli.ParseGUID,
+	"usercertificate":        decodeCertificate,
+	"cacertificate":          decodeCertificate,
+	"thumbnailphoto":         decodeBase64,
+	"jpegphoto":              decodeBase64,
+	"logonhours":             decodeBase64,
+	"ntsecuritydescriptor":   decodeBase64,
+}
+
+// DecodeLDAPEntry returns a copy of the entry with values decoded for display. Values of attributes with a
+// registered LDAPValueDecoder are decoded by it, and other values that aren't printable UTF-8 text are
+// base64-encoded. Attribute options, such as `;binary`, are ignored when looking up decoders.
+func DecodeLDAPEntry(e *ldap.Entry) *ldap.Entry {
+	decoded := &ldap.Entry{
+		DN:         e.DN,
+		Attributes: make([]*ldap.EntryAttribute, len(e.Attributes)),
+	}
+
+	for i, attr := range e.Attributes {
+		name := strings.ToLower(strings.SplitN(attr.Name, ";", 2)[0])
+		decoder := LDAPValueDecoders[name]
+
+		// attributes that weren't received from a server may only have string values
+		raw := attr.ByteValues
+		if len(raw) != len(attr.Values) {
+			raw = make([][]byte, len(attr.Values))
+			for j, v := range attr.Values {
+				raw[j] = []byte(v)
+			}
+		}
+
+		values := make([]string, len(raw))
+		byteValues := make([][]byte, len(raw))
+		for j, v := range raw {
+			values[j] = decodeValue(decoder, v)
+			byteValues[j] = []byte(values[j])
+		}
+
+		decoded.Attributes[i] = &ldap.EntryAttribute{
+			Name:       attr.Name,
+			Values:     values,
+			ByteValues: byteValues,
+		}
+	}
+
+	return decoded
+}
+
+// DecodeLDAPSearchResult returns a copy of the search result with the values of each entry decoded for display.
+// See DecodeLDAPEntry.
+func DecodeLDAPSearchResult(resp *ldap.SearchResult) *ldap.SearchResult {
+	decoded := &ldap.SearchResult{
+		Entries:   make([]*ldap.Entry, len(resp.Entries)),
+		Referrals: resp.Referrals,
+		Controls:  resp.Controls,
+	}
+
+	for i, e := range resp.Entries {
+		decoded.Entries[i] = DecodeLDAPEntry(e)
+	}
+
+	return decoded
+}
+
+func decodeValue(decoder LDAPValueDecoder, value []byte) string {
+	if decoder != nil {
+		if s, err := decoder(value); err == nil {
+			return s
+		}
+		return base64.StdEncoding.EncodeToString(value)
+	}
+
+	if !isPrintable(value) {
+		return base64.StdEncoding.EncodeToString(value)
+	}
+
+	return string(value)
+}
+
+// isPrintable returns true if the value is valid UTF-8 without control characters other than whitespace.
+func isPrintable(value []byte) bool {
+	if !utf8.Valid(value) {
+		return false
+	}
+
+	for _, r := range string(value) {
+		if unicode.IsControl(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func decodeBase64(value []byte) (string, error) {
+	return base64.StdEncoding.EncodeToString(value), nil
+}
+
+// decodeCertificate summarizes a DER-encoded X.509 certificate.
+func decodeCertificate(value []byte) (string, error) {
+	cert, err := x509.ParseCertificate(value)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("subject: %s; issuer: %s; serial: %x; not before: %s; not after: %s",
+		cert.Subject,
+		cert.Issuer,
+		cert.SerialNumber,
+		cert.NotBefore.UTC().Format(time.RFC3339),
+		cert.NotAfter.UTC().Format(time.RFC3339),
+	), nil
+}
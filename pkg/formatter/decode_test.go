@@ -0,0 +1,110 @@
+package formatter
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// binaryEntry returns an entry with a single attribute as received from a server, with the given raw values.
+func binaryEntry(name string, values ...[]byte) *ldap.Entry {
+	attr := &ldap.EntryAttribute{Name: name, ByteValues: values}
+	for _, v := range values {
+		attr.Values = append(attr.Values, string(v))
+	}
+
+	return &ldap.Entry{DN: "cn=newton,dc=example,dc=com", Attributes: []*ldap.EntryAttribute{attr}}
+}
+
+func testCertificate(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x2a),
+		Subject:      pkix.Name{CommonName: "newton"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return der
+}
+
+func TestDecodeCertificate(t *testing.T) {
+	der := testCertificate(t)
+
+	for _, name := range []string{"userCertificate", "userCertificate;binary", "cACertificate"} {
+		e := DecodeLDAPEntry(binaryEntry(name, der))
+		require.Equal(t, name, e.Attributes[0].Name)
+		require.Equal(t, []string{
+			"subject: CN=newton; issuer: CN=newton; serial: 2a; not before: 2020-01-01T00:00:00Z; not after: 2021-01-01T00:00:00Z",
+		}, e.Attributes[0].Values)
+		require.Equal(t, []byte(e.Attributes[0].Values[0]), e.Attributes[0].ByteValues[0])
+	}
+
+	// values that aren't certificates are base64-encoded instead
+	e := DecodeLDAPEntry(binaryEntry("userCertificate", []byte{0x30, 0x03, 0x02, 0x01}))
+	require.Equal(t, []string{"MAMCAQ=="}, e.Attributes[0].Values)
+}
+
+func TestDecodeNonUTF8(t *testing.T) {
+	invalid := []byte{0xff, 0xfe, 0x41}
+	control := []byte("a\x00b")
+
+	e := DecodeLDAPEntry(binaryEntry("description", invalid, control, []byte("café\tlatte\n")))
+	require.Equal(t, []string{
+		base64.StdEncoding.EncodeToString(invalid),
+		base64.StdEncoding.EncodeToString(control),
+		"café\tlatte\n",
+	}, e.Attributes[0].Values)
+
+	// the original entry isn't modified
+	original := binaryEntry("description", invalid)
+	DecodeLDAPEntry(original)
+	require.Equal(t, string(invalid), original.Attributes[0].Values[0])
+}
+
+func TestDecodeADValues(t *testing.T) {
+	sid := []byte{0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}
+	guid := []byte{0xd4, 0xc3, 0xb2, 0xa1, 0xf6, 0xe5, 0xb8, 0xa7, 0xc9, 0xd0, 0xe1, 0xf2, 0xa3, 0xb4, 0xc5, 0xd6}
+
+	e := DecodeLDAPEntry(binaryEntry("objectSid", sid))
+	require.Equal(t, []string{"S-1-1-0"}, e.Attributes[0].Values)
+
+	e = DecodeLDAPEntry(binaryEntry("objectGUID", guid))
+	require.Equal(t, []string{"a1b2c3d4-e5f6-a7b8-c9d0-e1f2a3b4c5d6"}, e.Attributes[0].Values)
+
+	// printable values of binary attributes are still base64-encoded
+	e = DecodeLDAPEntry(binaryEntry("thumbnailPhoto", []byte("photo")))
+	require.Equal(t, []string{"cGhvdG8="}, e.Attributes[0].Values)
+}
+
+func TestDecodeStringValues(t *testing.T) {
+	// entries that weren't received from a server may not have byte values
+	e := DecodeLDAPEntry(ldap.NewEntry("cn=newton", map[string][]string{"cn": {"newton"}}))
+	require.Equal(t, []string{"newton"}, e.Attributes[0].Values)
+
+	e = DecodeLDAPEntry(&ldap.Entry{DN: "cn=newton", Attributes: []*ldap.EntryAttribute{
+		{Name: "cn", Values: []string{"newton"}},
+	}})
+	require.Equal(t, []string{"newton"}, e.Attributes[0].Values)
+	require.Equal(t, [][]byte{[]byte("newton")}, e.Attributes[0].ByteValues)
+
+	resp := DecodeLDAPSearchResult(&ldap.SearchResult{
+		Entries:   []*ldap.Entry{binaryEntry("description", []byte{0xff})},
+		Referrals: []string{"ldap://dc2.example.com/dc=example,dc=com"},
+	})
+	require.Equal(t, []string{"/w=="}, resp.Entries[0].Attributes[0].Values)
+	require.Equal(t, []string{"ldap://dc2.example.com/dc=example,dc=com"}, resp.Referrals)
+}
@@ -0,0 +1,55 @@
+package formatter
+
+import (
+	"strings"
+	"time"
+
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// InterpretLDAPEntry returns a copy of the entry with the values of known Active Directory attributes made
+// human-readable. Times are formatted as RFC3339, or `never` for values that mean never, and userAccountControl
+// is shown as the names of its flags. Values that can't be interpreted are left as-is.
+func InterpretLDAPEntry(e *ldap.Entry) *ldap.Entry {
+	interpreted := &ldap.Entry{
+		DN:         e.DN,
+		Attributes: make([]*ldap.EntryAttribute, len(e.Attributes)),
+	}
+
+	for i, attr := range e.Attributes {
+		values := make([]string, len(attr.Values))
+		for j, v := range attr.Values {
+			values[j] = interpretValue(attr.Name, v)
+		}
+
+		interpreted.Attributes[i] = ldap.NewEntryAttribute(attr.Name, values)
+	}
+
+	return interpreted
+}
+
+func interpretValue(attribute, value string) string {
+	name := strings.SplitN(attribute, ";", 2)[0]
+
+	if strings.EqualFold(name, ldapcli.AttributeUserAccountControl) {
+		uac, err := ldapcli.ParseUserAccountControl(value)
+		if err != nil || uac == 0 {
+			return value
+		}
+		return uac.String()
+	}
+
+	if ldapcli.TimeAttributes[strings.ToLower(name)] == ldapcli.TimeSyntaxNone {
+		return value
+	}
+
+	t, err := ldapcli.ParseTimeAttribute(name, value)
+	if err != nil {
+		return value
+	} else if t.IsZero() {
+		return "never"
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
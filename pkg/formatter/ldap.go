@@ -21,16 +21,36 @@ type LDAPAttribute struct {
 // LDAPEntry represents an LDAP entry.
 type LDAPEntry struct {
 	DistinguishedName string          `json:"distinguishedName"`
+	Domain            string          `json:"domain,omitempty" yaml:"domain,omitempty"`
 	Attributes        []LDAPAttribute `json:"attributes"`
 }
 
 // LDAPFormatters is a list of registered formatters.
 var LDAPFormatters = map[string]LDAPFormatter{
+	"csv":         LDAPFormatterCSV,
 	"json":        LDAPFormatterJSON,
 	"json-pretty": LDAPFormatterJSONPretty,
+	"jsonl":       LDAPFormatterJSONLines,
 	"ldif":        LDAPFormatterLDIF,
+	"table":       LDAPFormatterTable,
 	"text":        LDAPFormatterText,
+	"tsv":         LDAPFormatterTSV,
 	"yaml":        LDAPFormatterYAML,
+	"yaml-stream": LDAPFormatterYAMLStream,
+}
+
+// LDAPContentTypes maps registered formatters to the content type of their output.
+var LDAPContentTypes = map[string]string{
+	"csv":         "text/csv; charset=utf-8",
+	"json":        "application/json; charset=utf-8",
+	"json-pretty": "application/json; charset=utf-8",
+	"jsonl":       "application/jsonl; charset=utf-8",
+	"ldif":        "application/ldif; charset=utf-8",
+	"table":       "text/plain; charset=utf-8",
+	"text":        "text/plain; charset=utf-8",
+	"tsv":         "text/tab-separated-values; charset=utf-8",
+	"yaml":        "application/yaml; charset=utf-8",
+	"yaml-stream": "application/yaml; charset=utf-8",
 }
 
 // LDAPFormatter interface for outputing objects into multiple formats.
@@ -48,8 +68,18 @@ func LDAPFormatterJSONPretty(resp *ldap.SearchResult) ([]byte, error) {
 	return json.MarshalIndent(entries, "", "  ")
 }
 
+// LDAPFormatterJSONLines outputs JSON Lines, one JSON object per entry.
+func LDAPFormatterJSONLines(resp *ldap.SearchResult) ([]byte, error) {
+	return formatStream(newJSONLinesStreamFormatter, resp)
+}
+
 // LDAPFormatterLDIF outputs LDAP entries in LDIF.
 func LDAPFormatterLDIF(resp *ldap.SearchResult) ([]byte, error) {
+	return marshalLDIF(resp, 1)
+}
+
+// marshalLDIF outputs LDAP entries in LDIF, with the version header if version is greater than zero.
+func marshalLDIF(resp *ldap.SearchResult, version int) ([]byte, error) {
 	entries := []*ldif.Entry{}
 
 	for _, e := range resp.Entries {
@@ -58,7 +88,7 @@ func LDAPFormatterLDIF(resp *ldap.SearchResult) ([]byte, error) {
 
 	ld := &ldif.LDIF{
 		Entries: entries,
-		Version: 1,
+		Version: version,
 	}
 
 	str, err := ldif.Marshal(ld)
@@ -94,8 +124,13 @@ func LDAPFormatterYAML(resp *ldap.SearchResult) ([]byte, error) {
 	return yaml.Marshal(entries)
 }
 
-// FormatLDAPSearchResult attempts to format an LDAP SearchResult it to the given format.
-// If format is an empty string, `text` is used.
+// LDAPFormatterYAMLStream outputs a YAML stream with one document per entry.
+func LDAPFormatterYAMLStream(resp *ldap.SearchResult) ([]byte, error) {
+	return formatStream(newYAMLDocumentsStreamFormatter, resp)
+}
+
+// FormatLDAPSearchResult attempts to format an LDAP SearchResult it to the given format, with attributes redacted
+// by DefaultRedactionPolicy and values decoded for display. If format is an empty string, `text` is used.
 func FormatLDAPSearchResult(format string, resp *ldap.SearchResult) ([]byte, error) {
 	if len(format) == 0 {
 		format = "text"
@@ -106,7 +141,18 @@ func FormatLDAPSearchResult(format string, resp *ldap.SearchResult) ([]byte, err
 		return nil, fmt.Errorf("unrecognized format: %s", format)
 	}
 
-	return f(resp)
+	redaction := DefaultRedactionPolicy()
+	redacted := &ldap.SearchResult{Referrals: resp.Referrals, Controls: resp.Controls}
+	for _, e := range resp.Entries {
+		redacted.Entries = append(redacted.Entries, redaction.RedactLDAPEntry(e))
+	}
+
+	return f(DecodeLDAPSearchResult(redacted))
+}
+
+// LDAPEntries converts an LDAP SearchResult into a list of LDAPEntry objects.
+func LDAPEntries(resp *ldap.SearchResult) []LDAPEntry {
+	return preprocessLDAPSearchResult(resp)
 }
 
 func preprocessLDAPSearchResult(resp *ldap.SearchResult) []LDAPEntry {
@@ -0,0 +1,58 @@
+package formatter
+
+import (
+	"io"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPPageWriter writes pages of LDAP search results to an io.Writer as they arrive using an LDAPStreamFormatter.
+// Nothing is written until the first page arrives, so an error before then doesn't leave partial output behind.
+type LDAPPageWriter struct {
+	s     LDAPStreamFormatter
+	begun bool
+}
+
+// NewLDAPPageWriter returns a new LDAPPageWriter for the given format. If format is an empty string, `text` is used.
+// opts may be nil.
+func NewLDAPPageWriter(w io.Writer, format string, opts *LDAPStreamOptions) (*LDAPPageWriter, error) {
+	s, err := NewLDAPStreamFormatter(w, format, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LDAPPageWriter{s: s}, nil
+}
+
+// WritePage writes the entries in the given page of search results.
+func (p *LDAPPageWriter) WritePage(page *ldap.SearchResult) error {
+	if err := p.begin(); err != nil {
+		return err
+	}
+
+	for _, e := range page.Entries {
+		if err := p.s.Entry(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close writes anything that follows the last entry, including any buffered entries.
+func (p *LDAPPageWriter) Close() error {
+	if err := p.begin(); err != nil {
+		return err
+	}
+
+	return p.s.End()
+}
+
+func (p *LDAPPageWriter) begin() error {
+	if p.begun {
+		return nil
+	}
+
+	p.begun = true
+	return p.s.Begin()
+}
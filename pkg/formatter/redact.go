@@ -0,0 +1,152 @@
+package formatter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// RedactedValue replaces each value of a masked attribute.
+const RedactedValue = "[REDACTED]"
+
+// DefaultRedactedAttributes are attributes that hold passwords and other secrets, which are masked by
+// DefaultRedactionPolicy.
+var DefaultRedactedAttributes = []string{
+	"unicodePwd",
+	"userPassword",
+	"dBCSPwd",
+	"ntPwdHistory",
+	"lmPwdHistory",
+	"supplementalCredentials",
+	"ms-Mcs-AdmPwd",
+	"msLAPS-Password",
+	"msLAPS-EncryptedPassword",
+	"msLAPS-EncryptedPasswordHistory",
+	"msLAPS-EncryptedDSRMPassword",
+	"msLAPS-EncryptedDSRMPasswordHistory",
+	"msFVE-RecoveryPassword",
+	"msFVE-KeyPackage",
+}
+
+// RedactionPolicy determines which attributes are masked or dropped before entries are formatted. Attributes are
+// matched by name or by a pattern such as `msLAPS-*`, ignoring case and attribute options such as `;binary`.
+// Dropped attributes are removed entirely, while masked attributes keep their name with each value replaced by
+// RedactedValue.
+type RedactionPolicy struct {
+	mask []string
+	drop []string
+}
+
+// NewRedactionPolicy returns a policy that masks DefaultRedactedAttributes and the given attributes, and drops
+// the given attributes. Patterns use the syntax of path.Match.
+func NewRedactionPolicy(mask, drop []string) (*RedactionPolicy, error) {
+	p := &RedactionPolicy{}
+
+	for _, pattern := range append(append([]string{}, DefaultRedactedAttributes...), mask...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid attribute pattern: %s", pattern)
+		}
+		p.mask = append(p.mask, strings.ToLower(pattern))
+	}
+
+	for _, pattern := range drop {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid attribute pattern: %s", pattern)
+		}
+		p.drop = append(p.drop, strings.ToLower(pattern))
+	}
+
+	return p, nil
+}
+
+// DefaultRedactionPolicy returns a policy that masks DefaultRedactedAttributes.
+func DefaultRedactionPolicy() *RedactionPolicy {
+	p, _ := NewRedactionPolicy(nil, nil)
+	return p
+}
+
+// NoRedactionPolicy returns a policy that doesn't redact any attributes.
+func NoRedactionPolicy() *RedactionPolicy {
+	return &RedactionPolicy{}
+}
+
+// RedactLDAPEntry returns the entry with the attributes matched by the policy masked or dropped. The entry is
+// returned as-is if no attributes match.
+func (p *RedactionPolicy) RedactLDAPEntry(e *ldap.Entry) *ldap.Entry {
+	var redacted *ldap.Entry
+
+	for i, attr := range e.Attributes {
+		drop, mask := p.match(attr.Name)
+		if !drop && !mask {
+			if redacted != nil {
+				redacted.Attributes = append(redacted.Attributes, attr)
+			}
+			continue
+		}
+
+		// copy the attributes before the first one that is redacted
+		if redacted == nil {
+			redacted = &ldap.Entry{DN: e.DN, Attributes: append([]*ldap.EntryAttribute{}, e.Attributes[:i]...)}
+		}
+
+		if drop {
+			continue
+		}
+
+		values := make([]string, len(attr.Values))
+		for j := range values {
+			values[j] = RedactedValue
+		}
+		redacted.Attributes = append(redacted.Attributes, ldap.NewEntryAttribute(attr.Name, values))
+	}
+
+	if redacted == nil {
+		return e
+	}
+
+	return redacted
+}
+
+// Redacts determines if the given attribute is masked or dropped by the policy.
+func (p *RedactionPolicy) Redacts(attribute string) bool {
+	drop, mask := p.match(attribute)
+	return drop || mask
+}
+
+// RedactAttributes returns the given attribute names without those dropped by the policy, such as the requested
+// attributes that tabular formats use as columns.
+func (p *RedactionPolicy) RedactAttributes(attributes []string) []string {
+	if len(p.drop) == 0 {
+		return attributes
+	}
+
+	kept := []string{}
+	for _, attr := range attributes {
+		if drop, _ := p.match(attr); !drop {
+			kept = append(kept, attr)
+		}
+	}
+
+	return kept
+}
+
+// match returns whether the given attribute is dropped or masked by the policy.
+func (p *RedactionPolicy) match(attribute string) (drop, mask bool) {
+	name := strings.ToLower(strings.SplitN(attribute, ";", 2)[0])
+
+	for _, pattern := range p.drop {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true, false
+		}
+	}
+
+	for _, pattern := range p.mask {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false, true
+		}
+	}
+
+	return false, false
+}
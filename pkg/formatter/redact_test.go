@@ -0,0 +1,88 @@
+package formatter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRedactionPolicy(t *testing.T) {
+	_, err := NewRedactionPolicy([]string{"[a-"}, nil)
+	require.Error(t, err)
+
+	_, err = NewRedactionPolicy(nil, []string{"[a-"})
+	require.Error(t, err)
+}
+
+func TestRedactionPolicyPatterns(t *testing.T) {
+	p, err := NewRedactionPolicy([]string{"employee?D"}, []string{"msDS-*", "homePhone"})
+	require.NoError(t, err)
+
+	// the defaults are masked, and names are matched ignoring case and attribute options
+	for _, attr := range []string{"unicodePwd", "USERPASSWORD", "userPassword;binary", "msLAPS-Password", "employeeID", "msDS-KeyCredentialLink", "homephone"} {
+		require.True(t, p.Redacts(attr), attr)
+	}
+
+	for _, attr := range []string{"cn", "employeeNumber", "msLAPS", "mail;binary", "userPasswordHint"} {
+		require.False(t, p.Redacts(attr), attr)
+	}
+
+	// dropping takes precedence over masking
+	p, err = NewRedactionPolicy([]string{"mail"}, []string{"mail"})
+	require.NoError(t, err)
+	drop, mask := p.match("mail")
+	require.True(t, drop)
+	require.False(t, mask)
+
+	require.False(t, NoRedactionPolicy().Redacts("unicodePwd"))
+}
+
+func TestRedactLDAPEntry(t *testing.T) {
+	p, err := NewRedactionPolicy([]string{"mail"}, []string{"homePhone"})
+	require.NoError(t, err)
+
+	e := ldap.NewEntry("cn=newton", map[string][]string{
+		"cn":           {"newton"},
+		"homePhone":    {"555-0100"},
+		"mail":         {"newton@example.com", "isaac@example.com"},
+		"userPassword": {"{SSHA}newton"},
+	})
+
+	redacted := p.RedactLDAPEntry(e)
+	require.Equal(t, "cn=newton", redacted.DN)
+	require.Len(t, redacted.Attributes, 3)
+	require.Equal(t, []string{"newton"}, redacted.GetAttributeValues("cn"))
+	require.Empty(t, redacted.GetAttributeValues("homePhone"))
+	require.Equal(t, []string{RedactedValue, RedactedValue}, redacted.GetAttributeValues("mail"))
+	require.Equal(t, []string{RedactedValue}, redacted.GetAttributeValues("userPassword"))
+
+	// the original entry isn't modified, and is returned as-is when nothing is redacted
+	require.Equal(t, []string{"555-0100"}, e.GetAttributeValues("homePhone"))
+
+	plain := ldap.NewEntry("cn=newton", map[string][]string{"cn": {"newton"}})
+	require.True(t, p.RedactLDAPEntry(plain) == plain)
+}
+
+func TestRedactAttributes(t *testing.T) {
+	p, err := NewRedactionPolicy(nil, []string{"home*"})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"cn", "unicodePwd", "*"}, p.RedactAttributes([]string{"cn", "homePhone", "unicodePwd", "HomeDirectory", "*"}))
+	require.Equal(t, []string{"cn"}, DefaultRedactionPolicy().RedactAttributes([]string{"cn"}))
+
+	// dropped attributes aren't output as columns, even if requested
+	resp := &ldap.SearchResult{Entries: []*ldap.Entry{
+		ldap.NewEntry("cn=newton", map[string][]string{"cn": {"newton"}, "homePhone": {"555-0100"}}),
+	}}
+
+	for _, format := range []string{"csv", "table"} {
+		buf := &bytes.Buffer{}
+		s, err := NewLDAPStreamFormatter(buf, format, &LDAPStreamOptions{Attributes: []string{"cn", "homePhone"}, Redaction: p})
+		require.NoError(t, err)
+		require.NoError(t, WriteLDAPSearchResult(s, resp))
+		require.NotContains(t, strings.ToLower(buf.String()), "homephone", format)
+	}
+}
@@ -0,0 +1,438 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	"github.com/go-ldap/ldap/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// LDAPStreamFormatter writes LDAP entries to an io.Writer one at a time, so a search result never needs to be
+// held in memory. Begin is called once before the first entry, Entry once for each entry, and End once after
+// the last entry, even if there were no entries.
+type LDAPStreamFormatter interface {
+	Begin() error
+	Entry(e *ldap.Entry) error
+	End() error
+}
+
+// LDAPStreamOptions are options for stream formatters.
+type LDAPStreamOptions struct {
+	// Attributes are the requested attributes, used as columns by tabular formats.
+	// If empty, the attributes of the first entry are used, as they are in place of `*` and `+`.
+	Attributes []string
+
+	// MultiValue determines how tabular formats output attributes with multiple values. Defaults to MultiValueJoin.
+	MultiValue MultiValueMode
+
+	// Separator is used to join multiple values when MultiValue is MultiValueJoin. Defaults to a semicolon
+	// followed by a space for the table format, and a semicolon for other formats.
+	Separator string
+
+	// NoHeaders omits the header row of tabular formats.
+	NoHeaders bool
+
+	// Width is the maximum width of a line in the table format, such as the width of the terminal.
+	// Zero means no limit.
+	Width int
+
+	// Wrap wraps values that don't fit in their column in the table format, instead of truncating them.
+	Wrap bool
+
+	// Template is executed for each entry by the template format. See ParseLDAPTemplate.
+	Template *template.Template
+
+	// Redaction determines which attributes are masked or dropped. Defaults to DefaultRedactionPolicy, use
+	// NoRedactionPolicy to output all attributes as-is.
+	Redaction *RedactionPolicy
+
+	// Raw disables decoding of binary and Active Directory values. See DecodeLDAPEntry.
+	Raw bool
+
+	// Interpret makes the values of known Active Directory attributes, such as times and userAccountControl,
+	// human-readable. See InterpretLDAPEntry.
+	Interpret bool
+
+	// Domain, if set, returns the domain to label an entry with in formats that support it, or an empty string.
+	Domain func(dn string) string
+}
+
+// NewLDAPStreamFormatterFunc returns a new LDAPStreamFormatter that writes to w.
+type NewLDAPStreamFormatterFunc func(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter
+
+// LDAPStreamFormatters is a list of registered stream formatters.
+var LDAPStreamFormatters = map[string]NewLDAPStreamFormatterFunc{
+	"csv":         newCSVStreamFormatter,
+	"json":        newJSONStreamFormatter,
+	"json-pretty": newJSONPrettyStreamFormatter,
+	"jsonl":       newJSONLinesStreamFormatter,
+	"ldif":        newLDIFStreamFormatter,
+	"table":       newTableStreamFormatter,
+	"template":    newTemplateStreamFormatter,
+	"text":        newTextStreamFormatter,
+	"tsv":         newTSVStreamFormatter,
+	"yaml":        newYAMLStreamFormatter,
+	"yaml-stream": newYAMLDocumentsStreamFormatter,
+}
+
+// NewLDAPStreamFormatter returns an LDAPStreamFormatter for the given format that writes to w.
+// Formats without a registered stream formatter are adapted from their LDAPFormatter, which buffers the entries
+// until End is called. Attributes are redacted according to the Redaction option, values are decoded for display
+// unless the Raw option is set, and interpreted if the Interpret option is set. If format is an empty string,
+// `text` is used. opts may be nil.
+func NewLDAPStreamFormatter(w io.Writer, format string, opts *LDAPStreamOptions) (LDAPStreamFormatter, error) {
+	if len(format) == 0 {
+		format = "text"
+	}
+
+	if opts == nil {
+		opts = &LDAPStreamOptions{}
+	}
+
+	redaction := opts.Redaction
+	if redaction == nil {
+		redaction = DefaultRedactionPolicy()
+	}
+
+	// dropped attributes aren't output as columns either, even if they were requested
+	redactedOpts := *opts
+	redactedOpts.Attributes = redaction.RedactAttributes(opts.Attributes)
+
+	var s LDAPStreamFormatter
+	if fn := LDAPStreamFormatters[format]; fn != nil {
+		s = fn(w, &redactedOpts)
+	} else if f := LDAPFormatters[format]; f != nil {
+		s = NewBufferedStreamFormatter(w, f)
+	} else {
+		return nil, fmt.Errorf("unrecognized format: %s", format)
+	}
+
+	return &decodingStreamFormatter{
+		LDAPStreamFormatter: s,
+		redaction:           redaction,
+		decode:              !opts.Raw,
+		interpret:           opts.Interpret,
+	}, nil
+}
+
+// decodingStreamFormatter redacts, decodes and interprets the values of each entry before passing it to another
+// stream formatter.
+type decodingStreamFormatter struct {
+	LDAPStreamFormatter
+	redaction *RedactionPolicy
+	decode    bool
+	interpret bool
+}
+
+func (s *decodingStreamFormatter) Entry(e *ldap.Entry) error {
+	e = s.redaction.RedactLDAPEntry(e)
+	if s.decode {
+		e = DecodeLDAPEntry(e)
+	}
+	if s.interpret {
+		e = InterpretLDAPEntry(e)
+	}
+	return s.LDAPStreamFormatter.Entry(e)
+}
+
+// NewBufferedStreamFormatter adapts an LDAPFormatter to the LDAPStreamFormatter interface.
+// Entries are buffered and formatted when End is called, followed by a newline if the output doesn't end with one.
+func NewBufferedStreamFormatter(w io.Writer, f LDAPFormatter) LDAPStreamFormatter {
+	return &bufferedStreamFormatter{w: w, f: f, resp: &ldap.SearchResult{}}
+}
+
+type bufferedStreamFormatter struct {
+	w    io.Writer
+	f    LDAPFormatter
+	resp *ldap.SearchResult
+}
+
+func (s *bufferedStreamFormatter) Begin() error {
+	return nil
+}
+
+func (s *bufferedStreamFormatter) Entry(e *ldap.Entry) error {
+	s.resp.Entries = append(s.resp.Entries, e)
+	return nil
+}
+
+func (s *bufferedStreamFormatter) End() error {
+	b, err := s.f(s.resp)
+	if err != nil {
+		return err
+	}
+
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
+
+	_, err = s.w.Write(b)
+	return err
+}
+
+// WriteLDAPSearchResult writes all entries in the given search result using the stream formatter.
+func WriteLDAPSearchResult(s LDAPStreamFormatter, resp *ldap.SearchResult) error {
+	if err := s.Begin(); err != nil {
+		return err
+	}
+
+	for _, e := range resp.Entries {
+		if err := s.Entry(e); err != nil {
+			return err
+		}
+	}
+
+	return s.End()
+}
+
+// formatStream formats a search result in memory using a stream formatter, adapting it to the LDAPFormatter type.
+func formatStream(fn NewLDAPStreamFormatterFunc, resp *ldap.SearchResult) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := WriteLDAPSearchResult(fn(buf, &LDAPStreamOptions{}), resp); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ldapEntry converts an LDAP entry into an LDAPEntry, labeling its domain if configured.
+func ldapEntry(e *ldap.Entry, opts *LDAPStreamOptions) LDAPEntry {
+	entry := LDAPEntry{
+		DistinguishedName: e.DN,
+		Attributes:        []LDAPAttribute{},
+	}
+
+	for _, attr := range e.Attributes {
+		entry.Attributes = append(entry.Attributes, LDAPAttribute{
+			Name:   attr.Name,
+			Values: attr.Values,
+		})
+	}
+
+	if opts.Domain != nil {
+		entry.Domain = opts.Domain(e.DN)
+	}
+
+	return entry
+}
+
+// textStreamFormatter writes human-readable text, with entries separated by a blank line.
+type textStreamFormatter struct {
+	w       io.Writer
+	written int
+}
+
+func newTextStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	return &textStreamFormatter{w: w}
+}
+
+func (s *textStreamFormatter) Begin() error {
+	return nil
+}
+
+func (s *textStreamFormatter) Entry(e *ldap.Entry) error {
+	buf := bytes.Buffer{}
+
+	if s.written > 0 {
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString(fmt.Sprintf("Distinguished Name: %s\n", e.DN))
+	buf.WriteString(fmt.Sprintln("Attributes:"))
+
+	for _, attr := range e.Attributes {
+		buf.WriteString(fmt.Sprintf("  %s: %s\n", attr.Name, strings.Join(attr.Values, "; ")))
+	}
+
+	s.written++
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+func (s *textStreamFormatter) End() error {
+	return nil
+}
+
+// jsonStreamFormatter writes a JSON array of entries, optionally indented.
+type jsonStreamFormatter struct {
+	w       io.Writer
+	opts    *LDAPStreamOptions
+	indent  bool
+	written int
+}
+
+func newJSONStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	return &jsonStreamFormatter{w: w, opts: opts}
+}
+
+func newJSONPrettyStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	return &jsonStreamFormatter{w: w, opts: opts, indent: true}
+}
+
+func (s *jsonStreamFormatter) Begin() error {
+	_, err := io.WriteString(s.w, "[")
+	return err
+}
+
+func (s *jsonStreamFormatter) Entry(e *ldap.Entry) error {
+	var b []byte
+	var err error
+
+	// the output matches that of marshaling the whole array at once
+	sep := ","
+	if s.indent {
+		b, err = json.MarshalIndent(ldapEntry(e, s.opts), "  ", "  ")
+		sep = ",\n  "
+		if s.written == 0 {
+			sep = "\n  "
+		}
+	} else {
+		b, err = json.Marshal(ldapEntry(e, s.opts))
+		if s.written == 0 {
+			sep = ""
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.written++
+	_, err = s.w.Write(append([]byte(sep), b...))
+	return err
+}
+
+func (s *jsonStreamFormatter) End() error {
+	end := "]\n"
+	if s.indent && s.written > 0 {
+		end = "\n]\n"
+	}
+
+	_, err := io.WriteString(s.w, end)
+	return err
+}
+
+// jsonLinesStreamFormatter writes JSON Lines, one JSON object per entry.
+type jsonLinesStreamFormatter struct {
+	w    io.Writer
+	opts *LDAPStreamOptions
+}
+
+func newJSONLinesStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	return &jsonLinesStreamFormatter{w: w, opts: opts}
+}
+
+func (s *jsonLinesStreamFormatter) Begin() error {
+	return nil
+}
+
+func (s *jsonLinesStreamFormatter) Entry(e *ldap.Entry) error {
+	b, err := json.Marshal(ldapEntry(e, s.opts))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.w.Write(append(b, '\n'))
+	return err
+}
+
+func (s *jsonLinesStreamFormatter) End() error {
+	return nil
+}
+
+// ldifStreamFormatter writes LDIF, with the version header before the first entry.
+type ldifStreamFormatter struct {
+	w io.Writer
+}
+
+func newLDIFStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	return &ldifStreamFormatter{w: w}
+}
+
+func (s *ldifStreamFormatter) Begin() error {
+	_, err := io.WriteString(s.w, "version: 1\n")
+	return err
+}
+
+func (s *ldifStreamFormatter) Entry(e *ldap.Entry) error {
+	b, err := marshalLDIF(&ldap.SearchResult{Entries: []*ldap.Entry{e}}, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *ldifStreamFormatter) End() error {
+	return nil
+}
+
+// yamlStreamFormatter writes a YAML sequence of entries. YAML sequences written one after another form a single
+// sequence, so each entry is written as a sequence of one.
+type yamlStreamFormatter struct {
+	w       io.Writer
+	opts    *LDAPStreamOptions
+	written int
+}
+
+func newYAMLStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	return &yamlStreamFormatter{w: w, opts: opts}
+}
+
+func (s *yamlStreamFormatter) Begin() error {
+	return nil
+}
+
+func (s *yamlStreamFormatter) Entry(e *ldap.Entry) error {
+	b, err := yaml.Marshal([]LDAPEntry{ldapEntry(e, s.opts)})
+	if err != nil {
+		return err
+	}
+
+	s.written++
+	_, err = s.w.Write(b)
+	return err
+}
+
+func (s *yamlStreamFormatter) End() error {
+	if s.written > 0 {
+		return nil
+	}
+
+	_, err := io.WriteString(s.w, "[]\n")
+	return err
+}
+
+// yamlDocumentsStreamFormatter writes a YAML stream with one document per entry.
+type yamlDocumentsStreamFormatter struct {
+	w    io.Writer
+	opts *LDAPStreamOptions
+}
+
+func newYAMLDocumentsStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	return &yamlDocumentsStreamFormatter{w: w, opts: opts}
+}
+
+func (s *yamlDocumentsStreamFormatter) Begin() error {
+	return nil
+}
+
+func (s *yamlDocumentsStreamFormatter) Entry(e *ldap.Entry) error {
+	b, err := yaml.Marshal(ldapEntry(e, s.opts))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.w.Write(append([]byte("---\n"), b...))
+	return err
+}
+
+func (s *yamlDocumentsStreamFormatter) End() error {
+	return nil
+}
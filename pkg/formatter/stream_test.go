@@ -0,0 +1,199 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func testSearchResult() *ldap.SearchResult {
+	return &ldap.SearchResult{
+		Entries: []*ldap.Entry{
+			ldap.NewEntry("cn=newton,dc=example,dc=com", map[string][]string{
+				"cn":   {"newton"},
+				"mail": {"newton@example.com"},
+			}),
+			ldap.NewEntry("cn=einstein,dc=example,dc=com", map[string][]string{
+				"cn":          {"einstein"},
+				"mail":        {"einstein@example.com", "albert@example.com"},
+				"description": {"physicist"},
+			}),
+		},
+	}
+}
+
+// streamFormat writes the search result using the stream formatter of the given format, with no redaction or
+// decoding.
+func streamFormat(t *testing.T, format string, opts *LDAPStreamOptions, resp *ldap.SearchResult) string {
+	if opts == nil {
+		opts = &LDAPStreamOptions{}
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteLDAPSearchResult(LDAPStreamFormatters[format](buf, opts), resp))
+	return buf.String()
+}
+
+func TestStreamFormattersMatchFormatters(t *testing.T) {
+	for format, f := range LDAPFormatters {
+		if LDAPStreamFormatters[format] == nil {
+			continue
+		}
+
+		for _, resp := range []*ldap.SearchResult{testSearchResult(), {}} {
+			expected, err := f(resp)
+			require.NoError(t, err, format)
+
+			// stream formatters always end with a newline
+			actual := streamFormat(t, format, nil, resp)
+			require.Equal(t, strings.TrimSuffix(string(expected), "\n"), strings.TrimSuffix(actual, "\n"), format)
+		}
+	}
+}
+
+func TestStreamFormattersEmpty(t *testing.T) {
+	expected := map[string]string{
+		"csv":         "dn\n",
+		"json":        "[]\n",
+		"json-pretty": "[]\n",
+		"jsonl":       "",
+		"ldif":        "version: 1\n",
+		"table":       "",
+		"text":        "",
+		"tsv":         "dn\n",
+		"yaml":        "[]\n",
+		"yaml-stream": "",
+	}
+
+	for format, output := range expected {
+		require.Equal(t, output, streamFormat(t, format, nil, &ldap.SearchResult{}), format)
+	}
+}
+
+func TestStreamFormatters(t *testing.T) {
+	resp := testSearchResult()
+
+	pretty := streamFormat(t, "json-pretty", nil, resp)
+	require.True(t, strings.HasPrefix(pretty, "[\n  {\n    \"distinguishedName\": \"cn=newton,dc=example,dc=com\",\n"))
+	require.True(t, strings.HasSuffix(pretty, "  }\n]\n"))
+
+	lines := strings.Split(strings.TrimSuffix(streamFormat(t, "jsonl", nil, resp), "\n"), "\n")
+	require.Len(t, lines, 2)
+	require.True(t, strings.HasPrefix(lines[1], `{"distinguishedName":"cn=einstein,dc=example,dc=com"`))
+
+	require.Equal(t, 2, strings.Count(streamFormat(t, "yaml-stream", nil, resp), "---\n"))
+
+	text := streamFormat(t, "text", nil, resp)
+	require.Contains(t, text, "Distinguished Name: cn=newton,dc=example,dc=com\nAttributes:\n  cn: newton\n")
+	require.Contains(t, text, "\n\nDistinguished Name: cn=einstein,dc=example,dc=com\n")
+	require.Contains(t, text, "  mail: einstein@example.com; albert@example.com\n")
+
+	ldif := streamFormat(t, "ldif", nil, resp)
+	require.True(t, strings.HasPrefix(ldif, "version: 1\n"))
+	require.Equal(t, 1, strings.Count(ldif, "version:"))
+	require.Contains(t, ldif, "dn: cn=einstein,dc=example,dc=com\n")
+
+	// entries are labeled with their domain if configured
+	opts := &LDAPStreamOptions{Domain: func(dn string) string { return "example.com" }}
+	require.Contains(t, streamFormat(t, "jsonl", opts, resp), `"domain":"example.com"`)
+}
+
+func TestNewLDAPStreamFormatter(t *testing.T) {
+	_, err := NewLDAPStreamFormatter(&bytes.Buffer{}, "xml", nil)
+	require.Error(t, err)
+
+	// the text format is used by default
+	buf := &bytes.Buffer{}
+	s, err := NewLDAPStreamFormatter(buf, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, WriteLDAPSearchResult(s, testSearchResult()))
+	require.True(t, strings.HasPrefix(buf.String(), "Distinguished Name: "))
+
+	// secrets are redacted by default
+	resp := &ldap.SearchResult{Entries: []*ldap.Entry{
+		ldap.NewEntry("cn=newton,dc=example,dc=com", map[string][]string{"unicodePwd": {"secret"}}),
+	}}
+
+	buf.Reset()
+	s, err = NewLDAPStreamFormatter(buf, "jsonl", nil)
+	require.NoError(t, err)
+	require.NoError(t, WriteLDAPSearchResult(s, resp))
+	require.NotContains(t, buf.String(), "secret")
+	require.Contains(t, buf.String(), RedactedValue)
+
+	buf.Reset()
+	s, err = NewLDAPStreamFormatter(buf, "jsonl", &LDAPStreamOptions{Redaction: NoRedactionPolicy()})
+	require.NoError(t, err)
+	require.NoError(t, WriteLDAPSearchResult(s, resp))
+	require.Contains(t, buf.String(), "secret")
+}
+
+func TestBufferedStreamFormatter(t *testing.T) {
+	calls := 0
+	f := func(resp *ldap.SearchResult) ([]byte, error) {
+		calls++
+		return []byte(fmt.Sprintf("%d entries", len(resp.Entries))), nil
+	}
+
+	buf := &bytes.Buffer{}
+	s := NewBufferedStreamFormatter(buf, f)
+	require.NoError(t, s.Begin())
+	for _, e := range testSearchResult().Entries {
+		require.NoError(t, s.Entry(e))
+	}
+
+	// nothing is formatted until End is called
+	require.Zero(t, calls)
+	require.Zero(t, buf.Len())
+
+	require.NoError(t, s.End())
+	require.Equal(t, 1, calls)
+	require.Equal(t, "2 entries\n", buf.String())
+
+	// output that already ends with a newline isn't given another, and empty output stays empty
+	for output, expected := range map[string]string{"done\n": "done\n", "": ""} {
+		output := output
+		buf.Reset()
+		s = NewBufferedStreamFormatter(buf, func(resp *ldap.SearchResult) ([]byte, error) {
+			return []byte(output), nil
+		})
+		require.NoError(t, WriteLDAPSearchResult(s, &ldap.SearchResult{}))
+		require.Equal(t, expected, buf.String())
+	}
+
+	buf.Reset()
+	s = NewBufferedStreamFormatter(buf, func(resp *ldap.SearchResult) ([]byte, error) {
+		return nil, fmt.Errorf("failed")
+	})
+	require.Error(t, WriteLDAPSearchResult(s, testSearchResult()))
+	require.Zero(t, buf.Len())
+}
+
+func TestLDAPPageWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	p, err := NewLDAPPageWriter(buf, "json", nil)
+	require.NoError(t, err)
+
+	// nothing is written before the first page
+	require.Zero(t, buf.Len())
+
+	resp := testSearchResult()
+	require.NoError(t, p.WritePage(&ldap.SearchResult{Entries: resp.Entries[:1]}))
+	require.NoError(t, p.WritePage(&ldap.SearchResult{Entries: resp.Entries[1:]}))
+	require.NoError(t, p.Close())
+
+	expected, err := LDAPFormatterJSON(resp)
+	require.NoError(t, err)
+	require.Equal(t, string(expected)+"\n", buf.String())
+
+	// closing without any pages still writes an empty result
+	buf.Reset()
+	p, err = NewLDAPPageWriter(buf, "json", nil)
+	require.NoError(t, err)
+	require.NoError(t, p.Close())
+	require.Equal(t, "[]\n", buf.String())
+}
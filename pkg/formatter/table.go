@@ -0,0 +1,236 @@
+package formatter
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	// tableGap is the space between table columns.
+	tableGap = "  "
+
+	// tableMinColumnWidth is the narrowest a table column is shrunk to when fitting the table to a width.
+	tableMinColumnWidth = 4
+
+	// tableEllipsis marks values that were truncated to fit their column.
+	tableEllipsis = "…"
+)
+
+// LDAPFormatterTable outputs an aligned table with one row per entry, using the attributes of the first entry
+// as columns.
+func LDAPFormatterTable(resp *ldap.SearchResult) ([]byte, error) {
+	return formatStream(newTableStreamFormatter, resp)
+}
+
+// tableStreamFormatter writes an aligned table with a header row and one row per entry, with the DN in the first
+// column, and one column for each requested attribute in the order requested. If no attributes were requested, the
+// attributes of the first entry are used in alphabetical order, as they are in place of `*` and `+`. Since the
+// widths of the columns depend on every entry, rows are buffered and the table is written when End is called. If a
+// width is given, the widest columns are shrunk until the table fits, and values that don't fit are truncated with
+// an ellipsis or wrapped onto more lines.
+type tableStreamFormatter struct {
+	w         io.Writer
+	columns   []string
+	expanded  bool
+	rows      [][]string
+	noHeaders bool
+	mode      MultiValueMode
+	separator string
+	width     int
+	wrap      bool
+}
+
+func newTableStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	s := &tableStreamFormatter{
+		w:         w,
+		columns:   opts.Attributes,
+		noHeaders: opts.NoHeaders,
+		mode:      opts.MultiValue,
+		separator: opts.Separator,
+		width:     opts.Width,
+		wrap:      opts.Wrap,
+	}
+
+	if len(s.mode) == 0 {
+		s.mode = MultiValueJoin
+	}
+	if len(s.separator) == 0 {
+		s.separator = "; "
+	}
+
+	return s
+}
+
+func (s *tableStreamFormatter) Begin() error {
+	return nil
+}
+
+func (s *tableStreamFormatter) Entry(e *ldap.Entry) error {
+	if !s.expanded {
+		s.columns = entryColumns(s.columns, e)
+		s.expanded = true
+	}
+
+	values := columnValues(e, s.columns)
+	rows := multiValueRows(values, s.mode)
+
+	for r := 0; r < rows; r++ {
+		row := []string{e.DN}
+		for _, v := range values {
+			row = append(row, multiValueField(v, r, s.mode, s.separator))
+		}
+		s.rows = append(s.rows, row)
+	}
+
+	return nil
+}
+
+func (s *tableStreamFormatter) End() error {
+	if !s.expanded {
+		s.columns = entryColumns(s.columns, nil)
+	}
+	if len(s.rows) == 0 && len(s.columns) == 0 {
+		return nil
+	}
+
+	rows := s.rows
+	if !s.noHeaders {
+		header := []string{"DN"}
+		for _, col := range s.columns {
+			header = append(header, strings.ToUpper(col))
+		}
+		rows = append([][]string{header}, rows...)
+	}
+
+	widths := s.columnWidths(rows)
+	buf := bytes.Buffer{}
+
+	for _, row := range rows {
+		// each cell is split into the lines it occupies, and the row is as tall as its tallest cell
+		cells := make([][]string, len(row))
+		height := 1
+		for i, value := range row {
+			cells[i] = s.fit(value, widths[i])
+			if len(cells[i]) > height {
+				height = len(cells[i])
+			}
+		}
+
+		for l := 0; l < height; l++ {
+			line := strings.Builder{}
+			for i, cell := range cells {
+				value := ""
+				if l < len(cell) {
+					value = cell[l]
+				}
+
+				if i > 0 {
+					line.WriteString(tableGap)
+				}
+				line.WriteString(value)
+
+				// pad all but the last column
+				if i < len(cells)-1 {
+					line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value)))
+				}
+			}
+
+			buf.WriteString(strings.TrimRight(line.String(), " "))
+			buf.WriteString("\n")
+		}
+	}
+
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+// columnWidths returns the width of each column, shrinking the widest columns until the table fits the width.
+func (s *tableStreamFormatter) columnWidths(rows [][]string) []int {
+	widths := make([]int, len(s.columns)+1)
+	for _, row := range rows {
+		for i, value := range row {
+			if n := utf8.RuneCountInString(value); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	if s.width <= 0 {
+		return widths
+	}
+
+	available := s.width - len(tableGap)*(len(widths)-1)
+	total := 0
+	for _, w := range widths {
+		total += w
+	}
+
+	for total > available {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+
+		if widths[widest] <= tableMinColumnWidth {
+			break
+		}
+
+		widths[widest]--
+		total--
+	}
+
+	return widths
+}
+
+// fit returns the lines needed to show value in a column of the given width, which is a single truncated line
+// unless wrapping is enabled.
+func (s *tableStreamFormatter) fit(value string, width int) []string {
+	if utf8.RuneCountInString(value) <= width {
+		return []string{value}
+	}
+
+	if !s.wrap {
+		return []string{string([]rune(value)[:width-1]) + tableEllipsis}
+	}
+
+	return wrapText(value, width)
+}
+
+// wrapText splits text into lines no wider than width, breaking at spaces where possible.
+func wrapText(text string, width int) []string {
+	lines := []string{}
+	line := []rune{}
+
+	for _, word := range strings.Fields(text) {
+		w := []rune(word)
+
+		if len(line) > 0 && len(line)+1+len(w) <= width {
+			line = append(append(line, ' '), w...)
+			continue
+		}
+
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+			line = nil
+		}
+
+		// words longer than the width are split
+		for len(w) > width {
+			lines = append(lines, string(w[:width]))
+			w = w[width:]
+		}
+		line = w
+	}
+
+	if len(line) > 0 || len(lines) == 0 {
+		lines = append(lines, string(line))
+	}
+
+	return lines
+}
@@ -0,0 +1,105 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func tableSearchResult() *ldap.SearchResult {
+	return &ldap.SearchResult{Entries: []*ldap.Entry{
+		ldap.NewEntry("cn=newton", map[string][]string{
+			"cn":          {"newton"},
+			"description": {"discovered the laws of motion"},
+		}),
+		ldap.NewEntry("cn=curie", map[string][]string{
+			"cn":          {"curie"},
+			"description": {"radioactivity"},
+		}),
+	}}
+}
+
+func TestTableStreamFormatter(t *testing.T) {
+	require.Equal(t, ""+
+		"DN         CN      DESCRIPTION\n"+
+		"cn=newton  newton  discovered the laws of motion\n"+
+		"cn=curie   curie   radioactivity\n",
+		streamFormat(t, "table", nil, tableSearchResult()))
+
+	opts := &LDAPStreamOptions{Attributes: []string{"description"}, NoHeaders: true}
+	require.Equal(t, ""+
+		"cn=newton  discovered the laws of motion\n"+
+		"cn=curie   radioactivity\n",
+		streamFormat(t, "table", opts, tableSearchResult()))
+
+	// nothing is written without entries or requested attributes
+	require.Equal(t, "", streamFormat(t, "table", nil, &ldap.SearchResult{}))
+
+	opts = &LDAPStreamOptions{Attributes: []string{"cn", "*"}}
+	require.Equal(t, "DN  CN\n", streamFormat(t, "table", opts, &ldap.SearchResult{}))
+}
+
+func TestTableWildcardAttributes(t *testing.T) {
+	opts := &LDAPStreamOptions{Attributes: []string{"description", "+", "*"}}
+	require.Equal(t, ""+
+		"DN         DESCRIPTION                    CN\n"+
+		"cn=newton  discovered the laws of motion  newton\n"+
+		"cn=curie   radioactivity                  curie\n",
+		streamFormat(t, "table", opts, tableSearchResult()))
+}
+
+func TestTableMultiValue(t *testing.T) {
+	resp := &ldap.SearchResult{Entries: []*ldap.Entry{
+		ldap.NewEntry("cn=einstein", map[string][]string{
+			"cn":   {"einstein"},
+			"mail": {"einstein@example.com", "albert@example.com"},
+		}),
+	}}
+
+	require.Equal(t, ""+
+		"DN           CN        MAIL\n"+
+		"cn=einstein  einstein  einstein@example.com; albert@example.com\n",
+		streamFormat(t, "table", nil, resp))
+
+	opts := &LDAPStreamOptions{MultiValue: MultiValueExplode}
+	require.Equal(t, ""+
+		"DN           CN        MAIL\n"+
+		"cn=einstein  einstein  einstein@example.com\n"+
+		"cn=einstein  einstein  albert@example.com\n",
+		streamFormat(t, "table", opts, resp))
+}
+
+func TestTableWidth(t *testing.T) {
+	// the widest column is shrunk until the table fits, and values that don't fit are truncated
+	opts := &LDAPStreamOptions{Width: 40}
+	require.Equal(t, ""+
+		"DN         CN      DESCRIPTION\n"+
+		"cn=newton  newton  discovered the laws …\n"+
+		"cn=curie   curie   radioactivity\n",
+		streamFormat(t, "table", opts, tableSearchResult()))
+
+	// or wrapped onto more lines, breaking at spaces
+	opts = &LDAPStreamOptions{Width: 40, Wrap: true}
+	require.Equal(t, ""+
+		"DN         CN      DESCRIPTION\n"+
+		"cn=newton  newton  discovered the laws\n"+
+		"                   of motion\n"+
+		"cn=curie   curie   radioactivity\n",
+		streamFormat(t, "table", opts, tableSearchResult()))
+
+	// columns aren't shrunk below the minimum width, even if the table doesn't fit
+	opts = &LDAPStreamOptions{Width: 10}
+	require.Equal(t, ""+
+		"DN    CN    DES…\n"+
+		"cn=…  new…  dis…\n"+
+		"cn=…  cur…  rad…\n",
+		streamFormat(t, "table", opts, tableSearchResult()))
+}
+
+func TestWrapText(t *testing.T) {
+	require.Equal(t, []string{"the quick", "brown fox"}, wrapText("the quick brown fox", 10))
+	require.Equal(t, []string{"abcd", "efgh", "ij"}, wrapText("abcdefghij", 4))
+	require.Equal(t, []string{"a", "abcd", "ef b"}, wrapText("a abcdef b", 4))
+	require.Equal(t, []string{""}, wrapText("", 4))
+}
@@ -0,0 +1,143 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/deejross/direktor/pkg/ldapcli"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// LDAPTemplateEntry is the data a template is executed with for each entry. Attrs maps the attribute names,
+// as returned by the server, to their values.
+type LDAPTemplateEntry struct {
+	DN    string
+	Attrs map[string][]string
+}
+
+// LDAPTemplateFuncs are the functions available to templates:
+//
+//	first VALUES           the first value, or an empty string
+//	join SEP VALUES        the values joined with SEP
+//	attr ENTRY NAME        the values of the named attribute, ignoring case
+//	domain DN              the domain of the DN in dot notation, e.g. example.com
+//	basedn DN              the base portion of the DN, e.g. dc=example,dc=com
+//	date LAYOUT VALUE      a GeneralizedTime or FILETIME value formatted with the Go time layout,
+//	                       or an empty string for times that mean never
+var LDAPTemplateFuncs = template.FuncMap{
+	"first":  templateFirst,
+	"join":   templateJoin,
+	"attr":   templateAttr,
+	"domain": ldapcli.ParseDomainFromDN,
+	"basedn": ldapcli.ParseBaseDN,
+	"date":   templateDate,
+}
+
+// ParseLDAPTemplate parses a template for the template format, with LDAPTemplateFuncs available.
+func ParseLDAPTemplate(text string) (*template.Template, error) {
+	return template.New("entry").Funcs(LDAPTemplateFuncs).Parse(text)
+}
+
+// templateStreamFormatter executes a template for each entry. A newline is written after each entry if the
+// output for the entry doesn't end with one.
+type templateStreamFormatter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func newTemplateStreamFormatter(w io.Writer, opts *LDAPStreamOptions) LDAPStreamFormatter {
+	return &templateStreamFormatter{w: w, tmpl: opts.Template}
+}
+
+func (s *templateStreamFormatter) Begin() error {
+	if s.tmpl == nil {
+		return fmt.Errorf("the template format requires a template")
+	}
+	return nil
+}
+
+func (s *templateStreamFormatter) Entry(e *ldap.Entry) error {
+	data := LDAPTemplateEntry{
+		DN:    e.DN,
+		Attrs: map[string][]string{},
+	}
+
+	for _, attr := range e.Attributes {
+		data.Attrs[attr.Name] = attr.Values
+	}
+
+	buf := bytes.Buffer{}
+	if err := s.tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	if b := buf.Bytes(); len(b) > 0 && b[len(b)-1] != '\n' {
+		buf.WriteString("\n")
+	}
+
+	_, err := s.w.Write(buf.Bytes())
+	return err
+}
+
+func (s *templateStreamFormatter) End() error {
+	return nil
+}
+
+func templateFirst(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func templateJoin(sep string, values []string) string {
+	return strings.Join(values, sep)
+}
+
+func templateAttr(entry LDAPTemplateEntry, name string) []string {
+	for attr, values := range entry.Attrs {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func templateDate(layout string, value interface{}) (string, error) {
+	var s string
+
+	switch v := value.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return "", nil
+		}
+		return v.Format(layout), nil
+	case []string:
+		s = templateFirst(v)
+	case string:
+		s = v
+	default:
+		return "", fmt.Errorf("date: unsupported value type: %T", value)
+	}
+
+	if len(s) == 0 {
+		return "", nil
+	}
+
+	t, err := ldapcli.ParseGeneralizedTime(s)
+	if err != nil {
+		if t, err = ldapcli.ParseFileTime(s); err != nil {
+			return "", fmt.Errorf("date: not a GeneralizedTime or FILETIME value: %s", s)
+		}
+	}
+
+	if t.IsZero() {
+		return "", nil
+	}
+
+	return t.Format(layout), nil
+}
@@ -0,0 +1,95 @@
+package formatter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateStreamFormatter(t *testing.T) {
+	tmpl, err := ParseLDAPTemplate(`{{.DN}}: {{first .Attrs.cn}} <{{join ", " (attr . "MAIL")}}>`)
+	require.NoError(t, err)
+
+	// a newline is added after each entry unless the template ends with one
+	opts := &LDAPStreamOptions{Template: tmpl}
+	require.Equal(t, ""+
+		"cn=newton,dc=example,dc=com: newton <newton@example.com>\n"+
+		"cn=einstein,dc=example,dc=com: einstein <einstein@example.com, albert@example.com>\n",
+		streamFormat(t, "template", opts, testSearchResult()))
+
+	tmpl, err = ParseLDAPTemplate("{{domain .DN}} {{basedn .DN}}\n")
+	require.NoError(t, err)
+
+	opts = &LDAPStreamOptions{Template: tmpl}
+	resp := &ldap.SearchResult{Entries: testSearchResult().Entries[:1]}
+	require.Equal(t, "example.com dc=example,dc=com\n", streamFormat(t, "template", opts, resp))
+
+	// the template format can't be used without a template
+	s := LDAPStreamFormatters["template"](&bytes.Buffer{}, &LDAPStreamOptions{})
+	require.Error(t, s.Begin())
+
+	_, err = ParseLDAPTemplate("{{upper .DN}}")
+	require.Error(t, err)
+}
+
+func TestTemplateFirst(t *testing.T) {
+	require.Equal(t, "a", templateFirst([]string{"a", "b"}))
+	require.Equal(t, "", templateFirst(nil))
+}
+
+func TestTemplateJoin(t *testing.T) {
+	require.Equal(t, "a;b", templateJoin(";", []string{"a", "b"}))
+	require.Equal(t, "", templateJoin(";", nil))
+}
+
+func TestTemplateAttr(t *testing.T) {
+	entry := LDAPTemplateEntry{
+		DN:    "cn=newton",
+		Attrs: map[string][]string{"sAMAccountName": {"newton"}},
+	}
+
+	require.Equal(t, []string{"newton"}, templateAttr(entry, "sAMAccountName"))
+	require.Equal(t, []string{"newton"}, templateAttr(entry, "samaccountname"))
+	require.Nil(t, templateAttr(entry, "mail"))
+}
+
+func TestTemplateDate(t *testing.T) {
+	layout := "2006-01-02 15:04"
+
+	s, err := templateDate(layout, "20200102150405.0Z")
+	require.NoError(t, err)
+	require.Equal(t, "2020-01-02 15:04", s)
+
+	s, err = templateDate(layout, []string{"132223104000000000", "0"})
+	require.NoError(t, err)
+	require.Equal(t, "2020-01-01 00:00", s)
+
+	s, err = templateDate(layout, time.Date(2020, 1, 2, 15, 4, 0, 0, time.UTC))
+	require.NoError(t, err)
+	require.Equal(t, "2020-01-02 15:04", s)
+
+	// times that mean never, and missing values, are empty
+	for _, value := range []interface{}{"0", "9223372036854775807", "", []string{}, time.Time{}} {
+		s, err = templateDate(layout, value)
+		require.NoError(t, err)
+		require.Empty(t, s)
+	}
+
+	_, err = templateDate(layout, "yesterday")
+	require.Error(t, err)
+
+	_, err = templateDate(layout, 42)
+	require.Error(t, err)
+
+	// the function is available to templates
+	tmpl, err := ParseLDAPTemplate(`{{date "2006" (attr . "whenCreated")}}`)
+	require.NoError(t, err)
+
+	resp := &ldap.SearchResult{Entries: []*ldap.Entry{
+		ldap.NewEntry("cn=newton", map[string][]string{"whenCreated": {"20200102150405.0Z"}}),
+	}}
+	require.Equal(t, "2020\n", streamFormat(t, "template", &LDAPStreamOptions{Template: tmpl}, resp))
+}
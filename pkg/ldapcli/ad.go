@@ -0,0 +1,284 @@
+package ldapcli
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ParseSID parses a binary security identifier, as used by Active Directory attributes such as objectSid,
+// into its string form, such as `S-1-5-21-3623811015-3361044348-30300820-1013`.
+func ParseSID(b []byte) (string, error) {
+	if len(b) < 8 || len(b) != 8+4*int(b[1]) {
+		return "", fmt.Errorf("invalid SID: length %d", len(b))
+	}
+
+	// the identifier authority is a 48-bit big-endian value
+	authority := uint64(0)
+	for _, v := range b[2:8] {
+		authority = authority<<8 | uint64(v)
+	}
+
+	sid := strings.Builder{}
+	sid.WriteString(fmt.Sprintf("S-%d-%d", b[0], authority))
+
+	// followed by little-endian 32-bit sub-authorities
+	for i := 8; i < len(b); i += 4 {
+		sid.WriteString(fmt.Sprintf("-%d", binary.LittleEndian.Uint32(b[i:i+4])))
+	}
+
+	return sid.String(), nil
+}
+
+// ParseGUID parses a binary GUID, as used by Active Directory attributes such as objectGUID, into its
+// canonical form, such as `a1b2c3d4-e5f6-a7b8-c9d0-e1f2a3b4c5d6`.
+func ParseGUID(b []byte) (string, error) {
+	if len(b) != 16 {
+		return "", fmt.Errorf("invalid GUID: length %d", len(b))
+	}
+
+	// the first three groups are little-endian, the rest are in byte order
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		b[8:10],
+		b[10:16],
+	), nil
+}
+
+const (
+	// AttributeAccountExpires is the name of the Active Directory accountExpires attribute, a FILETIME.
+	AttributeAccountExpires = "accountExpires"
+
+	// AttributeBadPasswordTime is the name of the Active Directory badPasswordTime attribute, a FILETIME.
+	AttributeBadPasswordTime = "badPasswordTime"
+
+	// AttributeLastLogon is the name of the Active Directory lastLogon attribute, a FILETIME.
+	AttributeLastLogon = "lastLogon"
+
+	// AttributeLastLogonTimestamp is the name of the Active Directory lastLogonTimestamp attribute, a FILETIME.
+	AttributeLastLogonTimestamp = "lastLogonTimestamp"
+
+	// AttributeLockoutTime is the name of the Active Directory lockoutTime attribute, a FILETIME.
+	AttributeLockoutTime = "lockoutTime"
+
+	// AttributePasswordLastSet is the name of the Active Directory pwdLastSet attribute, a FILETIME.
+	AttributePasswordLastSet = "pwdLastSet"
+
+	// AttributeUserAccountControl is the name of the Active Directory userAccountControl attribute, a bitmask.
+	AttributeUserAccountControl = "userAccountControl"
+
+	// AttributeWhenChanged is the name of the whenChanged attribute, a GeneralizedTime.
+	AttributeWhenChanged = "whenChanged"
+
+	// AttributeWhenCreated is the name of the whenCreated attribute, a GeneralizedTime.
+	AttributeWhenCreated = "whenCreated"
+)
+
+// ParseGeneralizedTime parses an LDAP GeneralizedTime value, such as `20200102150405.0Z`, as used by
+// attributes such as whenCreated.
+func ParseGeneralizedTime(value string) (time.Time, error) {
+	// fractional seconds are accepted after the seconds even though the layout doesn't include them
+	for _, layout := range []string{"20060102150405Z0700", "200601021504Z0700", "2006010215Z0700"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid GeneralizedTime: %s", value)
+}
+
+// fileTimeEpoch is the number of 100-nanosecond intervals between 1601-01-01 and 1970-01-01 UTC.
+const fileTimeEpoch = 116444736000000000
+
+// ParseFileTime parses a Windows FILETIME value, the number of 100-nanosecond intervals since 1601-01-01 UTC,
+// as used by AD attributes such as pwdLastSet. The zero time is returned for 0 and 9223372036854775807, which
+// AD uses to mean never.
+func ParseFileTime(value string) (time.Time, error) {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid FILETIME: %s", value)
+	}
+
+	if v == 0 || v == math.MaxInt64 {
+		return time.Time{}, nil
+	}
+
+	v -= fileTimeEpoch
+	return time.Unix(v/1e7, (v%1e7)*100).UTC(), nil
+}
+
+// TimeSyntax is the encoding of an attribute that holds a time.
+type TimeSyntax int
+
+const (
+	// TimeSyntaxNone is used for attributes that don't hold a time.
+	TimeSyntaxNone TimeSyntax = iota
+
+	// TimeSyntaxGeneralizedTime is used for attributes that hold an LDAP GeneralizedTime. See ParseGeneralizedTime.
+	TimeSyntaxGeneralizedTime
+
+	// TimeSyntaxFileTime is used for attributes that hold a Windows FILETIME. See ParseFileTime.
+	TimeSyntaxFileTime
+)
+
+// TimeAttributes maps the lower case names of known time attributes to their syntax.
+var TimeAttributes = map[string]TimeSyntax{
+	strings.ToLower(AttributeAccountExpires):     TimeSyntaxFileTime,
+	strings.ToLower(AttributeBadPasswordTime):    TimeSyntaxFileTime,
+	strings.ToLower(AttributeLastLogon):          TimeSyntaxFileTime,
+	"lastlogoff":                                 TimeSyntaxFileTime,
+	strings.ToLower(AttributeLastLogonTimestamp): TimeSyntaxFileTime,
+	strings.ToLower(AttributeLockoutTime):        TimeSyntaxFileTime,
+	strings.ToLower(AttributePasswordLastSet):    TimeSyntaxFileTime,
+	"msds-userpasswordexpirytimecomputed":        TimeSyntaxFileTime,
+	strings.ToLower(AttributeWhenChanged):        TimeSyntaxGeneralizedTime,
+	strings.ToLower(AttributeWhenCreated):        TimeSyntaxGeneralizedTime,
+	"createtimestamp":                            TimeSyntaxGeneralizedTime,
+	"modifytimestamp":                            TimeSyntaxGeneralizedTime,
+}
+
+// ParseTimeAttribute parses the value of a known time attribute. The zero time is returned for values that
+// mean never, such as an accountExpires of 0 or 9223372036854775807, or a lastLogonTimestamp of 0.
+func ParseTimeAttribute(attribute, value string) (time.Time, error) {
+	switch TimeAttributes[strings.ToLower(attribute)] {
+	case TimeSyntaxFileTime:
+		return ParseFileTime(value)
+	case TimeSyntaxGeneralizedTime:
+		return ParseGeneralizedTime(value)
+	}
+
+	return time.Time{}, fmt.Errorf("not a time attribute: %s", attribute)
+}
+
+// EntryTime returns the value of a known time attribute of the entry. The zero time is returned if the attribute
+// is missing or its value means never. See ParseTimeAttribute.
+func EntryTime(e *ldap.Entry, attribute string) (time.Time, error) {
+	value := e.GetEqualFoldAttributeValue(attribute)
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+
+	return ParseTimeAttribute(attribute, value)
+}
+
+// UserAccountControl is the value of the Active Directory userAccountControl attribute.
+type UserAccountControl uint32
+
+// Flags of the userAccountControl attribute.
+const (
+	UACScript                       UserAccountControl = 0x0001
+	UACAccountDisable               UserAccountControl = 0x0002
+	UACHomeDirRequired              UserAccountControl = 0x0008
+	UACLockout                      UserAccountControl = 0x0010
+	UACPasswordNotRequired          UserAccountControl = 0x0020
+	UACPasswordCantChange           UserAccountControl = 0x0040
+	UACEncryptedTextPasswordAllowed UserAccountControl = 0x0080
+	UACTempDuplicateAccount         UserAccountControl = 0x0100
+	UACNormalAccount                UserAccountControl = 0x0200
+	UACInterdomainTrustAccount      UserAccountControl = 0x0800
+	UACWorkstationTrustAccount      UserAccountControl = 0x1000
+	UACServerTrustAccount           UserAccountControl = 0x2000
+	UACDontExpirePassword           UserAccountControl = 0x10000
+	UACMNSLogonAccount              UserAccountControl = 0x20000
+	UACSmartcardRequired            UserAccountControl = 0x40000
+	UACTrustedForDelegation         UserAccountControl = 0x80000
+	UACNotDelegated                 UserAccountControl = 0x100000
+	UACUseDESKeyOnly                UserAccountControl = 0x200000
+	UACDontRequirePreauth           UserAccountControl = 0x400000
+	UACPasswordExpired              UserAccountControl = 0x800000
+	UACTrustedToAuthForDelegation   UserAccountControl = 0x1000000
+	UACPartialSecretsAccount        UserAccountControl = 0x4000000
+)
+
+// uacFlagNames are the names of the userAccountControl flags, in order of their values.
+var uacFlagNames = []struct {
+	flag UserAccountControl
+	name string
+}{
+	{UACScript, "SCRIPT"},
+	{UACAccountDisable, "ACCOUNTDISABLE"},
+	{UACHomeDirRequired, "HOMEDIR_REQUIRED"},
+	{UACLockout, "LOCKOUT"},
+	{UACPasswordNotRequired, "PASSWD_NOTREQD"},
+	{UACPasswordCantChange, "PASSWD_CANT_CHANGE"},
+	{UACEncryptedTextPasswordAllowed, "ENCRYPTED_TEXT_PWD_ALLOWED"},
+	{UACTempDuplicateAccount, "TEMP_DUPLICATE_ACCOUNT"},
+	{UACNormalAccount, "NORMAL_ACCOUNT"},
+	{UACInterdomainTrustAccount, "INTERDOMAIN_TRUST_ACCOUNT"},
+	{UACWorkstationTrustAccount, "WORKSTATION_TRUST_ACCOUNT"},
+	{UACServerTrustAccount, "SERVER_TRUST_ACCOUNT"},
+	{UACDontExpirePassword, "DONT_EXPIRE_PASSWORD"},
+	{UACMNSLogonAccount, "MNS_LOGON_ACCOUNT"},
+	{UACSmartcardRequired, "SMARTCARD_REQUIRED"},
+	{UACTrustedForDelegation, "TRUSTED_FOR_DELEGATION"},
+	{UACNotDelegated, "NOT_DELEGATED"},
+	{UACUseDESKeyOnly, "USE_DES_KEY_ONLY"},
+	{UACDontRequirePreauth, "DONT_REQ_PREAUTH"},
+	{UACPasswordExpired, "PASSWORD_EXPIRED"},
+	{UACTrustedToAuthForDelegation, "TRUSTED_TO_AUTH_FOR_DELEGATION"},
+	{UACPartialSecretsAccount, "PARTIAL_SECRETS_ACCOUNT"},
+}
+
+// ParseUserAccountControl parses the value of the userAccountControl attribute.
+func ParseUserAccountControl(value string) (UserAccountControl, error) {
+	v, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		// AD returns the value as a signed 32-bit integer
+		s, serr := strconv.ParseInt(value, 10, 32)
+		if serr != nil {
+			return 0, fmt.Errorf("invalid userAccountControl: %s", value)
+		}
+		v = uint64(uint32(s))
+	}
+
+	return UserAccountControl(v), nil
+}
+
+// EntryUserAccountControl returns the userAccountControl attribute of the entry, or zero if it is missing.
+func EntryUserAccountControl(e *ldap.Entry) (UserAccountControl, error) {
+	value := e.GetEqualFoldAttributeValue(AttributeUserAccountControl)
+	if len(value) == 0 {
+		return 0, nil
+	}
+
+	return ParseUserAccountControl(value)
+}
+
+// Has returns true if all of the given flags are set.
+func (u UserAccountControl) Has(flags UserAccountControl) bool {
+	return u&flags == flags
+}
+
+// Flags returns the names of the flags that are set, such as NORMAL_ACCOUNT. Unknown flags are named by their
+// hexadecimal value.
+func (u UserAccountControl) Flags() []string {
+	flags := []string{}
+	known := UserAccountControl(0)
+
+	for _, f := range uacFlagNames {
+		known |= f.flag
+		if u.Has(f.flag) {
+			flags = append(flags, f.name)
+		}
+	}
+
+	for bit := UserAccountControl(1); bit != 0; bit <<= 1 {
+		if u&bit != 0 && known&bit == 0 {
+			flags = append(flags, fmt.Sprintf("0x%x", uint32(bit)))
+		}
+	}
+
+	return flags
+}
+
+// String returns the names of the flags that are set separated by ` | `.
+func (u UserAccountControl) String() string {
+	return strings.Join(u.Flags(), " | ")
+}
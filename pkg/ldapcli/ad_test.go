@@ -0,0 +1,127 @@
+package ldapcli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSID(t *testing.T) {
+	b := []byte{
+		0x01, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05,
+		0x15, 0x00, 0x00, 0x00,
+		0xc7, 0xc7, 0xff, 0xd7,
+		0x7c, 0x7b, 0x55, 0xc8,
+		0x94, 0x56, 0xce, 0x01,
+		0xf5, 0x03, 0x00, 0x00,
+	}
+
+	sid, err := ParseSID(b)
+	require.NoError(t, err)
+	require.Equal(t, "S-1-5-21-3623864263-3361045372-30299796-1013", sid)
+
+	sid, err = ParseSID([]byte{0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00})
+	require.NoError(t, err)
+	require.Equal(t, "S-1-1-0", sid)
+
+	_, err = ParseSID(b[:20])
+	require.Error(t, err)
+
+	_, err = ParseSID([]byte("tesla"))
+	require.Error(t, err)
+}
+
+func TestParseGUID(t *testing.T) {
+	b := []byte{0xd4, 0xc3, 0xb2, 0xa1, 0xf6, 0xe5, 0xb8, 0xa7, 0xc9, 0xd0, 0xe1, 0xf2, 0xa3, 0xb4, 0xc5, 0xd6}
+
+	guid, err := ParseGUID(b)
+	require.NoError(t, err)
+	require.Equal(t, "a1b2c3d4-e5f6-a7b8-c9d0-e1f2a3b4c5d6", guid)
+
+	_, err = ParseGUID(b[:15])
+	require.Error(t, err)
+}
+
+func TestParseGeneralizedTime(t *testing.T) {
+	ts, err := ParseGeneralizedTime("20200102150405.0Z")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC), ts.UTC())
+
+	ts, err = ParseGeneralizedTime("202001021504-0500")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, 1, 2, 20, 4, 0, 0, time.UTC), ts.UTC())
+
+	_, err = ParseGeneralizedTime("yesterday")
+	require.Error(t, err)
+}
+
+func TestParseFileTime(t *testing.T) {
+	ts, err := ParseFileTime("132223104000000000")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), ts)
+
+	ts, err = ParseFileTime("0")
+	require.NoError(t, err)
+	require.True(t, ts.IsZero())
+
+	ts, err = ParseFileTime("9223372036854775807")
+	require.NoError(t, err)
+	require.True(t, ts.IsZero())
+
+	_, err = ParseFileTime("never")
+	require.Error(t, err)
+}
+
+func TestParseTimeAttribute(t *testing.T) {
+	ts, err := ParseTimeAttribute("PwdLastSet", "132223104000000000")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), ts)
+
+	ts, err = ParseTimeAttribute(AttributeWhenCreated, "20200102150405.0Z")
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC), ts.UTC())
+
+	ts, err = ParseTimeAttribute(AttributeAccountExpires, "9223372036854775807")
+	require.NoError(t, err)
+	require.True(t, ts.IsZero())
+
+	_, err = ParseTimeAttribute(AttributeMail, "132223104000000000")
+	require.Error(t, err)
+}
+
+func TestEntryTime(t *testing.T) {
+	e := ldap.NewEntry("cn=tesla,dc=example,dc=com", map[string][]string{
+		AttributeLastLogonTimestamp: {"132223104000000000"},
+	})
+
+	ts, err := EntryTime(e, AttributeLastLogonTimestamp)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), ts)
+
+	ts, err = EntryTime(e, AttributeLockoutTime)
+	require.NoError(t, err)
+	require.True(t, ts.IsZero())
+}
+
+func TestUserAccountControl(t *testing.T) {
+	uac, err := ParseUserAccountControl("66050")
+	require.NoError(t, err)
+	require.True(t, uac.Has(UACAccountDisable|UACNormalAccount))
+	require.False(t, uac.Has(UACLockout))
+	require.Equal(t, []string{"ACCOUNTDISABLE", "NORMAL_ACCOUNT", "DONT_EXPIRE_PASSWORD"}, uac.Flags())
+	require.Equal(t, "ACCOUNTDISABLE | NORMAL_ACCOUNT | DONT_EXPIRE_PASSWORD", uac.String())
+
+	uac, err = ParseUserAccountControl("-2147483136")
+	require.NoError(t, err)
+	require.Equal(t, []string{"NORMAL_ACCOUNT", "0x80000000"}, uac.Flags())
+
+	_, err = ParseUserAccountControl("disabled")
+	require.Error(t, err)
+
+	e := ldap.NewEntry("cn=tesla,dc=example,dc=com", map[string][]string{AttributeUserAccountControl: {"512"}})
+	uac, err = EntryUserAccountControl(e)
+	require.NoError(t, err)
+	require.Equal(t, UACNormalAccount, uac)
+}
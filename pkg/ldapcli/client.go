@@ -1,12 +1,19 @@
 package ldapcli
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"log"
+	"math/rand"
+	"net"
 	"net/url"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	ber "github.com/go-asn1-ber/asn1-ber"
 	"github.com/go-ldap/ldap/v3"
 	"golang.org/x/text/encoding/unicode"
 )
@@ -30,6 +37,9 @@ const (
 	// AttributeMail is the name for the mail attribute.
 	AttributeMail = "mail"
 
+	// AttributeMember is the name of the member attribute.
+	AttributeMember = "member"
+
 	// AttributeMemberOf is the name of the memberOf attribute.
 	AttributeMemberOf = "memberOf"
 
@@ -39,6 +49,12 @@ const (
 	// AttributeObjectClass is the name for the object class attribute.
 	AttributeObjectClass = "objectClass"
 
+	// AttributeObjectGUID is the name of the Active Directory objectGUID attribute.
+	AttributeObjectGUID = "objectGUID"
+
+	// AttributeObjectSID is the name of the Active Directory objectSid attribute.
+	AttributeObjectSID = "objectSid"
+
 	// AttributeSAMAccountName is the name for the Active Directory sAMAccountName attribute.
 	AttributeSAMAccountName = "sAMAccountName"
 
@@ -62,11 +78,62 @@ type Config struct {
 	BindPassword      string // optional, bind with password
 	StartTLS          bool   // should the connection attempt to STARTTLS
 	SkipVerify        bool   // ignore insecure TLS validation errors
-	BaseDN            string // base DN for searching
+	BaseDN            string // base DN for searching, default: read from the RootDSE when connecting
 	PageSize          int    // the number of results to request per page, default: 1000
 	DefaultTimeLimit  int    // default time limit to wait for results, default: 0 (no time limit)
 	FollowReferrals   bool   // should searches that return referrals be followed, default: true
 	userPrincipalName string // calculated userPrincipalName for binding
+
+	Addresses          []string      // optional, failover addresses tried after Address if it can't be reached
+	RandomizeAddresses bool          // try Address and Addresses in random order to spread the load
+	FailoverCooldown   time.Duration // how long an unreachable address is skipped, default: 1m
+	DialTimeout        time.Duration // how long to wait when connecting to each address, default: 60s
+
+	Domain           string           // optional, discover addresses from the DNS SRV records of the domain, e.g. example.com
+	DiscoveryService DiscoveryService // the SRV records used for discovery, default: DiscoverLDAP
+	Resolver         Resolver         // optional, resolves SRV records for discovery, default: net.DefaultResolver
+
+	AuthMode             AuthMode         // how to authenticate, default: AuthSimple
+	KerberosRealm        string           // optional, the Kerberos realm of BindUsername, default: the realm in BindUsername or the default realm of krb5.conf
+	KeytabPath           string           // optional, authenticate with the keys in this keytab instead of BindPassword when using GSSAPI
+	CCachePath           string           // optional, the credential cache used when there is no keytab or password, default: $KRB5CCNAME or /tmp/krb5cc_<uid>
+	DisableCCache        bool             // require a keytab or password, for servers that must not bind with the tickets in their own credential cache
+	Krb5ConfPath         string           // optional, the Kerberos configuration, default: $KRB5_CONFIG or /etc/krb5.conf
+	ServicePrincipalName string           // optional, the service principal of the LDAP servers, default: ldap/<host of the address>
+	GSSAPIClient         GSSAPIClientFunc // optional, creates the client for the GSSAPI exchange, default: NewKerberosClient
+
+	CAFile         string       // optional, PEM file of CA certificates trusted in addition to the system roots
+	CADir          string       // optional, directory of PEM files with CA certificates trusted in addition to the system roots
+	ClientCertFile string       // optional, PEM client certificate presented to the servers, required for AuthExternal over TLS
+	ClientKeyFile  string       // the PEM private key of ClientCertFile
+	MinTLSVersion  uint16       // optional, the minimum TLS version, e.g. tls.VersionTLS12, default: Go's default
+	TLSServerName  string       // optional, the name the server certificates are verified against, default: the host of each address
+	CipherPolicy   CipherPolicy // the cipher suites offered for TLS 1.2 and earlier, default: CipherPolicyDefault
+
+	AddressPolicy AddressPolicy // optional, restricts the servers that can be dialed, including referrals
+}
+
+// AddressPolicy decides which servers a Client may connect to. It is called with each address before it is dialed,
+// including failover addresses and referrals, and an error prevents the dial. If the returned function is not nil,
+// it is called with the IP address of each connection attempt, so host names can't resolve somewhere else
+// between the check and the dial.
+type AddressPolicy func(address string) (checkIP func(ip net.IP) error, err error)
+
+// dialControl returns a net.Dialer Control function that rejects connections to IP addresses not allowed by checkIP.
+func dialControl(checkIP func(ip net.IP) error) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return fmt.Errorf("cannot determine IP address of: %s", address)
+		}
+
+		return checkIP(ip)
+	}
 }
 
 // NewConfig returns a new Config object with defaults set.
@@ -79,14 +146,50 @@ func NewConfig(address, baseDN string) *Config {
 	}
 }
 
-// Validate the Config has all the required fields.
+// Validate the Config has all the required fields. If Address is not set, the first of Addresses is used.
+// If Domain is set, Address is optional and BaseDN defaults to the base DN of the domain. Otherwise, if BaseDN
+// is not set, it is read from the RootDSE of the server when connecting.
 func (c *Config) Validate() error {
-	if len(c.Address) == 0 {
+	if len(c.Address) == 0 && len(c.Addresses) > 0 {
+		c.Address = c.Addresses[0]
+	}
+
+	if len(c.Address) == 0 && len(c.Domain) == 0 {
 		return fmt.Errorf("Address is a required field")
 	}
 
-	if len(c.BaseDN) == 0 {
-		return fmt.Errorf("BaseDN is a required field")
+	if len(c.BaseDN) == 0 && len(c.Domain) > 0 {
+		c.BaseDN = ParseBaseDNFromDomain(strings.TrimSuffix(c.Domain, "."))
+	}
+
+	mode, err := ParseAuthMode(string(c.AuthMode))
+	if err != nil {
+		return err
+	}
+	c.AuthMode = mode
+
+	policy, err := ParseCipherPolicy(string(c.CipherPolicy))
+	if err != nil {
+		return err
+	}
+	c.CipherPolicy = policy
+
+	if len(c.ClientCertFile) > 0 && len(c.ClientKeyFile) == 0 {
+		return fmt.Errorf("ClientKeyFile is required with ClientCertFile")
+	}
+	if len(c.ClientKeyFile) > 0 && len(c.ClientCertFile) == 0 {
+		return fmt.Errorf("ClientCertFile is required with ClientKeyFile")
+	}
+	if c.AuthMode == AuthExternal && len(c.ClientCertFile) == 0 && !strings.HasPrefix(c.Address, "ldapi://") {
+		return fmt.Errorf("ClientCertFile is required for external authentication, unless Address is an ldapi:// socket")
+	}
+
+	if len(c.Domain) > 0 {
+		service, err := ParseDiscoveryService(string(c.DiscoveryService))
+		if err != nil {
+			return err
+		}
+		c.DiscoveryService = service
 	}
 
 	if c.PageSize < 100 {
@@ -95,19 +198,68 @@ func (c *Config) Validate() error {
 		c.PageSize = 10000
 	}
 
+	if c.FailoverCooldown <= 0 {
+		c.FailoverCooldown = DefaultFailoverCooldown
+	}
+	if c.DialTimeout <= 0 {
+		c.DialTimeout = ldap.DefaultTimeout
+	}
+
 	c.userPrincipalName = CalculateUserPrincipalName(c.BindUsername, c.BaseDN)
 	return nil
 }
 
-// Client for LDAP connection.
+// addresses returns Address followed by Addresses and any discovered addresses, without duplicates. If
+// RandomizeAddresses is set, Address and Addresses are shuffled, but discovered addresses keep their order, which
+// follows the priority and weight of their SRV records.
+func (c *Config) addresses(discovered ...string) []string {
+	addresses := []string{}
+	seen := map[string]struct{}{}
+
+	configured := append([]string{c.Address}, c.Addresses...)
+	if c.RandomizeAddresses {
+		rand.Shuffle(len(configured), func(i, j int) {
+			configured[i], configured[j] = configured[j], configured[i]
+		})
+	}
+
+	for _, address := range append(configured, discovered...) {
+		if _, ok := seen[address]; !ok && len(address) > 0 {
+			addresses = append(addresses, address)
+			seen[address] = struct{}{}
+		}
+	}
+
+	return addresses
+}
+
+// Client for LDAP connection. Client is safe for concurrent use by multiple goroutines.
 type Client struct {
-	conn *ldap.Conn
-	conf *Config
-	refs map[string]*Client
+	mu     sync.RWMutex       // guards conn, conf, refs, and closed
+	dialMu sync.Mutex         // serializes reconnecting and binding so credentials can't change mid-reconnect
+	conn   *sharedConn        // the current connection, replaced when reconnecting
+	conf   *Config            // the current config, replaced rather than modified when binding
+	refs   map[string]*Client // clients for referrals, keyed by referral URL
+	closed bool               // set once Close is called, prevents reconnecting
+}
+
+// sharedConn is a connection used by multiple goroutines. When replaced by a reconnect, it is retired
+// and closed once the operations in progress on it have finished. Fields are guarded by Client.mu.
+type sharedConn struct {
+	*ldap.Conn
+	address string
+	users   int
+	retired bool
 }
 
 // Dial creates a new Client and attempts to connect to the given LDAP server.
 func Dial(conf *Config) (*Client, error) {
+	return DialContext(context.Background(), conf)
+}
+
+// DialContext creates a new Client and attempts to connect to the given LDAP server.
+// The context only applies to connecting and binding, not to the lifetime of the Client.
+func DialContext(ctx context.Context, conf *Config) (*Client, error) {
 	if conf == nil {
 		return nil, fmt.Errorf("Config cannot be nil")
 	}
@@ -121,104 +273,380 @@ func Dial(conf *Config) (*Client, error) {
 		refs: map[string]*Client{},
 	}
 
-	if err := cli.Reconnect(); err != nil {
+	if err := cli.ReconnectContext(ctx); err != nil {
 		return nil, err
 	}
 
 	return cli, nil
 }
 
-// Close the connection.
+// Close the connection. Once closed, the Client will not reconnect.
 func (c *Client) Close() {
-	c.conn.Close()
+	c.mu.Lock()
+	conn := c.conn
+	refs := c.refs
+	c.refs = map[string]*Client{}
+	c.closed = true
+	c.mu.Unlock()
 
-	for _, conn := range c.refs {
+	if conn != nil {
 		conn.Close()
 	}
 
-	c.refs = map[string]*Client{}
+	for _, ref := range refs {
+		ref.Close()
+	}
 }
 
 // Reconnect to LDAP. This is used internally if the connection is interrupted.
 func (c *Client) Reconnect() error {
-	tlsConf := &tls.Config{
-		InsecureSkipVerify: c.conf.SkipVerify,
+	return c.ReconnectContext(context.Background())
+}
+
+// ReconnectContext reconnects to LDAP. If the context is done before the connection is established and bound,
+// the new connection is closed and the context's error is returned.
+func (c *Client) ReconnectContext(ctx context.Context) error {
+	c.dialMu.Lock()
+	defer c.dialMu.Unlock()
+
+	return c.reconnect(ctx)
+}
+
+// reconnectIfCurrent reconnects only if the given connection is still the current connection. This prevents
+// goroutines that fail on the same closed connection from each replacing it. The caller must not hold dialMu.
+func (c *Client) reconnectIfCurrent(ctx context.Context, failed *ldap.Conn) error {
+	c.dialMu.Lock()
+	defer c.dialMu.Unlock()
+
+	if c.currentConn() != failed {
+		return nil
+	}
+
+	return c.reconnect(ctx)
+}
+
+// reconnect dials and binds a new connection, then replaces the current connection with it. Each configured
+// address is tried in turn until one can be reached, and unreachable addresses are put in cooldown. If Domain is
+// set, addresses are discovered each time, so changes to the SRV records are picked up without a new Client.
+// The caller must hold dialMu.
+func (c *Client) reconnect(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	conf := c.Config()
+
+	tlsConf, err := conf.tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	discovered := []string{}
+	if len(conf.Domain) > 0 {
+		var err error
+		discovered, err = DiscoverAddresses(ctx, conf.Resolver, conf.Domain, conf.DiscoveryService)
+
+		// configured addresses can still be tried if discovery fails
+		if err != nil && len(conf.Address) == 0 {
+			return err
+		}
+	}
+
+	addresses := orderAddresses(conf.addresses(discovered...))
+	errs := []string{}
+	var lastErr error
+
+	for _, address := range addresses {
+		// the base DN may be read from the server, so each address is dialed with its own copy of the config
+		dialConf := *conf
+		conn, err := dialAddress(ctx, &dialConf, address, tlsConf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if !isFailoverError(err) {
+				return err
+			}
+
+			markUnhealthy(address, conf.FailoverCooldown)
+			errs = append(errs, fmt.Sprintf("%s: %v", address, err))
+			lastErr = err
+			continue
+		}
+
+		markHealthy(address)
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return fmt.Errorf("client is closed")
+		}
+		if dialConf.BaseDN != conf.BaseDN && c.conf == conf {
+			c.conf = &dialConf
+		}
+		old := c.conn
+		c.conn = &sharedConn{Conn: conn, address: address}
+		closeOld := old != nil && old.users == 0
+		if old != nil {
+			old.retired = true
+		}
+		c.mu.Unlock()
+
+		if closeOld {
+			old.Close()
+		}
+
+		return nil
+	}
+
+	if len(errs) == 1 {
+		return lastErr
+	}
+
+	return fmt.Errorf("no LDAP server could be reached: %s", strings.Join(errs, "; "))
+}
+
+// dialAddress dials and binds a new connection to the given address. If the config has no base DN, it is set
+// from the RootDSE before binding, since the userPrincipalName used for binding depends on it.
+func dialAddress(ctx context.Context, conf *Config, address string, tlsConf *tls.Config) (*ldap.Conn, error) {
+	dialer := &net.Dialer{Timeout: conf.DialTimeout}
+
+	if conf.AddressPolicy != nil {
+		checkIP, err := conf.AddressPolicy(address)
+		if err != nil {
+			return nil, err
+		}
+		if checkIP != nil {
+			dialer.Control = dialControl(checkIP)
+		}
+	}
+
+	tlsConf = tlsConfigForAddress(tlsConf, address)
+	conn, err := dialURL(ctx, dialer, address, tlsConf)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to LDAP: %w", err)
 	}
 
-	conn, err := ldap.DialURL(c.conf.Address, ldap.DialWithTLSConfig(tlsConf))
+	stop := watchConn(ctx, conn)
+	err = bindConn(ctx, conn, conf, address, tlsConf)
+	stop()
+
+	if err != nil || ctx.Err() != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// dialURL is the same as ldap.DialURL, but stops dialing when the context is done. go-ldap only dials with
+// the dialer's Dial method, so the connection is dialed here and handed to go-ldap once established.
+func dialURL(ctx context.Context, dialer *net.Dialer, address string, tlsConf *tls.Config) (*ldap.Conn, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, ldap.NewError(ldap.ErrorNetwork, err)
+	}
+
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		// the port is missing
+		host = u.Host
+		port = ""
+	}
+
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldapi":
+		path := u.Path
+		if len(path) == 0 || path == "/" {
+			path = "/var/run/slapd/ldapi"
+		}
+		conn, err = dialer.DialContext(ctx, "unix", path)
+	case "ldap":
+		if len(port) == 0 {
+			port = ldap.DefaultLdapPort
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	case "ldaps":
+		if len(port) == 0 {
+			port = ldap.DefaultLdapsPort
+		}
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: tlsConf}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	default:
+		err = fmt.Errorf("unknown scheme '%s'", u.Scheme)
+	}
 	if err != nil {
-		return fmt.Errorf("connecting to LDAP: %v", err)
+		return nil, ldap.NewError(ldap.ErrorNetwork, err)
 	}
 
-	if c.conf.StartTLS {
+	ldapConn := ldap.NewConn(conn, u.Scheme == "ldaps")
+	ldapConn.Start()
+	return ldapConn, nil
+}
+
+// bindConn starts TLS if configured, and binds the given connection to the server at the given address using
+// the configured credentials. If the config has no base DN, it is read from the RootDSE first.
+func bindConn(ctx context.Context, conn *ldap.Conn, conf *Config, address string, tlsConf *tls.Config) error {
+	if conf.StartTLS {
 		if err := conn.StartTLS(tlsConf); err != nil {
-			return fmt.Errorf("starting TLS: %v", err)
+			return fmt.Errorf("starting TLS: %w", err)
 		}
 	}
 
-	if len(c.conf.BindPassword) == 0 {
-		if err := conn.UnauthenticatedBind(c.conf.userPrincipalName); err != nil {
-			return fmt.Errorf("unauthenticated bind to LDAP: %v", err)
+	if len(conf.BaseDN) == 0 {
+		root, err := readRootDSE(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("finding base DN: %w", err)
 		}
-	} else if len(c.conf.userPrincipalName) > 0 && len(c.conf.BindPassword) > 0 {
-		if err := conn.Bind(c.conf.userPrincipalName, c.conf.BindPassword); err != nil {
-			return fmt.Errorf("binding to LDAP: %v", err)
+
+		conf.BaseDN = root.BaseDN()
+		if len(conf.BaseDN) == 0 {
+			return fmt.Errorf("finding base DN: the server has no naming contexts, a base DN must be given")
 		}
+		conf.userPrincipalName = CalculateUserPrincipalName(conf.BindUsername, conf.BaseDN)
 	}
 
-	if c.conn != nil {
-		c.conn.Close()
+	switch conf.AuthMode {
+	case AuthGSSAPI:
+		return gssapiBind(conn, conf, address)
+	case AuthExternal:
+		return externalBind(conn)
+	}
+
+	if len(conf.BindPassword) == 0 {
+		if err := conn.UnauthenticatedBind(conf.userPrincipalName); err != nil {
+			return fmt.Errorf("unauthenticated bind to LDAP: %w", err)
+		}
+	} else if len(conf.userPrincipalName) > 0 && len(conf.BindPassword) > 0 {
+		if err := conn.Bind(conf.userPrincipalName, conf.BindPassword); err != nil {
+			return fmt.Errorf("binding to LDAP: %w", err)
+		}
 	}
 
-	c.conn = conn
 	return nil
 }
 
-// Config returns the Config object being used.
+// IsClosing returns true if the connection is closing or has been closed.
+func (c *Client) IsClosing() bool {
+	conn := c.currentConn()
+	return conn == nil || conn.IsClosing()
+}
+
+// Ping checks the health of the connection by reading the RootDSE. Unlike other methods,
+// this does not attempt to reconnect if the connection has been closed.
+func (c *Client) Ping() error {
+	req := ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, 0, false, "(objectClass=*)", []string{"1.1"}, nil)
+	_, err := c.currentConn().Search(req)
+	return err
+}
+
+// Address returns the address of the server the client is connected to, which may be one of the failover
+// addresses if Address could not be reached.
+func (c *Client) Address() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.conn == nil {
+		return ""
+	}
+	return c.conn.address
+}
+
+// Config returns the Config object being used. The returned Config must not be modified.
 func (c *Client) Config() *Config {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.conf
 }
 
-// Bind will attempt to bind as the given DN and password.
+// currentConn returns the current connection.
+func (c *Client) currentConn() *ldap.Conn {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.conn == nil {
+		return nil
+	}
+	return c.conn.Conn
+}
+
+// acquireConn returns the current connection, which won't be closed by a reconnect until it is released.
+func (c *Client) acquireConn() *sharedConn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.users++
+	return c.conn
+}
+
+// releaseConn releases a connection returned by acquireConn, closing it if it has been retired and is no longer in use.
+func (c *Client) releaseConn(conn *sharedConn) {
+	c.mu.Lock()
+	conn.users--
+	closeConn := conn.retired && conn.users == 0
+	c.mu.Unlock()
+
+	if closeConn {
+		conn.Close()
+	}
+}
+
+// Bind will attempt to bind as the given DN and password. Operations already in progress on other
+// goroutines may complete as either the previous or the new identity.
 func (c *Client) Bind(username, password string) error {
-	upn := CalculateUserPrincipalName(username, c.conf.BaseDN)
+	c.dialMu.Lock()
+	defer c.dialMu.Unlock()
+
+	conf := *c.Config()
+	upn := CalculateUserPrincipalName(username, conf.BaseDN)
+	conn := c.currentConn()
 
 	if len(password) == 0 {
-		if err := c.conn.UnauthenticatedBind(upn); err != nil {
-			return fmt.Errorf("unauthenticated bind to LDAP: %v", err)
+		if err := conn.UnauthenticatedBind(upn); err != nil {
+			return fmt.Errorf("unauthenticated bind to LDAP: %w", err)
 		}
 
-		c.conf.BindUsername = username
-		c.conf.userPrincipalName = upn
+		conf.BindUsername = username
+		conf.userPrincipalName = upn
 	} else if len(upn) > 0 && len(password) > 0 {
-		if err := c.conn.Bind(upn, password); err != nil {
-			return fmt.Errorf("binding to LDAP: %v", err)
+		if err := conn.Bind(upn, password); err != nil {
+			return fmt.Errorf("binding to LDAP: %w", err)
 		}
 
-		c.conf.BindUsername = username
-		c.conf.userPrincipalName = upn
-		c.conf.BindPassword = password
+		conf.BindUsername = username
+		conf.userPrincipalName = upn
+		conf.BindPassword = password
 	} else {
 		return fmt.Errorf("cannot bind without a username")
 	}
 
 	// clear out any existing referral connections using previous credentials
-	for _, conn := range c.refs {
-		conn.Close()
+	c.mu.Lock()
+	c.conf = &conf
+	refs := c.refs
+	c.refs = map[string]*Client{}
+	c.mu.Unlock()
+
+	for _, ref := range refs {
+		ref.Close()
 	}
 
-	c.refs = map[string]*Client{}
 	return nil
 }
 
 // NewSearchRequest returns a new ldap.SearchRequest object with some defaults set.
 func (c *Client) NewSearchRequest(filter string, attributes []string) *ldap.SearchRequest {
+	conf := c.Config()
 	return ldap.NewSearchRequest(
-		c.conf.BaseDN,
+		conf.BaseDN,
 		ldap.ScopeWholeSubtree,
 		ldap.NeverDerefAliases,
 		0,
-		c.conf.DefaultTimeLimit,
+		conf.DefaultTimeLimit,
 		false,
 		filter,
 		attributes,
@@ -229,28 +657,42 @@ func (c *Client) NewSearchRequest(filter string, attributes []string) *ldap.Sear
 // Search is the low-level method of searching LDAP, and returns SearchResult.
 // This method automaticaly reconnects to LDAP and retries if there is a connection error.
 func (c *Client) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
-	resp, err := c.conn.SearchWithPaging(req, uint32(c.conf.PageSize))
-	if IsErrConnectionClosed(err) {
-		if err := c.Reconnect(); err != nil {
-			return nil, fmt.Errorf("while attempting to reconnect: %v", err)
-		}
+	return c.SearchContext(context.Background(), req)
+}
 
-		return c.conn.SearchWithPaging(req, uint32(c.conf.PageSize))
-	}
+// SearchContext is the same as Search, but abandons the search and stops following referrals
+// when the context is done.
+func (c *Client) SearchContext(ctx context.Context, req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	conf := c.Config()
+
+	var resp *ldap.SearchResult
+	err := c.do(ctx, func(conn *ldap.Conn) error {
+		var err error
+		resp, err = searchWithPaging(ctx, conn, req, uint32(conf.PageSize))
+		return err
+	})
+
+	if conf.FollowReferrals && resp != nil && ctx.Err() == nil {
+		refs, uncached := c.configureReferrals(ctx, resp.Referrals)
+		for _, conn := range uncached {
+			defer conn.Close()
+		}
 
-	if c.conf.FollowReferrals {
-		origBaseDN := req.BaseDN
-		c.configureReferrals(resp.Referrals)
 		for _, ref := range resp.Referrals {
-			conn := c.refs[ref]
+			if ctx.Err() != nil {
+				break
+			}
+
+			conn := refs[ref]
 			if conn == nil {
 				continue
 			}
 
 			// change the initial base DN to the DN indicated by the referral
-			req.BaseDN = conn.conf.BaseDN
+			refReq := *req
+			refReq.BaseDN = conn.Config().BaseDN
 
-			refResp, err := conn.Search(req)
+			refResp, err := conn.SearchContext(ctx, &refReq)
 			if err != nil {
 				log.Printf("could not follow referral: %s: %v\n", ref, err)
 				continue
@@ -260,56 +702,402 @@ func (c *Client) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
 				resp.Entries = append(resp.Entries, refResp.Entries...)
 			}
 		}
-		req.BaseDN = origBaseDN
+	}
+
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
 	}
 
 	return resp, err
 }
 
-// Add adds a new entry to the directory.
-func (c *Client) Add(req *ldap.AddRequest) error {
-	err := c.conn.Add(req)
-	if IsErrConnectionClosed(err) {
-		if err := c.Reconnect(); err != nil {
-			return fmt.Errorf("while attempting to reconnect: %v", err)
+// SearchPages performs a paged search and calls fn with each page of entries as it arrives, so large result sets
+// are never held in memory all at once. If FollowReferrals is enabled, referrals are only dialed and searched after
+// the initial search completes, and their pages are passed to fn as well. If fn returns an error, the search is
+// abandoned and that error is returned.
+func (c *Client) SearchPages(req *ldap.SearchRequest, fn func(page *ldap.SearchResult) error) error {
+	return c.SearchPagesContext(context.Background(), req, fn)
+}
+
+// SearchPagesContext is the same as SearchPages, but abandons the search and stops following referrals
+// when the context is done.
+func (c *Client) SearchPagesContext(ctx context.Context, req *ldap.SearchRequest, fn func(page *ldap.SearchResult) error) error {
+	err := c.searchPages(ctx, req, fn)
+	if ce, ok := err.(*callbackError); ok {
+		return ce.err
+	}
+	return err
+}
+
+// callbackError wraps errors returned by a SearchPages callback so they can be told apart from search errors.
+type callbackError struct {
+	err error
+}
+
+func (e *callbackError) Error() string {
+	return e.err.Error()
+}
+
+func (c *Client) searchPages(ctx context.Context, req *ldap.SearchRequest, fn func(page *ldap.SearchResult) error) error {
+	conf := c.Config()
+
+	referrals, err := c.readPages(ctx, req, fn)
+	if err != nil {
+		return err
+	}
+
+	if !conf.FollowReferrals || len(referrals) == 0 {
+		return nil
+	}
+
+	refs, uncached := c.configureReferrals(ctx, referrals)
+	for _, conn := range uncached {
+		defer conn.Close()
+	}
+
+	for _, ref := range referrals {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		conn := refs[ref]
+		if conn == nil {
+			continue
 		}
 
-		return c.conn.Add(req)
+		// change the initial base DN to the DN indicated by the referral
+		refReq := *req
+		refReq.BaseDN = conn.Config().BaseDN
+
+		if err := conn.searchPages(ctx, &refReq, fn); err != nil {
+			if _, ok := err.(*callbackError); ok || ctx.Err() != nil {
+				return err
+			}
+			log.Printf("could not follow referral: %s: %v\n", ref, err)
+		}
 	}
 
-	return err
+	return nil
 }
 
-// Modify an existing entry.
-func (c *Client) Modify(req *ldap.ModifyRequest) error {
-	err := c.conn.Modify(req)
-	if IsErrConnectionClosed(err) {
-		if err := c.Reconnect(); err != nil {
-			return fmt.Errorf("while attempting to reconnect: %v", err)
+// readPages performs a paged search on the client's own connection, calling fn with each page, and returns the
+// referrals from all pages.
+func (c *Client) readPages(ctx context.Context, req *ldap.SearchRequest, fn func(page *ldap.SearchResult) error) ([]string, error) {
+	pageReq, paging := pagedRequest(req, uint32(c.Config().PageSize))
+	referrals := []string{}
+
+	// the cookie is only valid on the connection that started the search, so all pages are read from it even if
+	// the client reconnects in the meantime. If it's closed after the first page, the search fails rather than
+	// sending the cookie on a new connection.
+	conn, err := c.acquireOpenConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if conn != nil {
+			c.releaseConn(conn)
+		}
+	}()
+
+	for first := true; ; first = false {
+		result, err := searchAsync(ctx, conn.Conn, pageReq)
+		if first && IsErrConnectionClosed(err) && ctx.Err() == nil {
+			// no cookie has been sent yet, so the search can be started again on a new connection
+			c.releaseConn(conn)
+			if err := c.reconnectAfter(ctx, conn.Conn); err != nil {
+				conn = nil
+				return nil, err
+			}
+
+			conn = c.acquireConn()
+			result, err = searchAsync(ctx, conn.Conn, pageReq)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if !first && IsErrConnectionClosed(err) {
+				return nil, fmt.Errorf("paged search interrupted, the connection was closed: %w", err)
+			}
+			return nil, err
+		}
+
+		referrals = append(referrals, result.Referrals...)
+		cookie := pagingCookie(result)
+
+		if err := fn(result); err != nil {
+			if len(cookie) > 0 {
+				abandonPaging(conn.Conn, pageReq, paging, cookie)
+			}
+			return nil, &callbackError{err: err}
 		}
 
-		return c.conn.Modify(req)
+		if len(cookie) == 0 {
+			return referrals, nil
+		}
+		paging.SetCookie(cookie)
 	}
+}
 
-	return err
+// pagedRequest returns a copy of the given request with a paging control of the given size, so the paging control
+// doesn't modify the caller's request.
+func pagedRequest(req *ldap.SearchRequest, pageSize uint32) (*ldap.SearchRequest, *ldap.ControlPaging) {
+	pageReq := *req
+	paging := ldap.NewControlPaging(pageSize)
+	pageReq.Controls = []ldap.Control{paging}
+	for _, control := range req.Controls {
+		if control.GetControlType() != ldap.ControlTypePaging {
+			pageReq.Controls = append(pageReq.Controls, control)
+		}
+	}
+
+	return &pageReq, paging
+}
+
+// pagingCookie returns the cookie for the next page of a paged search, which is empty after the last page.
+func pagingCookie(result *ldap.SearchResult) []byte {
+	if control, ok := ldap.FindControl(result.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging); ok {
+		return control.Cookie
+	}
+	return nil
+}
+
+// searchWithPaging is the same as ldap.Conn.SearchWithPaging, but abandons the search when the context is done and
+// doesn't modify the given request.
+func searchWithPaging(ctx context.Context, conn *ldap.Conn, req *ldap.SearchRequest, pageSize uint32) (*ldap.SearchResult, error) {
+	pageReq, paging := pagedRequest(req, pageSize)
+	result := &ldap.SearchResult{}
+
+	for {
+		page, err := searchAsync(ctx, conn, pageReq)
+		result.Entries = append(result.Entries, page.Entries...)
+		result.Referrals = append(result.Referrals, page.Referrals...)
+		result.Controls = append(result.Controls, page.Controls...)
+		if err != nil {
+			return result, err
+		}
+
+		cookie := pagingCookie(page)
+		if len(cookie) == 0 {
+			return result, nil
+		}
+		paging.SetCookie(cookie)
+	}
+}
+
+// searchAsync performs a single search request on the given connection. If the context is done first, go-ldap's
+// SearchAsync stops reading the search's responses and the context's error is returned. SearchAsync doesn't send
+// an abandon request when the context is done, and go-ldap v3.4.8 has no other way to send one, so the server
+// finishes the search and the rest of its responses are discarded. The connection is left open, since it's
+// shared with other goroutines.
+func searchAsync(ctx context.Context, conn *ldap.Conn, req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result := &ldap.SearchResult{}
+
+	// go-ldap returns no results and no error for a search on a closed connection
+	if conn.IsClosing() {
+		return result, ldap.NewError(ldap.ErrorNetwork, fmt.Errorf("ldap: connection closed"))
+	}
+
+	resp := conn.SearchAsync(ctx, req, 0)
+
+	// read until the response is closed, so the goroutine sending the results doesn't block
+	for resp.Next() {
+		switch {
+		case resp.Entry() != nil:
+			result.Entries = append(result.Entries, resp.Entry())
+		case len(resp.Referral()) > 0:
+			result.Referrals = append(result.Referrals, resp.Referral())
+		default:
+			result.Controls = append(result.Controls, resp.Controls()...)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	return result, resp.Err()
+}
+
+// abandonPaging tells the server to release the paged search with the given cookie, ignoring any errors. It must be
+// sent on the connection that started the search.
+func abandonPaging(conn *ldap.Conn, req *ldap.SearchRequest, paging *ldap.ControlPaging, cookie []byte) {
+	paging.SetCookie(cookie)
+	paging.PagingSize = 0
+	conn.Search(req)
+}
+
+// Add adds a new entry to the directory.
+func (c *Client) Add(req *ldap.AddRequest) error {
+	return c.AddContext(context.Background(), req)
+}
+
+// AddContext is the same as Add, but stops waiting for the response and returns the context's error when the
+// context is done. The write isn't abandoned, so it may still complete on the server.
+func (c *Client) AddContext(ctx context.Context, req *ldap.AddRequest) error {
+	return c.doWrite(ctx, func(conn *ldap.Conn) error {
+		return conn.Add(req)
+	})
+}
+
+// Modify an existing entry.
+func (c *Client) Modify(req *ldap.ModifyRequest) error {
+	return c.ModifyContext(context.Background(), req)
+}
+
+// ModifyContext is the same as Modify, but stops waiting for the response and returns the context's error when the
+// context is done. The write isn't abandoned, so it may still complete on the server.
+func (c *Client) ModifyContext(ctx context.Context, req *ldap.ModifyRequest) error {
+	return c.doWrite(ctx, func(conn *ldap.Conn) error {
+		return conn.Modify(req)
+	})
 }
 
 // Delete an existing entry.
 func (c *Client) Delete(req *ldap.DelRequest) error {
-	err := c.conn.Del(req)
-	if IsErrConnectionClosed(err) {
-		if err := c.Reconnect(); err != nil {
-			return fmt.Errorf("while attempting to reconnect: %v", err)
+	return c.DeleteContext(context.Background(), req)
+}
+
+// DeleteContext is the same as Delete, but stops waiting for the response and returns the context's error when the
+// context is done. The write isn't abandoned, so it may still complete on the server.
+func (c *Client) DeleteContext(ctx context.Context, req *ldap.DelRequest) error {
+	return c.doWrite(ctx, func(conn *ldap.Conn) error {
+		return conn.Del(req)
+	})
+}
+
+// do runs the given read-only operation on the current connection, reconnecting and retrying once if the
+// connection was closed. The operation must return when the context is done, and the context's error is returned.
+func (c *Client) do(ctx context.Context, op func(conn *ldap.Conn) error) error {
+	return c.run(ctx, false, op)
+}
+
+// doWrite is the same as do, but stops waiting for the operation when the context is done, leaving it to complete
+// in the background. It isn't retried if the connection was closed while it was in progress, since the server may
+// have applied it before the connection was lost; the connection is re-established on the next call instead.
+func (c *Client) doWrite(ctx context.Context, op func(conn *ldap.Conn) error) error {
+	return c.run(ctx, true, op)
+}
+
+func (c *Client) run(ctx context.Context, write bool, op func(conn *ldap.Conn) error) error {
+	conn, err := c.acquireOpenConn(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = c.doOnce(ctx, conn, write, op)
+	if !IsErrConnectionClosed(err) {
+		return err
+	}
+
+	if write {
+		return fmt.Errorf("connection closed before the server responded, the request may have been applied: %w", err)
+	}
+
+	if err := c.reconnectAfter(ctx, conn.Conn); err != nil {
+		return err
+	}
+
+	return c.doOnce(ctx, c.acquireConn(), write, op)
+}
+
+// acquireOpenConn is the same as acquireConn, but first replaces a connection that is known to be closed, such
+// as one the server closed while idle. Nothing has been sent yet, so this is safe for writes as well.
+func (c *Client) acquireOpenConn(ctx context.Context) (*sharedConn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	conn := c.acquireConn()
+	if !conn.IsClosing() {
+		return conn, nil
+	}
+
+	c.releaseConn(conn)
+	if err := c.reconnectAfter(ctx, conn.Conn); err != nil {
+		return nil, err
+	}
+
+	return c.acquireConn(), nil
+}
+
+// reconnectAfter reconnects if the given failed connection is still the current one, returning the context's
+// error if it is done.
+func (c *Client) reconnectAfter(ctx context.Context, failed *ldap.Conn) error {
+	if err := c.reconnectIfCurrent(ctx, failed); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
+		return fmt.Errorf("while attempting to reconnect: %v", err)
+	}
 
-		return c.conn.Del(req)
+	return nil
+}
+
+// doOnce runs the given operation on the given connection, and releases the connection when the operation
+// completes. The connection is shared with other goroutines, so it's never closed to stop an operation. Searches
+// stop reading their responses when the context is done, but go-ldap's writes don't take a context, so doOnce
+// stops waiting for writes instead and returns the context's error. The write may still complete on the server.
+func (c *Client) doOnce(ctx context.Context, conn *sharedConn, write bool, op func(conn *ldap.Conn) error) error {
+	if !write || ctx.Done() == nil {
+		err := op(conn.Conn)
+		c.releaseConn(conn)
+
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
 	}
 
-	return err
+	done := make(chan error, 1)
+	go func() {
+		defer c.releaseConn(conn)
+		done <- op(conn.Conn)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// watchConn closes the given connection if the context is done before the returned stop function is called.
+// This is only used while dialing, before the connection is shared, since closing it is what stops a StartTLS
+// or bind that is waiting for the server.
+func watchConn(ctx context.Context, conn *ldap.Conn) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
 }
 
 // SetPassword sets the password for a user.
 func (c *Client) SetPassword(userDN string, password string) error {
+	return c.SetPasswordContext(context.Background(), userDN, password)
+}
+
+// SetPasswordContext is the same as SetPassword, but stops waiting for the response when the context is done. The
+// password may still be changed by the server.
+func (c *Client) SetPasswordContext(ctx context.Context, userDN string, password string) error {
 	encodedPW, err := formatPassword(password)
 	if err != nil {
 		return fmt.Errorf("encoding password: %v", err)
@@ -328,46 +1116,102 @@ func (c *Client) SetPassword(userDN string, password string) error {
 		},
 	}
 
-	return c.conn.Modify(req)
+	return c.ModifyContext(ctx, req)
 }
 
-// configureReferrals configures suggested referral clients.
-func (c *Client) configureReferrals(referrals []string) {
+// configureReferrals configures suggested referral clients, and returns the clients for the given referrals.
+// Referral clients are cached, and are dialed without holding the lock so other operations aren't blocked.
+// Clients that could not be cached are also returned as uncached, and must be closed by the caller when done.
+func (c *Client) configureReferrals(ctx context.Context, referrals []string) (refs map[string]*Client, uncached []*Client) {
+	refs = map[string]*Client{}
+	conf := c.Config()
+
 	for _, ref := range referrals {
-		if c.refs[ref] == nil {
-			u, err := url.Parse(ref)
-			if err != nil {
-				log.Printf("cannot parse referral: %s: %v\n", ref, err)
-				continue
-			}
+		if ctx.Err() != nil {
+			break
+		}
 
-			conf := &Config{
-				Address:          fmt.Sprintf("%s://%s", u.Scheme, u.Host),
-				BaseDN:           strings.TrimSuffix(strings.TrimPrefix(u.Path, "/"), "/"),
-				BindUsername:     c.conf.BindUsername,
-				BindPassword:     c.conf.BindPassword,
-				DefaultTimeLimit: c.conf.DefaultTimeLimit,
-				FollowReferrals:  false,
-				PageSize:         c.conf.PageSize,
-				SkipVerify:       c.conf.SkipVerify,
-				StartTLS:         c.conf.StartTLS,
-			}
+		c.mu.RLock()
+		cached := c.refs[ref]
+		c.mu.RUnlock()
 
-			conn, err := Dial(conf)
-			if err != nil {
-				log.Printf("dialing referral failed: %s: %v\n", ref, err)
-				continue
-			}
+		if cached != nil {
+			refs[ref] = cached
+			continue
+		}
+
+		u, err := url.Parse(ref)
+		if err != nil {
+			log.Printf("cannot parse referral: %s: %v\n", ref, err)
+			continue
+		}
+
+		refConf := &Config{
+			Address:          fmt.Sprintf("%s://%s", u.Scheme, u.Host),
+			BaseDN:           strings.TrimSuffix(strings.TrimPrefix(u.Path, "/"), "/"),
+			BindUsername:     conf.BindUsername,
+			BindPassword:     conf.BindPassword,
+			DefaultTimeLimit: conf.DefaultTimeLimit,
+			FollowReferrals:  false,
+			PageSize:         conf.PageSize,
+			SkipVerify:       conf.SkipVerify,
+			StartTLS:         conf.StartTLS,
+			AuthMode:         conf.AuthMode,
+			KerberosRealm:    conf.KerberosRealm,
+			KeytabPath:       conf.KeytabPath,
+			CCachePath:       conf.CCachePath,
+			DisableCCache:    conf.DisableCCache,
+			Krb5ConfPath:     conf.Krb5ConfPath,
+			GSSAPIClient:     conf.GSSAPIClient,
+			CAFile:           conf.CAFile,
+			CADir:            conf.CADir,
+			ClientCertFile:   conf.ClientCertFile,
+			ClientKeyFile:    conf.ClientKeyFile,
+			MinTLSVersion:    conf.MinTLSVersion,
+			CipherPolicy:     conf.CipherPolicy,
+			AddressPolicy:    conf.AddressPolicy,
+		}
 
+		conn, err := DialContext(ctx, refConf)
+		if err != nil {
+			log.Printf("dialing referral failed: %s: %v\n", ref, err)
+			continue
+		}
+
+		// keep the existing client if another goroutine dialed the same referral first, and don't cache
+		// clients dialed with credentials that have since changed or for a client that has been closed
+		c.mu.Lock()
+		if existing := c.refs[ref]; existing != nil {
+			c.mu.Unlock()
+			conn.Close()
+			refs[ref] = existing
+			continue
+		}
+		cache := !c.closed && c.conf == conf
+		if cache {
 			c.refs[ref] = conn
 		}
+		c.mu.Unlock()
+
+		refs[ref] = conn
+		if !cache {
+			uncached = append(uncached, conn)
+		}
 	}
+
+	return refs, uncached
 }
 
-// IsErrConnectionClosed determines if the given error is a connection closed message.
+// IsErrConnectionClosed determines if the given error is a connection closed message, including
+// operations that were interrupted because the connection closed while waiting for a response.
 // This is used interally to determine if a reconnect is required.
 func IsErrConnectionClosed(err error) bool {
-	return err != nil && strings.Contains(err.Error(), "connection closed")
+	if err == nil {
+		return false
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "connection closed") || strings.Contains(msg, "response channel closed")
 }
 
 // IsDNSanitized determines if the given DN is sanitized to prevent LDAP injection.
@@ -384,6 +1228,110 @@ func IsNameSanitized(name string) bool {
 	return !strings.ContainsAny(name, badCharacters)
 }
 
+// BuildFilter returns an LDAP filter from the given search shortcuts. If filter is set,
+// it is returned as-is. Otherwise the first of dn, cn, or byAttr (format: <attribute>=<value>)
+// is sanitized and converted into an equality filter. If none are set, an empty string is returned.
+func BuildFilter(dn, cn, byAttr, filter string) (string, error) {
+	if len(filter) > 0 {
+		return filter, nil
+	}
+
+	if len(dn) > 0 {
+		if !IsDNSanitized(dn) {
+			return "", fmt.Errorf("dn contains invalid characters: %s", dn)
+		}
+
+		return fmt.Sprintf("(%s=%s)", AttributeDistinguishedName, dn), nil
+	}
+
+	if len(cn) > 0 {
+		if !IsNameSanitized(cn) {
+			return "", fmt.Errorf("cn contains invalid characters: %s", cn)
+		}
+
+		return fmt.Sprintf("(%s=%s)", AttributeCommonName, cn), nil
+	}
+
+	if len(byAttr) > 0 {
+		if !strings.Contains(byAttr, "=") {
+			return "", fmt.Errorf("by-attr missing value to search for: %s", byAttr)
+		}
+
+		parts := strings.SplitN(byAttr, "=", 2)
+		if !IsNameSanitized(parts[0]) {
+			return "", fmt.Errorf("by-attr name contains invalid characters: %s", parts[0])
+		}
+		if !IsDNSanitized(parts[1]) {
+			return "", fmt.Errorf("by-attr value contains invalid characters: %s", parts[1])
+		}
+
+		return fmt.Sprintf("(%s=%s)", parts[0], parts[1]), nil
+	}
+
+	return "", nil
+}
+
+// FilterAttributes returns the names of the attributes tested by the given LDAP filter, in the order they appear
+// and without duplicates.
+func FilterAttributes(filter string) ([]string, error) {
+	packet, err := ldap.CompileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := []string{}
+	seen := map[string]bool{}
+
+	var walk func(p *ber.Packet)
+	walk = func(p *ber.Packet) {
+		name := ""
+
+		switch p.Tag {
+		case ldap.FilterAnd, ldap.FilterOr, ldap.FilterNot:
+			for _, child := range p.Children {
+				walk(child)
+			}
+			return
+		case ldap.FilterPresent:
+			name = p.Data.String()
+		case ldap.FilterExtensibleMatch:
+			// the attribute is optional when a matching rule is given
+			for _, child := range p.Children {
+				if child.Tag == ldap.MatchingRuleAssertionType {
+					name = child.Data.String()
+				}
+			}
+		default:
+			if len(p.Children) > 0 {
+				name = p.Children[0].Data.String()
+			}
+		}
+
+		if len(name) > 0 && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			attributes = append(attributes, name)
+		}
+	}
+
+	walk(packet)
+	return attributes, nil
+}
+
+// ParseScope returns the LDAP search scope for the given name: `base`, `one`, or `sub`.
+// An empty string defaults to `sub`.
+func ParseScope(scope string) (int, error) {
+	switch strings.ToLower(scope) {
+	case "", "sub", "subtree":
+		return ldap.ScopeWholeSubtree, nil
+	case "one", "onelevel", "single":
+		return ldap.ScopeSingleLevel, nil
+	case "base", "baseobject":
+		return ldap.ScopeBaseObject, nil
+	}
+
+	return 0, fmt.Errorf("unknown scope: %s", scope)
+}
+
 // ParseBaseDN returns only the base portion of a DN.
 func ParseBaseDN(dn string) string {
 	if len(dn) < 3 {
@@ -1,8 +1,14 @@
 package ldapcli
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
 	"testing"
 
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
 	"github.com/stretchr/testify/require"
 )
 
@@ -34,3 +40,223 @@ func TestParseBaseDNFromDomain(t *testing.T) {
 	require.Equal(t, "dc=invalid", ParseBaseDNFromDomain("invalid"))
 	require.Equal(t, "", ParseBaseDNFromDomain(""))
 }
+
+func TestBuildFilter(t *testing.T) {
+	filter, err := BuildFilter("", "", "", "(cn=*)")
+	require.NoError(t, err)
+	require.Equal(t, "(cn=*)", filter)
+
+	filter, err = BuildFilter("cn=tesla,dc=server,dc=local", "", "", "")
+	require.NoError(t, err)
+	require.Equal(t, "(distinguishedName=cn=tesla,dc=server,dc=local)", filter)
+
+	filter, err = BuildFilter("", "tesla", "", "")
+	require.NoError(t, err)
+	require.Equal(t, "(cn=tesla)", filter)
+
+	filter, err = BuildFilter("", "", "mail=tesla@server.local", "")
+	require.NoError(t, err)
+	require.Equal(t, "(mail=tesla@server.local)", filter)
+
+	filter, err = BuildFilter("", "", "", "")
+	require.NoError(t, err)
+	require.Empty(t, filter)
+
+	_, err = BuildFilter("", "te*la", "", "")
+	require.Error(t, err)
+
+	_, err = BuildFilter("", "", "mail", "")
+	require.Error(t, err)
+}
+
+func TestFilterAttributes(t *testing.T) {
+	attributes, err := FilterAttributes("(&(cn=tesla)(|(mail=*@example.com)(!(userPassword;binary=x)))(CN>=a)(description~=b)(homePhone=*))")
+	require.NoError(t, err)
+	require.Equal(t, []string{"cn", "mail", "userPassword;binary", "description", "homePhone"}, attributes)
+
+	attributes, err = FilterAttributes("(userAccountControl:1.2.840.113556.1.4.803:=2)")
+	require.NoError(t, err)
+	require.Equal(t, []string{"userAccountControl"}, attributes)
+
+	// the attribute is optional when a matching rule is given
+	attributes, err = FilterAttributes("(:caseExactMatch:=tesla)")
+	require.NoError(t, err)
+	require.Empty(t, attributes)
+
+	_, err = FilterAttributes("(cn=tesla")
+	require.Error(t, err)
+}
+
+// startPagingServer starts a server that returns one entry per page for two pages. The cookie for the second page
+// is only valid on the connection that returned the first page. If closeAfterFirstPage is set, the connection is
+// closed after the first page instead. Cookies sent on the wrong connection are counted in misplacedCookies.
+func startPagingServer(t *testing.T, closeAfterFirstPage bool) (address string, misplacedCookies *int32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	misplacedCookies = new(int32)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				cookie := ""
+
+				for {
+					packet, err := ber.ReadPacket(conn)
+					if err != nil || len(packet.Children) < 2 {
+						return
+					}
+
+					messageID := packet.Children[0].Value.(int64)
+					switch packet.Children[1].Tag {
+					case ldap.ApplicationBindRequest:
+						writeBindResponse(conn, messageID, ldap.LDAPResultSuccess, "")
+						continue
+					case ldap.ApplicationSearchRequest:
+					default:
+						return
+					}
+
+					paging := &ldap.ControlPaging{}
+					if len(packet.Children) > 2 {
+						for _, child := range packet.Children[2].Children {
+							if control, err := ldap.DecodeControl(child); err == nil {
+								if p, ok := control.(*ldap.ControlPaging); ok {
+									paging = p
+								}
+							}
+						}
+					}
+
+					switch {
+					case len(paging.Cookie) == 0:
+						cookie = fmt.Sprintf("%p", conn)
+						writeSearchPage(conn, messageID, "cn=first", cookie)
+						if closeAfterFirstPage {
+							return
+						}
+					case string(paging.Cookie) == cookie:
+						writeSearchPage(conn, messageID, "cn=second", "")
+					default:
+						atomic.AddInt32(misplacedCookies, 1)
+						writeSearchPage(conn, messageID, "cn=second", "")
+					}
+				}
+			}()
+		}
+	}()
+
+	return "ldap://" + ln.Addr().String(), misplacedCookies
+}
+
+func writeSearchPage(conn net.Conn, messageID int64, dn, cookie string) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, dn, "DN"))
+	entry.AppendChild(ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes"))
+	envelope.AppendChild(entry)
+	conn.Write(envelope.Bytes())
+
+	envelope = ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+	done := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultDone, nil, "Search Result Done")
+	done.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(ldap.LDAPResultSuccess), "Result Code"))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	done.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	envelope.AppendChild(done)
+
+	paging := ldap.NewControlPaging(0)
+	paging.SetCookie([]byte(cookie))
+	controls := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+	controls.AppendChild(paging.Encode())
+	envelope.AppendChild(controls)
+	conn.Write(envelope.Bytes())
+}
+
+func TestSearchPagesReconnect(t *testing.T) {
+	address, misplacedCookies := startPagingServer(t, false)
+
+	conf := NewConfig(address, "dc=example,dc=com")
+	conf.BindUsername = "svc-direktor"
+	conf.BindPassword = "secret"
+
+	c, err := Dial(conf)
+	require.NoError(t, err)
+	defer c.Close()
+
+	// a reconnect while paging doesn't move the rest of the search to the new connection
+	dns := []string{}
+	err = c.SearchPages(c.NewSearchRequest("(cn=*)", nil), func(page *ldap.SearchResult) error {
+		for _, e := range page.Entries {
+			dns = append(dns, e.DN)
+		}
+		if len(dns) == 1 {
+			return c.Reconnect()
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"cn=first", "cn=second"}, dns)
+	require.Zero(t, atomic.LoadInt32(misplacedCookies))
+
+	// the buffered search is restarted from the first page instead
+	resp, err := c.Search(c.NewSearchRequest("(cn=*)", nil))
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 2)
+}
+
+func TestSearchPagesConnectionClosed(t *testing.T) {
+	address, misplacedCookies := startPagingServer(t, true)
+
+	conf := NewConfig(address, "dc=example,dc=com")
+	conf.BindUsername = "svc-direktor"
+	conf.BindPassword = "secret"
+
+	c, err := Dial(conf)
+	require.NoError(t, err)
+	defer c.Close()
+
+	// the cookie isn't sent on a new connection when the one that started the search is closed
+	pages := 0
+	err = c.SearchPages(c.NewSearchRequest("(cn=*)", nil), func(page *ldap.SearchResult) error {
+		pages++
+		return nil
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "paged search interrupted")
+	require.Equal(t, 1, pages)
+	require.Zero(t, atomic.LoadInt32(misplacedCookies))
+}
+
+func TestDialURL(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	address := "ldap://" + ln.Addr().String()
+	dialer := &net.Dialer{}
+
+	conn, err := dialURL(context.Background(), dialer, address, nil)
+	require.NoError(t, err)
+	require.False(t, conn.IsClosing())
+	conn.Close()
+
+	// dialing stops when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = dialURL(ctx, dialer, address, nil)
+	require.Error(t, err)
+	require.True(t, ldap.IsErrorWithCode(err, ldap.ErrorNetwork))
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = dialURL(context.Background(), dialer, "http://"+ln.Addr().String(), nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package ldapcli
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Resolver looks up DNS SRV records. *net.Resolver implements Resolver, and it can be stubbed in tests.
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*net.SRV, err error)
+}
+
+// DiscoveryService determines which SRV records are used to discover the LDAP servers of a domain.
+type DiscoveryService string
+
+const (
+	// DiscoverLDAP uses the `_ldap._tcp.<domain>` records, which list the LDAP servers of the domain.
+	DiscoverLDAP DiscoveryService = "ldap"
+
+	// DiscoverDC uses the `_ldap._tcp.dc._msdcs.<domain>` records, which list only Active Directory domain
+	// controllers.
+	DiscoverDC DiscoveryService = "dc"
+
+	// DiscoverGC uses the `_gc._tcp.<domain>` records, which list Active Directory global catalog servers.
+	DiscoverGC DiscoveryService = "gc"
+)
+
+// ParseDiscoveryService returns the DiscoveryService with the given name. An empty string returns DiscoverLDAP.
+func ParseDiscoveryService(name string) (DiscoveryService, error) {
+	switch s := DiscoveryService(strings.ToLower(name)); s {
+	case "":
+		return DiscoverLDAP, nil
+	case DiscoverLDAP, DiscoverDC, DiscoverGC:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown discovery service, must be one of: ldap, dc, gc")
+	}
+}
+
+// record returns the arguments for looking up the SRV records of the service for the given domain.
+func (s DiscoveryService) record(domain string) (service, proto, name string) {
+	switch s {
+	case DiscoverDC:
+		return "ldap", "tcp", "dc._msdcs." + domain
+	case DiscoverGC:
+		return "gc", "tcp", domain
+	default:
+		return "ldap", "tcp", domain
+	}
+}
+
+// DiscoverAddresses looks up the SRV records of the service for the given domain, such as example.com,
+// and returns the addresses of the servers in the order they should be tried. Servers are ordered by priority,
+// and servers with the same priority are shuffled using their weight as described by RFC 2782. Servers on the
+// LDAPS ports, 636 and 3269, use the ldaps:// protocol. If resolver is nil, net.DefaultResolver is used.
+func DiscoverAddresses(ctx context.Context, resolver Resolver, domain string, service DiscoveryService) ([]string, error) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	domain = strings.TrimSuffix(domain, ".")
+	if len(domain) == 0 {
+		return nil, fmt.Errorf("domain is required for discovery")
+	}
+
+	srvService, proto, name := service.record(domain)
+	_, records, err := resolver.LookupSRV(ctx, srvService, proto, name)
+	if err != nil {
+		return nil, fmt.Errorf("discovering LDAP servers: %w", err)
+	}
+
+	addresses := []string{}
+	for _, srv := range orderSRV(records, rand.Intn) {
+		target := strings.TrimSuffix(srv.Target, ".")
+
+		// a target of "." means the service is not available in the domain
+		if len(target) == 0 {
+			continue
+		}
+
+		scheme := "ldap"
+		if srv.Port == 636 || srv.Port == 3269 {
+			scheme = "ldaps"
+		}
+
+		addresses = append(addresses, fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(target, strconv.Itoa(int(srv.Port)))))
+	}
+
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("discovering LDAP servers: no %s servers found for domain: %s", service, domain)
+	}
+
+	return addresses, nil
+}
+
+// orderSRV returns the records sorted by priority, with records of the same priority in a random order weighted
+// by their weight. intn returns a random number in [0, n).
+func orderSRV(records []*net.SRV, intn func(n int) int) []*net.SRV {
+	sorted := append([]*net.SRV{}, records...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	ordered := make([]*net.SRV, 0, len(sorted))
+	for start := 0; start < len(sorted); {
+		end := start
+		for end < len(sorted) && sorted[end].Priority == sorted[start].Priority {
+			end++
+		}
+
+		// records with a weight of zero are placed first, so they have a small chance of being selected
+		group := append([]*net.SRV{}, sorted[start:end]...)
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Weight == 0 && group[j].Weight > 0
+		})
+
+		for len(group) > 0 {
+			total := 0
+			for _, srv := range group {
+				total += int(srv.Weight)
+			}
+
+			// select the first record whose running sum of weights is at least a random number in [0, total]
+			n := intn(total + 1)
+			selected := len(group) - 1
+			sum := 0
+			for i, srv := range group {
+				sum += int(srv.Weight)
+				if sum >= n {
+					selected = i
+					break
+				}
+			}
+
+			ordered = append(ordered, group[selected])
+			group = append(group[:selected], group[selected+1:]...)
+		}
+
+		start = end
+	}
+
+	return ordered
+}
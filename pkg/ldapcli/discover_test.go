@@ -0,0 +1,128 @@
+package ldapcli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stubResolver returns the SRV records for each record name, e.g. _ldap._tcp.example.com.
+type stubResolver map[string][]*net.SRV
+
+func (r stubResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	record := fmt.Sprintf("_%s._%s.%s", service, proto, name)
+	records, ok := r[record]
+	if !ok {
+		return "", nil, &net.DNSError{Err: "no such host", Name: record, IsNotFound: true}
+	}
+	return record, records, nil
+}
+
+func TestDiscoverAddresses(t *testing.T) {
+	resolver := stubResolver{
+		"_ldap._tcp.example.com": {
+			{Target: "ldap2.example.com.", Port: 389, Priority: 10},
+			{Target: "ldap1.example.com.", Port: 636, Priority: 0},
+		},
+		"_ldap._tcp.dc._msdcs.example.com": {
+			{Target: "dc1.example.com.", Port: 389},
+		},
+		"_gc._tcp.example.com": {
+			{Target: "gc1.example.com.", Port: 3268},
+			{Target: "gc2.example.com.", Port: 3269, Priority: 1},
+		},
+		"_ldap._tcp.unavailable.com": {
+			{Target: ".", Port: 0},
+		},
+	}
+
+	ctx := context.Background()
+
+	addresses, err := DiscoverAddresses(ctx, resolver, "example.com", DiscoverLDAP)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ldaps://ldap1.example.com:636", "ldap://ldap2.example.com:389"}, addresses)
+
+	addresses, err = DiscoverAddresses(ctx, resolver, "example.com.", DiscoverDC)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ldap://dc1.example.com:389"}, addresses)
+
+	addresses, err = DiscoverAddresses(ctx, resolver, "example.com", DiscoverGC)
+	require.NoError(t, err)
+	require.Equal(t, []string{"ldap://gc1.example.com:3268", "ldaps://gc2.example.com:3269"}, addresses)
+
+	_, err = DiscoverAddresses(ctx, resolver, "unavailable.com", DiscoverLDAP)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no ldap servers found")
+
+	_, err = DiscoverAddresses(ctx, resolver, "unknown.com", DiscoverLDAP)
+	require.Error(t, err)
+
+	dnsErr := &net.DNSError{}
+	require.True(t, errors.As(err, &dnsErr))
+	require.True(t, dnsErr.IsNotFound)
+
+	_, err = DiscoverAddresses(ctx, resolver, "", DiscoverLDAP)
+	require.Error(t, err)
+}
+
+func TestOrderSRV(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "a", Priority: 10, Weight: 60},
+		{Target: "b", Priority: 10, Weight: 40},
+		{Target: "c", Priority: 0, Weight: 0},
+		{Target: "d", Priority: 10, Weight: 0},
+		{Target: "e", Priority: 20, Weight: 0},
+	}
+
+	targets := func(records []*net.SRV) []string {
+		t := []string{}
+		for _, srv := range records {
+			t = append(t, srv.Target)
+		}
+		return t
+	}
+
+	// a random number of zero selects a record with a weight of zero, or the first record
+	lowest := func(n int) int { return 0 }
+	require.Equal(t, []string{"c", "d", "a", "b", "e"}, targets(orderSRV(records, lowest)))
+
+	// the highest random number selects the last record
+	highest := func(n int) int { return n - 1 }
+	require.Equal(t, []string{"c", "b", "a", "d", "e"}, targets(orderSRV(records, highest)))
+
+	// a random number within the weight of the first record selects it
+	require.Equal(t, []string{"c", "a", "b", "d", "e"}, targets(orderSRV(records, func(n int) int {
+		if n > 1 {
+			return 60
+		}
+		return 0
+	})))
+
+	// heavier records are selected first more often
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		counts[orderSRV(records[:2], rand.Intn)[0].Target]++
+	}
+	require.Greater(t, counts["a"], counts["b"])
+}
+
+func TestParseDiscoveryService(t *testing.T) {
+	for name, expected := range map[string]DiscoveryService{
+		"":     DiscoverLDAP,
+		"ldap": DiscoverLDAP,
+		"DC":   DiscoverDC,
+		"gc":   DiscoverGC,
+	} {
+		service, err := ParseDiscoveryService(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, service)
+	}
+
+	_, err := ParseDiscoveryService("kerberos")
+	require.Error(t, err)
+}
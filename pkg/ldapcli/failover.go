@@ -0,0 +1,76 @@
+package ldapcli
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// DefaultFailoverCooldown is how long an unreachable address is skipped if Config.FailoverCooldown is not set.
+const DefaultFailoverCooldown = time.Minute
+
+// cooldowns records when unreachable addresses may be tried again. It is shared by all clients, so a server
+// that is down is skipped by every client until its cooldown ends.
+var cooldowns = struct {
+	sync.Mutex
+	until map[string]time.Time
+}{until: map[string]time.Time{}}
+
+// now is replaced in tests.
+var now = time.Now
+
+// markUnhealthy puts the given address in cooldown for the given duration.
+func markUnhealthy(address string, cooldown time.Duration) {
+	cooldowns.Lock()
+	cooldowns.until[address] = now().Add(cooldown)
+	cooldowns.Unlock()
+}
+
+// markHealthy ends the cooldown of the given address.
+func markHealthy(address string) {
+	cooldowns.Lock()
+	delete(cooldowns.until, address)
+	cooldowns.Unlock()
+}
+
+// orderAddresses returns the addresses in the order they should be tried. Addresses that are not in cooldown come
+// first, in the order given, followed by addresses in cooldown ordered by when their cooldown ends, so a client
+// can still connect if every server was recently unreachable.
+func orderAddresses(addresses []string) []string {
+	healthy := []string{}
+	cooling := []string{}
+	until := map[string]time.Time{}
+
+	cooldowns.Lock()
+	t := now()
+	for _, address := range addresses {
+		if u, ok := cooldowns.until[address]; ok && u.After(t) {
+			cooling = append(cooling, address)
+			until[address] = u
+		} else {
+			healthy = append(healthy, address)
+		}
+	}
+	cooldowns.Unlock()
+
+	sort.SliceStable(cooling, func(i, j int) bool {
+		return until[cooling[i]].Before(until[cooling[j]])
+	})
+
+	return append(healthy, cooling...)
+}
+
+// isFailoverError returns true if the error means the server could not be reached or the connection was lost,
+// in which case the next address should be tried. Other errors, such as invalid credentials, would be the same
+// on every server.
+func isFailoverError(err error) bool {
+	var ldapErr *ldap.Error
+	if errors.As(err, &ldapErr) && ldapErr.ResultCode == ldap.ErrorNetwork {
+		return true
+	}
+
+	return IsErrConnectionClosed(err)
+}
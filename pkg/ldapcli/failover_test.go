@@ -0,0 +1,71 @@
+package ldapcli
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderAddresses(t *testing.T) {
+	start := time.Now()
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	addresses := []string{"ldap://dc1:389", "ldap://dc2:389", "ldap://dc3:389", "ldap://dc4:389"}
+	defer func() {
+		for _, address := range addresses {
+			markHealthy(address)
+		}
+	}()
+
+	require.Equal(t, addresses, orderAddresses(addresses))
+
+	// addresses in cooldown are tried last, in the order their cooldown ends
+	markUnhealthy("ldap://dc1:389", 2*time.Minute)
+	markUnhealthy("ldap://dc3:389", time.Minute)
+	require.Equal(t, []string{"ldap://dc2:389", "ldap://dc4:389", "ldap://dc3:389", "ldap://dc1:389"}, orderAddresses(addresses))
+
+	// once the cooldown ends, the address is tried in its original position again
+	now = func() time.Time { return start.Add(90 * time.Second) }
+	require.Equal(t, []string{"ldap://dc2:389", "ldap://dc3:389", "ldap://dc4:389", "ldap://dc1:389"}, orderAddresses(addresses))
+
+	markHealthy("ldap://dc1:389")
+	require.Equal(t, addresses, orderAddresses(addresses))
+}
+
+func TestConfigAddresses(t *testing.T) {
+	conf := &Config{
+		Addresses: []string{"ldap://dc1:389", "ldap://dc2:389", "ldap://dc1:389", ""},
+		BaseDN:    "dc=example,dc=com",
+	}
+
+	require.NoError(t, conf.Validate())
+	require.Equal(t, "ldap://dc1:389", conf.Address)
+	require.Equal(t, []string{"ldap://dc1:389", "ldap://dc2:389"}, conf.addresses())
+	require.Equal(t, DefaultFailoverCooldown, conf.FailoverCooldown)
+
+	require.Error(t, (&Config{BaseDN: "dc=example,dc=com"}).Validate())
+
+	// discovered addresses follow the configured addresses in the order of their SRV records, even when the
+	// configured addresses are randomized
+	conf.Addresses = []string{"ldap://dc1:389", "ldap://dc2:389", "ldap://dc3:389"}
+	conf.RandomizeAddresses = true
+	discovered := []string{"ldap://dc5:389", "ldap://dc2:389", "ldap://dc4:389"}
+
+	for i := 0; i < 10; i++ {
+		addresses := conf.addresses(discovered...)
+		require.Len(t, addresses, 5)
+		require.ElementsMatch(t, conf.Addresses, addresses[:3])
+		require.Equal(t, []string{"ldap://dc5:389", "ldap://dc4:389"}, addresses[3:])
+	}
+}
+
+func TestIsFailoverError(t *testing.T) {
+	require.True(t, isFailoverError(ldap.NewError(ldap.ErrorNetwork, fmt.Errorf("connection refused"))))
+	require.True(t, isFailoverError(fmt.Errorf("starting TLS: %w", ldap.NewError(ldap.ErrorNetwork, fmt.Errorf("EOF")))))
+	require.True(t, isFailoverError(fmt.Errorf("ldap: connection closed")))
+	require.False(t, isFailoverError(fmt.Errorf("binding to LDAP: %w", ldap.NewError(ldap.LDAPResultInvalidCredentials, fmt.Errorf("invalid credentials")))))
+}
@@ -0,0 +1,157 @@
+package ldapcli
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/go-ldap/ldap/v3/gssapi"
+)
+
+// AuthMode determines how a Client authenticates to the server.
+type AuthMode string
+
+const (
+	// AuthSimple binds with BindUsername and BindPassword, or anonymously if there is no password.
+	AuthSimple AuthMode = "simple"
+
+	// AuthGSSAPI binds with Kerberos using the SASL GSSAPI mechanism, so the password is never sent to the server.
+	AuthGSSAPI AuthMode = "gssapi"
+
+	// AuthExternal binds using the SASL EXTERNAL mechanism, so the server identifies the client by its TLS client
+	// certificate, or by the user running the client when connecting to an ldapi:// socket.
+	AuthExternal AuthMode = "external"
+)
+
+// ParseAuthMode returns the AuthMode with the given name. An empty string returns AuthSimple.
+func ParseAuthMode(name string) (AuthMode, error) {
+	switch m := AuthMode(strings.ToLower(name)); m {
+	case "":
+		return AuthSimple, nil
+	case AuthSimple, AuthGSSAPI, AuthExternal:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown auth mode, must be one of: simple, gssapi, external")
+	}
+}
+
+// GSSAPIClientFunc returns the client that performs the GSSAPI exchange when binding with the given config.
+type GSSAPIClientFunc func(conf *Config) (ldap.GSSAPIClient, error)
+
+// NewKerberosClient returns a Kerberos client for binding with the given config. The client authenticates as
+// BindUsername using KeytabPath if set, or using BindPassword if set, and otherwise uses the tickets in the
+// credential cache at CCachePath, unless DisableCCache is set. BindUsername may include the realm, e.g.
+// svc-direktor@EXAMPLE.COM.
+func NewKerberosClient(conf *Config) (ldap.GSSAPIClient, error) {
+	krb5conf := conf.Krb5ConfPath
+	if len(krb5conf) == 0 {
+		krb5conf = defaultKrb5ConfPath()
+	}
+
+	username, realm := kerberosPrincipal(conf.BindUsername, conf.KerberosRealm)
+
+	switch {
+	case len(conf.KeytabPath) > 0:
+		if len(username) == 0 {
+			return nil, fmt.Errorf("a username is required to authenticate with a keytab")
+		}
+		return gssapi.NewClientWithKeytab(username, realm, conf.KeytabPath, krb5conf)
+	case len(conf.BindPassword) > 0:
+		if len(username) == 0 {
+			return nil, fmt.Errorf("a username is required to authenticate with a password")
+		}
+		return gssapi.NewClientWithPassword(username, realm, conf.BindPassword, krb5conf)
+	case conf.DisableCCache:
+		return nil, fmt.Errorf("a keytab or password is required to authenticate, the credential cache is disabled")
+	default:
+		ccache := conf.CCachePath
+		if len(ccache) == 0 {
+			var err error
+			if ccache, err = defaultCCachePath(); err != nil {
+				return nil, err
+			}
+		}
+		return gssapi.NewClientFromCCache(ccache, krb5conf)
+	}
+}
+
+// gssapiBind binds the given connection to the server at the given address using the SASL GSSAPI mechanism.
+func gssapiBind(conn *ldap.Conn, conf *Config, address string) error {
+	newClient := conf.GSSAPIClient
+	if newClient == nil {
+		newClient = NewKerberosClient
+	}
+
+	client, err := newClient(conf)
+	if err != nil {
+		return fmt.Errorf("creating Kerberos client: %w", err)
+	}
+	if closer, ok := client.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	spn, err := conf.servicePrincipalName(address)
+	if err != nil {
+		return err
+	}
+
+	if err := conn.GSSAPIBind(client, spn, ""); err != nil {
+		return fmt.Errorf("GSSAPI bind to LDAP: %w", err)
+	}
+
+	return nil
+}
+
+// servicePrincipalName returns ServicePrincipalName, or the LDAP service principal of the host at the given
+// address, e.g. ldap/dc1.example.com.
+func (c *Config) servicePrincipalName(address string) (string, error) {
+	if len(c.ServicePrincipalName) > 0 {
+		return c.ServicePrincipalName, nil
+	}
+
+	u, err := url.Parse(address)
+	if err != nil || len(u.Hostname()) == 0 {
+		return "", fmt.Errorf("cannot determine the service principal name for address: %s", address)
+	}
+
+	return "ldap/" + strings.ToLower(u.Hostname()), nil
+}
+
+// kerberosPrincipal splits the realm from a username in the form user@REALM. If the username has no realm,
+// the given realm is used, and an empty realm means the default realm from krb5.conf.
+func kerberosPrincipal(username, realm string) (string, string) {
+	if idx := strings.LastIndex(username, "@"); idx > -1 {
+		return username[:idx], strings.ToUpper(username[idx+1:])
+	}
+	return username, realm
+}
+
+// defaultKrb5ConfPath returns the path of krb5.conf given by the KRB5_CONFIG environment variable,
+// or /etc/krb5.conf.
+func defaultKrb5ConfPath() string {
+	if path := os.Getenv("KRB5_CONFIG"); len(path) > 0 {
+		return path
+	}
+	return "/etc/krb5.conf"
+}
+
+// defaultCCachePath returns the path of the credential cache given by the KRB5CCNAME environment variable,
+// or /tmp/krb5cc_<uid>. Only file credential caches are supported.
+func defaultCCachePath() (string, error) {
+	name := os.Getenv("KRB5CCNAME")
+	if len(name) == 0 {
+		return fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid()), nil
+	}
+
+	if idx := strings.Index(name, ":"); idx > -1 {
+		if name[:idx] != "FILE" {
+			return "", fmt.Errorf("unsupported credential cache type, only FILE is supported: %s", name)
+		}
+		name = name[idx+1:]
+	}
+
+	return name, nil
+}
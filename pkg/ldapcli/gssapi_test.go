@@ -0,0 +1,261 @@
+package ldapcli
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	stubAPReq     = "stub-ap-req"
+	stubAPRep     = "stub-ap-rep"
+	stubWrapToken = "\x01\x00\x10\x00" // integrity protection offered, 4096 byte receive buffer
+	stubWrapReply = "\x00\x00\x00\x00" // no security layer
+)
+
+// stubGSSAPIClient performs a GSSAPI exchange with fixed tokens in place of Kerberos.
+type stubGSSAPIClient struct {
+	targets []string
+	closed  bool
+}
+
+func (c *stubGSSAPIClient) InitSecContext(target string, token []byte) ([]byte, bool, error) {
+	c.targets = append(c.targets, target)
+	if token == nil {
+		return []byte(stubAPReq), true, nil
+	}
+	if string(token) != stubAPRep {
+		return nil, false, fmt.Errorf("unexpected token from server: %q", token)
+	}
+	return []byte{}, false, nil
+}
+
+func (c *stubGSSAPIClient) NegotiateSaslAuth(token []byte, authzid string) ([]byte, error) {
+	if string(token) != stubWrapToken {
+		return nil, fmt.Errorf("unexpected wrap token from server: %q", token)
+	}
+	return []byte(stubWrapReply), nil
+}
+
+func (c *stubGSSAPIClient) DeleteSecContext() error {
+	return nil
+}
+
+func (c *stubGSSAPIClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+// startSASLServer starts an LDAP server that only answers GSSAPI binds, expecting the tokens sent by
+// stubGSSAPIClient. The mechanism and credentials of each bind request are sent to the returned channel.
+func startSASLServer(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	binds := make(chan string, 10)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			packet, err := ber.ReadPacket(conn)
+			if err != nil || len(packet.Children) < 2 {
+				return
+			}
+
+			messageID := packet.Children[0].Value.(int64)
+			op := packet.Children[1]
+			if op.Tag != ldap.ApplicationBindRequest {
+				return
+			}
+
+			// the authentication choice is the third child, with tag 3 for SASL
+			auth := op.Children[2]
+			mechanism := auth.Children[0].Data.String()
+			credentials := ""
+			if len(auth.Children) > 1 {
+				credentials = auth.Children[1].Data.String()
+			}
+			binds <- mechanism + ":" + credentials
+
+			switch {
+			case mechanism != "GSSAPI":
+				writeBindResponse(conn, messageID, ldap.LDAPResultAuthMethodNotSupported, "")
+			case credentials == stubAPReq:
+				writeBindResponse(conn, messageID, ldap.LDAPResultSaslBindInProgress, stubAPRep)
+			case credentials == "":
+				writeBindResponse(conn, messageID, ldap.LDAPResultSaslBindInProgress, stubWrapToken)
+			case credentials == stubWrapReply:
+				writeBindResponse(conn, messageID, ldap.LDAPResultSuccess, "")
+			default:
+				writeBindResponse(conn, messageID, ldap.LDAPResultInvalidCredentials, "")
+			}
+		}
+	}()
+
+	return "ldap://" + ln.Addr().String(), binds
+}
+
+func writeBindResponse(conn net.Conn, messageID int64, resultCode uint16, serverSaslCreds string) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "Message ID"))
+
+	resp := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationBindResponse, nil, "Bind Response")
+	resp.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(resultCode), "Result Code"))
+	resp.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	resp.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	if len(serverSaslCreds) > 0 {
+		resp.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 7, serverSaslCreds, "Server SASL Credentials"))
+	}
+	envelope.AppendChild(resp)
+
+	conn.Write(envelope.Bytes())
+}
+
+func TestGSSAPIBind(t *testing.T) {
+	address, binds := startSASLServer(t)
+	stub := &stubGSSAPIClient{}
+
+	conf := NewConfig(address, "dc=example,dc=com")
+	conf.AuthMode = AuthGSSAPI
+	conf.ServicePrincipalName = "ldap/dc1.example.com"
+	conf.GSSAPIClient = func(conf *Config) (ldap.GSSAPIClient, error) {
+		return stub, nil
+	}
+
+	cli, err := Dial(conf)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	require.Equal(t, "GSSAPI:"+stubAPReq, <-binds)
+	require.Equal(t, "GSSAPI:", <-binds)
+	require.Equal(t, "GSSAPI:"+stubWrapReply, <-binds)
+	require.Equal(t, []string{"ldap/dc1.example.com", "ldap/dc1.example.com"}, stub.targets)
+	require.True(t, stub.closed)
+}
+
+func TestGSSAPIBindError(t *testing.T) {
+	address, _ := startSASLServer(t)
+
+	conf := NewConfig(address, "dc=example,dc=com")
+	conf.AuthMode = AuthGSSAPI
+	conf.GSSAPIClient = func(conf *Config) (ldap.GSSAPIClient, error) {
+		return nil, fmt.Errorf("no credentials")
+	}
+
+	_, err := Dial(conf)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "creating Kerberos client: no credentials")
+
+	// the server rejects tokens it doesn't expect
+	conf.GSSAPIClient = func(conf *Config) (ldap.GSSAPIClient, error) {
+		return &badGSSAPIClient{}, nil
+	}
+
+	address, _ = startSASLServer(t)
+	conf.Address = address
+
+	_, err = Dial(conf)
+	require.Error(t, err)
+	require.True(t, ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials))
+}
+
+// badGSSAPIClient sends a token the server doesn't accept.
+type badGSSAPIClient struct {
+	stubGSSAPIClient
+}
+
+func (c *badGSSAPIClient) InitSecContext(target string, token []byte) ([]byte, bool, error) {
+	return []byte("forged"), true, nil
+}
+
+func TestNewKerberosClientDisableCCache(t *testing.T) {
+	conf := NewConfig("ldap://dc1.example.com", "dc=example,dc=com")
+	conf.AuthMode = AuthGSSAPI
+	conf.BindUsername = "newton"
+	conf.CCachePath = filepath.Join(t.TempDir(), "krb5cc_missing")
+
+	_, err := NewKerberosClient(conf)
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "credential cache is disabled")
+
+	// without a keytab or password, servers don't fall back to their own credential cache
+	conf.DisableCCache = true
+	_, err = NewKerberosClient(conf)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "credential cache is disabled")
+}
+
+func TestServicePrincipalName(t *testing.T) {
+	conf := &Config{}
+
+	spn, err := conf.servicePrincipalName("ldaps://DC1.example.com:636")
+	require.NoError(t, err)
+	require.Equal(t, "ldap/dc1.example.com", spn)
+
+	_, err = conf.servicePrincipalName("")
+	require.Error(t, err)
+
+	conf.ServicePrincipalName = "ldap/ldap.example.com@EXAMPLE.COM"
+	spn, err = conf.servicePrincipalName("ldap://10.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, "ldap/ldap.example.com@EXAMPLE.COM", spn)
+}
+
+func TestKerberosPrincipal(t *testing.T) {
+	username, realm := kerberosPrincipal("svc-direktor@example.com", "OTHER.COM")
+	require.Equal(t, "svc-direktor", username)
+	require.Equal(t, "EXAMPLE.COM", realm)
+
+	username, realm = kerberosPrincipal("svc-direktor", "EXAMPLE.COM")
+	require.Equal(t, "svc-direktor", username)
+	require.Equal(t, "EXAMPLE.COM", realm)
+}
+
+func TestDefaultCCachePath(t *testing.T) {
+	if ccache, ok := os.LookupEnv("KRB5CCNAME"); ok {
+		defer os.Setenv("KRB5CCNAME", ccache)
+	} else {
+		defer os.Unsetenv("KRB5CCNAME")
+	}
+
+	os.Setenv("KRB5CCNAME", "FILE:/tmp/krb5cc_test")
+	path, err := defaultCCachePath()
+	require.NoError(t, err)
+	require.Equal(t, "/tmp/krb5cc_test", path)
+
+	os.Setenv("KRB5CCNAME", "KEYRING:persistent:1000")
+	_, err = defaultCCachePath()
+	require.Error(t, err)
+
+	os.Setenv("KRB5CCNAME", "")
+	path, err = defaultCCachePath()
+	require.NoError(t, err)
+	require.Contains(t, path, "/tmp/krb5cc_")
+}
+
+func TestParseAuthMode(t *testing.T) {
+	mode, err := ParseAuthMode("")
+	require.NoError(t, err)
+	require.Equal(t, AuthSimple, mode)
+
+	mode, err = ParseAuthMode("GSSAPI")
+	require.NoError(t, err)
+	require.Equal(t, AuthGSSAPI, mode)
+
+	_, err = ParseAuthMode("ntlm")
+	require.Error(t, err)
+
+	require.Error(t, (&Config{Address: "ldap://localhost", BaseDN: "dc=example,dc=com", AuthMode: "ntlm"}).Validate())
+}
@@ -0,0 +1,213 @@
+package ldapcli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	// AttributeNamingContexts is the name of the RootDSE attribute listing the naming contexts held by the server.
+	AttributeNamingContexts = "namingContexts"
+
+	// AttributeDefaultNamingContext is the name of the RootDSE attribute with the DN of the server's domain.
+	AttributeDefaultNamingContext = "defaultNamingContext"
+
+	// AttributeRootDomainNamingContext is the name of the RootDSE attribute with the DN of the forest root domain.
+	AttributeRootDomainNamingContext = "rootDomainNamingContext"
+
+	// AttributeSupportedControl is the name of the RootDSE attribute listing the OIDs of supported controls.
+	AttributeSupportedControl = "supportedControl"
+
+	// AttributeSupportedSASLMechanisms is the name of the RootDSE attribute listing the supported SASL mechanisms.
+	AttributeSupportedSASLMechanisms = "supportedSASLMechanisms"
+
+	// AttributeSupportedLDAPVersion is the name of the RootDSE attribute listing the supported LDAP versions.
+	AttributeSupportedLDAPVersion = "supportedLDAPVersion"
+
+	// AttributeVendorName is the name of the RootDSE attribute with the name of the server's vendor.
+	AttributeVendorName = "vendorName"
+
+	// AttributeVendorVersion is the name of the RootDSE attribute with the version of the server.
+	AttributeVendorVersion = "vendorVersion"
+
+	// AttributeDNSHostName is the name of the RootDSE attribute with the DNS host name of an AD domain controller.
+	AttributeDNSHostName = "dnsHostName"
+
+	// AttributeForestFunctionality is the name of the RootDSE attribute with the AD forest functional level.
+	AttributeForestFunctionality = "forestFunctionality"
+
+	// AttributeDomainFunctionality is the name of the RootDSE attribute with the AD domain functional level.
+	AttributeDomainFunctionality = "domainFunctionality"
+
+	// AttributeDomainControllerFunctionality is the name of the RootDSE attribute with the AD functional level
+	// of the domain controller.
+	AttributeDomainControllerFunctionality = "domainControllerFunctionality"
+
+	// ControlPagedResults is the OID of the paged results control.
+	ControlPagedResults = "1.2.840.113556.1.4.319"
+)
+
+// RootDSEAttributes are the attributes read from the RootDSE. Most are operational attributes, which servers
+// only return when asked for by name.
+var RootDSEAttributes = []string{
+	AttributeNamingContexts,
+	AttributeDefaultNamingContext,
+	AttributeRootDomainNamingContext,
+	AttributeSupportedControl,
+	AttributeSupportedSASLMechanisms,
+	AttributeSupportedLDAPVersion,
+	AttributeVendorName,
+	AttributeVendorVersion,
+	AttributeDNSHostName,
+	AttributeForestFunctionality,
+	AttributeDomainFunctionality,
+	AttributeDomainControllerFunctionality,
+}
+
+// FunctionalLevel is an Active Directory forest, domain, or domain controller functional level.
+type FunctionalLevel int
+
+// FunctionalLevelUnknown means the server did not return a functional level, such as a server that isn't
+// Active Directory.
+const FunctionalLevelUnknown FunctionalLevel = -1
+
+var functionalLevelNames = map[FunctionalLevel]string{
+	0:  "Windows 2000",
+	1:  "Windows Server 2003 interim",
+	2:  "Windows Server 2003",
+	3:  "Windows Server 2008",
+	4:  "Windows Server 2008 R2",
+	5:  "Windows Server 2012",
+	6:  "Windows Server 2012 R2",
+	7:  "Windows Server 2016",
+	10: "Windows Server 2025",
+}
+
+// String returns the name of the Windows Server version that introduced the functional level.
+func (l FunctionalLevel) String() string {
+	if l == FunctionalLevelUnknown {
+		return "unknown"
+	}
+	if name, ok := functionalLevelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level %d", int(l))
+}
+
+// RootDSE is the information a server publishes about itself in the entry with an empty DN.
+type RootDSE struct {
+	NamingContexts                []string
+	DefaultNamingContext          string
+	RootDomainNamingContext       string
+	SupportedControls             []string
+	SupportedSASLMechanisms       []string
+	SupportedLDAPVersions         []int
+	VendorName                    string
+	VendorVersion                 string
+	DNSHostName                   string
+	ForestFunctionality           FunctionalLevel
+	DomainFunctionality           FunctionalLevel
+	DomainControllerFunctionality FunctionalLevel
+	Entry                         *ldap.Entry // the entry the RootDSE was parsed from
+}
+
+// ParseRootDSE parses the RootDSE from the given entry.
+func ParseRootDSE(e *ldap.Entry) *RootDSE {
+	r := &RootDSE{
+		NamingContexts:                e.GetEqualFoldAttributeValues(AttributeNamingContexts),
+		DefaultNamingContext:          e.GetEqualFoldAttributeValue(AttributeDefaultNamingContext),
+		RootDomainNamingContext:       e.GetEqualFoldAttributeValue(AttributeRootDomainNamingContext),
+		SupportedControls:             e.GetEqualFoldAttributeValues(AttributeSupportedControl),
+		SupportedSASLMechanisms:       e.GetEqualFoldAttributeValues(AttributeSupportedSASLMechanisms),
+		VendorName:                    e.GetEqualFoldAttributeValue(AttributeVendorName),
+		VendorVersion:                 e.GetEqualFoldAttributeValue(AttributeVendorVersion),
+		DNSHostName:                   e.GetEqualFoldAttributeValue(AttributeDNSHostName),
+		ForestFunctionality:           parseFunctionalLevel(e.GetEqualFoldAttributeValue(AttributeForestFunctionality)),
+		DomainFunctionality:           parseFunctionalLevel(e.GetEqualFoldAttributeValue(AttributeDomainFunctionality)),
+		DomainControllerFunctionality: parseFunctionalLevel(e.GetEqualFoldAttributeValue(AttributeDomainControllerFunctionality)),
+		Entry:                         e,
+	}
+
+	for _, v := range e.GetEqualFoldAttributeValues(AttributeSupportedLDAPVersion) {
+		if version, err := strconv.Atoi(v); err == nil {
+			r.SupportedLDAPVersions = append(r.SupportedLDAPVersions, version)
+		}
+	}
+
+	return r
+}
+
+func parseFunctionalLevel(value string) FunctionalLevel {
+	level, err := strconv.Atoi(value)
+	if err != nil {
+		return FunctionalLevelUnknown
+	}
+	return FunctionalLevel(level)
+}
+
+// BaseDN returns the default naming context, or the first naming context if the server doesn't have a default.
+func (r *RootDSE) BaseDN() string {
+	if len(r.DefaultNamingContext) > 0 {
+		return r.DefaultNamingContext
+	}
+	if len(r.NamingContexts) > 0 {
+		return r.NamingContexts[0]
+	}
+	return ""
+}
+
+// SupportsControl returns true if the server supports the control with the given OID.
+func (r *RootDSE) SupportsControl(oid string) bool {
+	for _, c := range r.SupportedControls {
+		if c == oid {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsSASLMechanism returns true if the server supports the given SASL mechanism, such as GSSAPI.
+func (r *RootDSE) SupportsSASLMechanism(mechanism string) bool {
+	for _, m := range r.SupportedSASLMechanisms {
+		if strings.EqualFold(m, mechanism) {
+			return true
+		}
+	}
+	return false
+}
+
+// RootDSE reads the RootDSE of the server the client is connected to.
+func (c *Client) RootDSE() (*RootDSE, error) {
+	return c.RootDSEContext(context.Background())
+}
+
+// RootDSEContext is the same as RootDSE, but abandons the search when the context is done.
+func (c *Client) RootDSEContext(ctx context.Context) (*RootDSE, error) {
+	var r *RootDSE
+	err := c.do(ctx, func(conn *ldap.Conn) error {
+		var err error
+		r, err = readRootDSE(ctx, conn)
+		return err
+	})
+
+	return r, err
+}
+
+// readRootDSE reads the RootDSE using the given connection, which doesn't need to be bound.
+func readRootDSE(ctx context.Context, conn *ldap.Conn) (*RootDSE, error) {
+	req := ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, 0, false, "(objectClass=*)", RootDSEAttributes, nil)
+	resp, err := searchAsync(ctx, conn, req)
+	if err != nil {
+		return nil, fmt.Errorf("reading RootDSE: %w", err)
+	}
+
+	if len(resp.Entries) == 0 {
+		return nil, fmt.Errorf("reading RootDSE: no entry returned")
+	}
+
+	return ParseRootDSE(resp.Entries[0]), nil
+}
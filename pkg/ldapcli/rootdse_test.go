@@ -0,0 +1,41 @@
+package ldapcli
+
+import (
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRootDSE(t *testing.T) {
+	e := ldap.NewEntry("", map[string][]string{
+		"namingContexts":          {"o=example", "cn=config"},
+		"supportedLDAPVersion":    {"3"},
+		"supportedSASLMechanisms": {"EXTERNAL"},
+		"vendorName":              {"Example Corp"},
+	})
+
+	root := ParseRootDSE(e)
+	require.Equal(t, "o=example", root.BaseDN())
+	require.Equal(t, []int{3}, root.SupportedLDAPVersions)
+	require.True(t, root.SupportsSASLMechanism("external"))
+	require.False(t, root.SupportsSASLMechanism("GSSAPI"))
+	require.False(t, root.SupportsControl(ControlPagedResults))
+	require.Equal(t, "Example Corp", root.VendorName)
+	require.Equal(t, FunctionalLevelUnknown, root.ForestFunctionality)
+	require.Equal(t, "unknown", root.ForestFunctionality.String())
+	require.Equal(t, "level 8", FunctionalLevel(8).String())
+
+	// AD returns dNSHostName, which is matched regardless of case
+	e = ldap.NewEntry("", map[string][]string{
+		"defaultNamingContext": {"dc=example,dc=com"},
+		"namingContexts":       {"cn=Configuration,dc=example,dc=com", "dc=example,dc=com"},
+		"dNSHostName":          {"dc1.example.com"},
+	})
+
+	root = ParseRootDSE(e)
+	require.Equal(t, "dc=example,dc=com", root.BaseDN())
+	require.Equal(t, "dc1.example.com", root.DNSHostName)
+
+	require.Empty(t, ParseRootDSE(ldap.NewEntry("", nil)).BaseDN())
+}
@@ -1,6 +1,7 @@
 package ldapcli
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -12,6 +13,11 @@ import (
 // Optionally, a list of attributes for the members can also be returned.
 // If attributes is empty, only the objectClass attribute is returned.
 func (c *Client) GroupMembers(groupDN string, attributes ...string) (*ldap.SearchResult, error) {
+	return c.GroupMembersContext(context.Background(), groupDN, attributes...)
+}
+
+// GroupMembersContext is the same as GroupMembers, but abandons the search when the context is done.
+func (c *Client) GroupMembersContext(ctx context.Context, groupDN string, attributes ...string) (*ldap.SearchResult, error) {
 	// perform a (memberOf=groupDN) search with desired attributes
 	filter := fmt.Sprintf(`(%s=%s)`, AttributeMemberOf, groupDN)
 	if attributes == nil || len(attributes) == 0 {
@@ -19,7 +25,7 @@ func (c *Client) GroupMembers(groupDN string, attributes ...string) (*ldap.Searc
 	}
 
 	req := c.NewSearchRequest(filter, attributes)
-	return c.Search(req)
+	return c.SearchContext(ctx, req)
 }
 
 // GroupMembersExtended gets members from a call to GroupMembers, then attempts
@@ -27,12 +33,18 @@ func (c *Client) GroupMembers(groupDN string, attributes ...string) (*ldap.Searc
 // This is done by querying the group's `member` attribute and performing additional searches
 // to retreive the requested attributes for any newly discovered members.
 func (c *Client) GroupMembersExtended(groupDN string, attributes ...string) (*ldap.SearchResult, error) {
+	return c.GroupMembersExtendedContext(context.Background(), groupDN, attributes...)
+}
+
+// GroupMembersExtendedContext is the same as GroupMembersExtended, but stops looking up members
+// and returns the context's error along with the members found so far when the context is done.
+func (c *Client) GroupMembersExtendedContext(ctx context.Context, groupDN string, attributes ...string) (*ldap.SearchResult, error) {
 	// call GroupMembers
 	if attributes == nil || len(attributes) == 0 {
 		attributes = []string{AttributeObjectClass}
 	}
 
-	resp, err := c.GroupMembers(groupDN, attributes...)
+	resp, err := c.GroupMembersContext(ctx, groupDN, attributes...)
 	if err != nil {
 		return resp, err
 	}
@@ -45,11 +57,11 @@ func (c *Client) GroupMembersExtended(groupDN string, attributes ...string) (*ld
 
 	// retreive the group's `member` attribute
 	filter := fmt.Sprintf(`(%s=%s)`, AttributeDistinguishedName, groupDN)
-	memberRange := "member;range=0-*"
+	memberRange := AttributeMember + ";range=0-*"
 	groupAttrs := []string{memberRange}
 	req := c.NewSearchRequest(filter, groupAttrs)
 
-	exResp, err := c.Search(req)
+	exResp, err := c.SearchContext(ctx, req)
 	if err != nil {
 		return resp, err
 	}
@@ -61,12 +73,20 @@ func (c *Client) GroupMembersExtended(groupDN string, attributes ...string) (*ld
 	// ignore indexed members, for new members search for desired attributes and append to results
 	members := exResp.Entries[0].GetAttributeValues(memberRange)
 	for _, dn := range members {
+		if err := ctx.Err(); err != nil {
+			return resp, err
+		}
+
 		if _, ok := index[dn]; !ok {
 			filter := fmt.Sprintf(`(%s=%s)`, AttributeDistinguishedName, dn)
 			req := c.NewSearchRequest(filter, attributes)
 
-			memResp, err := c.Search(req)
+			memResp, err := c.SearchContext(ctx, req)
 			if err != nil {
+				if ctx.Err() != nil {
+					return resp, ctx.Err()
+				}
+
 				log.Printf("could not get member attributes: %s: %v", dn, err)
 				resp.Entries = append(resp.Entries, &ldap.Entry{DN: dn, Attributes: []*ldap.EntryAttribute{}})
 				continue
@@ -86,6 +106,22 @@ func (c *Client) GroupMembersExtended(groupDN string, attributes ...string) (*ld
 // Optionally, a list of attributes for the members can also be returned. If
 // attributes is empty, only the distinguishedName attribute is requred.
 func (c *Client) OrganizationalUnitMembers(baseDN string, attributes ...string) (*ldap.SearchResult, error) {
+	return c.OrganizationalUnitMembersContext(context.Background(), baseDN, attributes...)
+}
+
+// OrganizationalUnitMembersContext is the same as OrganizationalUnitMembers, but abandons the search
+// when the context is done.
+func (c *Client) OrganizationalUnitMembersContext(ctx context.Context, baseDN string, attributes ...string) (*ldap.SearchResult, error) {
+	return c.SearchContext(ctx, c.organizationalUnitMembersRequest(baseDN, attributes))
+}
+
+// OrganizationalUnitMembersPages is the same as OrganizationalUnitMembers, but calls fn with each page of
+// members as it arrives instead of returning them all at once. See SearchPages for more information.
+func (c *Client) OrganizationalUnitMembersPages(ctx context.Context, baseDN string, attributes []string, fn func(page *ldap.SearchResult) error) error {
+	return c.SearchPagesContext(ctx, c.organizationalUnitMembersRequest(baseDN, attributes), fn)
+}
+
+func (c *Client) organizationalUnitMembersRequest(baseDN string, attributes []string) *ldap.SearchRequest {
 	filter := fmt.Sprintf(`(objectClass=*)`)
 	if attributes == nil {
 		attributes = []string{}
@@ -97,5 +133,5 @@ func (c *Client) OrganizationalUnitMembers(baseDN string, attributes ...string)
 		req.BaseDN = baseDN
 	}
 
-	return c.Search(req)
+	return req
 }
@@ -0,0 +1,197 @@
+package ldapcli
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// CipherPolicy determines which cipher suites are offered for TLS 1.0 to 1.2. TLS 1.3 cipher suites are not
+// configurable and are always secure.
+type CipherPolicy string
+
+const (
+	// CipherPolicyDefault offers the cipher suites Go considers secure.
+	CipherPolicyDefault CipherPolicy = "default"
+
+	// CipherPolicyStrict offers only cipher suites with forward secrecy and authenticated encryption, and requires
+	// at least TLS 1.2.
+	CipherPolicyStrict CipherPolicy = "strict"
+
+	// CipherPolicyLegacy also offers insecure cipher suites, such as those with RSA key exchange or 3DES, for
+	// servers that support nothing else. It should be combined with a MinTLSVersion of TLS 1.0 for old servers.
+	CipherPolicyLegacy CipherPolicy = "legacy"
+)
+
+// ParseCipherPolicy returns the CipherPolicy with the given name. An empty string returns CipherPolicyDefault.
+func ParseCipherPolicy(name string) (CipherPolicy, error) {
+	switch p := CipherPolicy(strings.ToLower(name)); p {
+	case "":
+		return CipherPolicyDefault, nil
+	case CipherPolicyDefault, CipherPolicyStrict, CipherPolicyLegacy:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown cipher policy, must be one of: default, strict, legacy")
+	}
+}
+
+// strictCipherSuites have forward secrecy and authenticated encryption.
+var strictCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// cipherSuites returns the cipher suites offered by the policy, or nil for Go's defaults.
+func (p CipherPolicy) cipherSuites() []uint16 {
+	switch p {
+	case CipherPolicyStrict:
+		return strictCipherSuites
+	case CipherPolicyLegacy:
+		suites := []uint16{}
+		for _, s := range tls.CipherSuites() {
+			suites = append(suites, s.ID)
+		}
+		for _, s := range tls.InsecureCipherSuites() {
+			suites = append(suites, s.ID)
+		}
+		return suites
+	default:
+		return nil
+	}
+}
+
+// ParseTLSVersion returns the TLS version with the given name, one of 1.0, 1.1, 1.2, or 1.3, optionally prefixed
+// with TLS, e.g. TLS1.2. An empty string returns 0, which means Go's default minimum version.
+func ParseTLSVersion(name string) (uint16, error) {
+	version := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(name), "tls"), "v")
+
+	switch version {
+	case "":
+		return 0, nil
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS version, must be one of: 1.0, 1.1, 1.2, 1.3")
+	}
+}
+
+// tlsConfig returns the TLS configuration for connecting to the servers. The CA and client certificates are read
+// each time, so certificates renewed on disk are used when the client reconnects.
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: c.SkipVerify,
+		ServerName:         c.TLSServerName,
+		MinVersion:         c.MinTLSVersion,
+		CipherSuites:       c.CipherPolicy.cipherSuites(),
+	}
+
+	if c.CipherPolicy == CipherPolicyStrict && tlsConf.MinVersion < tls.VersionTLS12 {
+		tlsConf.MinVersion = tls.VersionTLS12
+	}
+
+	if len(c.CAFile) > 0 || len(c.CADir) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if len(c.CAFile) > 0 {
+			pem, err := ioutil.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading CA file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("reading CA file: no PEM certificates found in: %s", c.CAFile)
+			}
+		}
+
+		if len(c.CADir) > 0 {
+			if err := appendCertsFromDir(pool, c.CADir); err != nil {
+				return nil, err
+			}
+		}
+
+		tlsConf.RootCAs = pool
+	}
+
+	if len(c.ClientCertFile) > 0 {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConf, nil
+}
+
+// appendCertsFromDir adds the PEM certificates in each file of the directory to the pool. Files that don't hold
+// certificates are skipped, so directories such as /etc/ssl/certs can be used.
+func appendCertsFromDir(pool *x509.CertPool, dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading CA directory: %w", err)
+	}
+
+	found := false
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		pem, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		if pool.AppendCertsFromPEM(pem) {
+			found = true
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("reading CA directory: no PEM certificates found in: %s", dir)
+	}
+
+	return nil
+}
+
+// tlsConfigForAddress returns the TLS configuration for the server at the given address. Unless a server name is
+// configured, the certificate is verified against the host of the address, which StartTLS can't determine itself.
+func tlsConfigForAddress(tlsConf *tls.Config, address string) *tls.Config {
+	if len(tlsConf.ServerName) > 0 {
+		return tlsConf
+	}
+
+	u, err := url.Parse(address)
+	if err != nil || len(u.Hostname()) == 0 {
+		return tlsConf
+	}
+
+	addrConf := tlsConf.Clone()
+	addrConf.ServerName = u.Hostname()
+	return addrConf
+}
+
+// externalBind binds the given connection using the SASL EXTERNAL mechanism.
+func externalBind(conn *ldap.Conn) error {
+	if err := conn.ExternalBind(); err != nil {
+		return fmt.Errorf("EXTERNAL bind to LDAP: %w", err)
+	}
+	return nil
+}
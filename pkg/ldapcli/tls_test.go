@@ -0,0 +1,309 @@
+package ldapcli
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+const testServerName = "ldap.example.test"
+
+// testPKI holds a CA with a server and client certificate, written as PEM files to dir.
+type testPKI struct {
+	dir        string
+	caFile     string
+	serverCert tls.Certificate
+	clientCert string
+	clientKey  string
+	pool       *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Direktor Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	issue := func(serial int64, name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			DNSNames:     []string{name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+		require.NoError(t, err)
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		require.NoError(t, err)
+
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+
+	p := &testPKI{
+		dir:        dir,
+		caFile:     filepath.Join(dir, "ca.pem"),
+		clientCert: filepath.Join(dir, "client.pem"),
+		clientKey:  filepath.Join(dir, "client-key.pem"),
+		pool:       x509.NewCertPool(),
+	}
+	p.pool.AddCert(caCert)
+
+	require.NoError(t, ioutil.WriteFile(p.caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), 0600))
+
+	certPEM, keyPEM := issue(2, testServerName, x509.ExtKeyUsageServerAuth)
+	p.serverCert, err = tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+
+	certPEM, keyPEM = issue(3, "svc-direktor", x509.ExtKeyUsageClientAuth)
+	require.NoError(t, ioutil.WriteFile(p.clientCert, certPEM, 0600))
+	require.NoError(t, ioutil.WriteFile(p.clientKey, keyPEM, 0600))
+
+	return p
+}
+
+// startTLSServer starts an LDAPS server that only answers EXTERNAL binds, which succeed if the client presented a
+// certificate issued by the test CA. The mechanism and client certificate name of each bind request are sent to
+// the returned channel.
+func startTLSServer(t *testing.T, pki *testPKI) (string, <-chan string) {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{pki.serverCert},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    pki.pool,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	binds := make(chan string, 10)
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				tlsConn := conn.(*tls.Conn)
+				if err := tlsConn.Handshake(); err != nil {
+					return
+				}
+
+				name := ""
+				if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+					name = certs[0].Subject.CommonName
+				}
+
+				for {
+					packet, err := ber.ReadPacket(conn)
+					if err != nil || len(packet.Children) < 2 || packet.Children[1].Tag != ldap.ApplicationBindRequest {
+						return
+					}
+
+					messageID := packet.Children[0].Value.(int64)
+					auth := packet.Children[1].Children[2]
+					mechanism := ""
+					if len(auth.Children) > 0 {
+						mechanism = auth.Children[0].Data.String()
+					}
+					binds <- mechanism + ":" + name
+
+					switch {
+					case mechanism != "EXTERNAL":
+						writeBindResponse(conn, messageID, ldap.LDAPResultAuthMethodNotSupported, "")
+					case len(name) == 0:
+						writeBindResponse(conn, messageID, ldap.LDAPResultInappropriateAuthentication, "")
+					default:
+						writeBindResponse(conn, messageID, ldap.LDAPResultSuccess, "")
+					}
+				}
+			}()
+		}
+	}()
+
+	return "ldaps://" + ln.Addr().String(), binds
+}
+
+func TestExternalBind(t *testing.T) {
+	pki := newTestPKI(t)
+	address, binds := startTLSServer(t, pki)
+
+	conf := NewConfig(address, "dc=example,dc=com")
+	conf.AuthMode = AuthExternal
+	conf.CAFile = pki.caFile
+	conf.ClientCertFile = pki.clientCert
+	conf.ClientKeyFile = pki.clientKey
+	conf.TLSServerName = testServerName
+
+	cli, err := Dial(conf)
+	require.NoError(t, err)
+	cli.Close()
+	require.Equal(t, "EXTERNAL:svc-direktor", <-binds)
+
+	// the certificate is verified against the host of the address without a server name override
+	noServerName := *conf
+	noServerName.TLSServerName = ""
+	_, err = Dial(&noServerName)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "certificate")
+
+	// the server's certificate is not trusted without the CA
+	noCA := *conf
+	noCA.CAFile = ""
+	_, err = Dial(&noCA)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "certificate")
+
+	// the CA can also be read from a directory
+	caDir := filepath.Join(pki.dir, "certs")
+	require.NoError(t, os.Mkdir(caDir, 0700))
+	require.NoError(t, os.Rename(pki.caFile, filepath.Join(caDir, "ca.pem")))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(caDir, "README"), []byte("not a certificate"), 0600))
+
+	withDir := *conf
+	withDir.CAFile = ""
+	withDir.CADir = caDir
+	cli, err = Dial(&withDir)
+	require.NoError(t, err)
+	cli.Close()
+	require.Equal(t, "EXTERNAL:svc-direktor", <-binds)
+}
+
+func TestTLSConfig(t *testing.T) {
+	pki := newTestPKI(t)
+
+	conf := &Config{
+		CAFile:         pki.caFile,
+		ClientCertFile: pki.clientCert,
+		ClientKeyFile:  pki.clientKey,
+		MinTLSVersion:  tls.VersionTLS11,
+		TLSServerName:  testServerName,
+	}
+
+	tlsConf, err := conf.tlsConfig()
+	require.NoError(t, err)
+	require.NotNil(t, tlsConf.RootCAs)
+	require.Len(t, tlsConf.Certificates, 1)
+	require.Equal(t, uint16(tls.VersionTLS11), tlsConf.MinVersion)
+	require.Equal(t, testServerName, tlsConf.ServerName)
+	require.Nil(t, tlsConf.CipherSuites)
+
+	// the strict policy requires TLS 1.2
+	conf.CipherPolicy = CipherPolicyStrict
+	tlsConf, err = conf.tlsConfig()
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS12), tlsConf.MinVersion)
+	require.Equal(t, strictCipherSuites, tlsConf.CipherSuites)
+
+	conf.CipherPolicy = CipherPolicyLegacy
+	tlsConf, err = conf.tlsConfig()
+	require.NoError(t, err)
+	require.Contains(t, tlsConf.CipherSuites, tls.TLS_RSA_WITH_AES_128_CBC_SHA)
+
+	_, err = (&Config{CAFile: filepath.Join(pki.dir, "missing.pem")}).tlsConfig()
+	require.Error(t, err)
+
+	_, err = (&Config{CAFile: pki.clientKey}).tlsConfig()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no PEM certificates found")
+
+	_, err = (&Config{CADir: t.TempDir()}).tlsConfig()
+	require.Error(t, err)
+
+	_, err = (&Config{ClientCertFile: pki.clientCert, ClientKeyFile: pki.caFile}).tlsConfig()
+	require.Error(t, err)
+}
+
+func TestTLSConfigForAddress(t *testing.T) {
+	tlsConf := &tls.Config{}
+
+	addrConf := tlsConfigForAddress(tlsConf, "ldap://DC1.example.com:389")
+	require.Equal(t, "DC1.example.com", addrConf.ServerName)
+	require.Empty(t, tlsConf.ServerName)
+
+	tlsConf.ServerName = testServerName
+	addrConf = tlsConfigForAddress(tlsConf, "ldaps://10.0.0.1:636")
+	require.Equal(t, testServerName, addrConf.ServerName)
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	version, err := ParseTLSVersion("")
+	require.NoError(t, err)
+	require.Equal(t, uint16(0), version)
+
+	version, err = ParseTLSVersion("1.2")
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS12), version)
+
+	version, err = ParseTLSVersion("TLSv1.3")
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS13), version)
+
+	_, err = ParseTLSVersion("ssl3")
+	require.Error(t, err)
+}
+
+func TestParseCipherPolicy(t *testing.T) {
+	policy, err := ParseCipherPolicy("")
+	require.NoError(t, err)
+	require.Equal(t, CipherPolicyDefault, policy)
+
+	policy, err = ParseCipherPolicy("Strict")
+	require.NoError(t, err)
+	require.Equal(t, CipherPolicyStrict, policy)
+
+	_, err = ParseCipherPolicy("fips")
+	require.Error(t, err)
+}
+
+func TestConfigValidateTLS(t *testing.T) {
+	conf := NewConfig("ldaps://localhost", "dc=example,dc=com")
+	conf.ClientCertFile = "client.pem"
+	require.Error(t, conf.Validate())
+
+	conf.ClientCertFile = ""
+	conf.ClientKeyFile = "client-key.pem"
+	require.Error(t, conf.Validate())
+
+	// a client certificate identifies the client when binding with EXTERNAL over TLS
+	conf.ClientKeyFile = ""
+	conf.AuthMode = AuthExternal
+	require.Error(t, conf.Validate())
+
+	conf.Address = "ldapi:///var/run/slapd/ldapi"
+	require.NoError(t, conf.Validate())
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/deejross/direktor/pkg/ldapcli"
@@ -21,6 +22,12 @@ const (
 	TestBindPW = "password"
 	// TestBaseDN is the configure base DN for the directory.
 	TestBaseDN = "dc=example,dc=com"
+	// TestGroupDN is the DN of a group in the directory.
+	TestGroupDN = "cn=physicists,ou=groups,dc=example,dc=com"
+	// TestObjectSID is the binary objectSid of tesla, S-1-5-21-3623864263-3361045372-30299796-1013.
+	TestObjectSID = "\x01\x05\x00\x00\x00\x00\x00\x05\x15\x00\x00\x00\xc7\xc7\xff\xd7\x7c\x7b\x55\xc8\x94\x56\xce\x01\xf5\x03\x00\x00"
+	// TestObjectGUID is the binary objectGUID of tesla, a1b2c3d4-e5f6-a7b8-c9d0-e1f2a3b4c5d6.
+	TestObjectGUID = "\xd4\xc3\xb2\xa1\xf6\xe5\xb8\xa7\xc9\xd0\xe1\xf2\xa3\xb4\xc5\xd6"
 )
 
 var directory = []map[string]string{
@@ -41,6 +48,7 @@ var directory = []map[string]string{
 		ldapcli.AttributeMail:              "einstein@example.com",
 		ldapcli.AttributeUserPrincipalName: "einstein@example.com",
 		ldapcli.AttributeObjectClass:       ldapcli.ObjectClassPerson,
+		ldapcli.AttributeMemberOf:          TestGroupDN,
 	},
 	{
 		ldapcli.AttributeDistinguishedName: "cn=newton,ou=scientists,dc=example,dc=com",
@@ -52,16 +60,60 @@ var directory = []map[string]string{
 		ldapcli.AttributeObjectClass:       ldapcli.ObjectClassPerson,
 	},
 	{
-		ldapcli.AttributeDistinguishedName: "cn=tesla,ou=scientists,dc=example,dc=com",
-		ldapcli.AttributeCommonName:        "tesla",
-		ldapcli.AttributeDisplayName:       "Nikola Tesla",
-		ldapcli.AttributeDepartment:        "Scientists",
-		ldapcli.AttributeMail:              "tesla@example.com",
-		ldapcli.AttributeUserPrincipalName: "tesla@example.com",
-		ldapcli.AttributeObjectClass:       ldapcli.ObjectClassPerson,
+		ldapcli.AttributeDistinguishedName:  "cn=tesla,ou=scientists,dc=example,dc=com",
+		ldapcli.AttributeCommonName:         "tesla",
+		ldapcli.AttributeDisplayName:        "Nikola Tesla",
+		ldapcli.AttributeDepartment:         "Scientists",
+		ldapcli.AttributeMail:               "tesla@example.com",
+		ldapcli.AttributeUserPrincipalName:  "tesla@example.com",
+		ldapcli.AttributeObjectClass:        ldapcli.ObjectClassPerson,
+		ldapcli.AttributeMemberOf:           TestGroupDN,
+		ldapcli.AttributeObjectSID:          TestObjectSID,
+		ldapcli.AttributeObjectGUID:         TestObjectGUID,
+		ldapcli.AttributeUserAccountControl: "66048",
+		ldapcli.AttributePasswordLastSet:    "132223104000000000",
+		ldapcli.AttributeAccountExpires:     "9223372036854775807",
+		ldapcli.AttributeWhenCreated:        "20200102150405.0Z",
+		"userPassword":                      "{SSHA}tesla",
+	},
+	{
+		ldapcli.AttributeDistinguishedName: TestGroupDN,
+		ldapcli.AttributeCommonName:        "physicists",
+		ldapcli.AttributeDisplayName:       "Physicists",
+		ldapcli.AttributeObjectClass:       ldapcli.ObjectClassGroup,
+		ldapcli.AttributeMember:            "cn=newton,ou=scientists,dc=example,dc=com",
 	},
 }
 
+// rootDSE is returned for base searches of the empty DN, like a domain controller in a Windows Server 2016 domain.
+var rootDSE = map[string][]string{
+	ldapcli.AttributeNamingContexts: {
+		TestBaseDN,
+		"cn=Configuration,dc=example,dc=com",
+		"cn=Schema,cn=Configuration,dc=example,dc=com",
+	},
+	ldapcli.AttributeDefaultNamingContext:    {TestBaseDN},
+	ldapcli.AttributeRootDomainNamingContext: {TestBaseDN},
+	ldapcli.AttributeSupportedControl: {
+		ldapcli.ControlPagedResults,
+		"1.2.840.113556.1.4.473",
+		"1.2.840.113556.1.4.1339",
+	},
+	ldapcli.AttributeSupportedSASLMechanisms:       {"GSSAPI", "GSS-SPNEGO", "EXTERNAL", "DIGEST-MD5"},
+	ldapcli.AttributeSupportedLDAPVersion:          {"3", "2"},
+	ldapcli.AttributeDNSHostName:                   {"dc1.example.com"},
+	ldapcli.AttributeForestFunctionality:           {"7"},
+	ldapcli.AttributeDomainFunctionality:           {"7"},
+	ldapcli.AttributeDomainControllerFunctionality: {"7"},
+}
+
+var searchDelay int64
+
+// SetSearchDelay sets how long searches wait before returning results. This is used to test cancellation.
+func SetSearchDelay(d time.Duration) {
+	atomic.StoreInt64(&searchDelay, int64(d))
+}
+
 // Start the mock LDAP server.
 func Start(addr string) (chan struct{}, error) {
 	// start the LDAP server
@@ -218,6 +270,20 @@ func handleDelete(w ldapserver.ResponseWriter, m *ldapserver.Message) {
 func handleSearch(w ldapserver.ResponseWriter, m *ldapserver.Message) {
 	req := m.GetSearchRequest()
 
+	if delay := time.Duration(atomic.LoadInt64(&searchDelay)); delay > 0 {
+		select {
+		case <-m.Done:
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	if len(req.BaseObject()) == 0 && req.Scope() == ldapserver.SearchRequestScopeBaseObject {
+		w.Write(rootDSEEntry(req.Attributes()))
+		w.Write(ldapserver.NewSearchResultDoneResponse(ldapserver.LDAPResultSuccess))
+		return
+	}
+
 	for _, m := range directory {
 		if !strings.HasSuffix(m[ldapcli.AttributeDistinguishedName], string(req.BaseObject())) {
 			continue
@@ -229,7 +295,9 @@ func handleSearch(w ldapserver.ResponseWriter, m *ldapserver.Message) {
 
 		e := ldapserver.NewSearchResultEntry(m[ldapcli.AttributeDistinguishedName])
 		for _, attr := range req.Attributes() {
-			e.AddAttribute(message.AttributeDescription(attr), message.AttributeValue(m[string(attr)]))
+			// ignore attribute options such as `;range=0-*` when looking up values
+			name := strings.SplitN(string(attr), ";", 2)[0]
+			e.AddAttribute(message.AttributeDescription(attr), message.AttributeValue(m[name]))
 		}
 
 		w.Write(e)
@@ -238,6 +306,42 @@ func handleSearch(w ldapserver.ResponseWriter, m *ldapserver.Message) {
 	w.Write(ldapserver.NewSearchResultDoneResponse(ldapserver.LDAPResultSuccess))
 }
 
+// rootDSEEntry returns the RootDSE with the requested attributes, or all of them if `*` or `+` is requested.
+func rootDSEEntry(attributes message.AttributeSelection) message.SearchResultEntry {
+	e := ldapserver.NewSearchResultEntry("")
+
+	all := false
+	for _, attr := range attributes {
+		if attr == "*" || attr == "+" {
+			all = true
+		}
+	}
+
+	// return the attributes in a consistent order
+	for _, name := range ldapcli.RootDSEAttributes {
+		values, ok := rootDSE[name]
+		if !ok {
+			continue
+		}
+
+		requested := all
+		for _, attr := range attributes {
+			requested = requested || strings.EqualFold(string(attr), name)
+		}
+		if !requested {
+			continue
+		}
+
+		attrValues := make([]message.AttributeValue, len(values))
+		for i, v := range values {
+			attrValues[i] = message.AttributeValue(v)
+		}
+		e.AddAttribute(message.AttributeDescription(name), attrValues...)
+	}
+
+	return e
+}
+
 func entryMatchesFilter(m map[string]string, filter message.Filter) bool {
 	switch f := filter.(type) {
 	case message.FilterAnd:
@@ -1,9 +1,14 @@
 package ldapmockserver
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net"
 	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/deejross/direktor/pkg/ldapcli"
 	"github.com/go-ldap/ldap/v3"
@@ -187,3 +192,339 @@ func TestDelete(t *testing.T) {
 	require.Empty(t, resp.Referrals)
 	require.Empty(t, resp.Entries)
 }
+
+func TestGroupMembersExtended(t *testing.T) {
+	require.NotNil(t, cli)
+
+	resp, err := cli.GroupMembers(TestGroupDN, ldapcli.AttributeCommonName)
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 2)
+
+	resp, err = cli.GroupMembersExtended(TestGroupDN, ldapcli.AttributeCommonName)
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 3)
+	require.Equal(t, "newton", resp.Entries[2].GetAttributeValue(ldapcli.AttributeCommonName))
+}
+
+func TestSearchContext(t *testing.T) {
+	require.NotNil(t, cli)
+
+	req := cli.NewSearchRequest(`(cn=*)`, []string{ldapcli.AttributeCommonName})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cli.SearchContext(ctx, req)
+	require.Equal(t, context.Canceled, err)
+
+	// a search still in progress is abandoned when the deadline passes
+	SetSearchDelay(5 * time.Second)
+	defer SetSearchDelay(0)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = cli.SearchContext(ctx, req)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	// the connection is shared with other goroutines, so it's left open
+	require.False(t, cli.IsClosing())
+
+	SetSearchDelay(0)
+	resp, err := cli.Search(req)
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, Size())
+}
+
+func TestSearchContextConcurrent(t *testing.T) {
+	require.NotNil(t, cli)
+
+	req := cli.NewSearchRequest(`(cn=*)`, []string{ldapcli.AttributeCommonName})
+
+	SetSearchDelay(500 * time.Millisecond)
+	defer SetSearchDelay(0)
+
+	// cancelling one search doesn't affect another search in progress on the same connection
+	errCh := make(chan error, 1)
+	go func() {
+		resp, err := cli.Search(req)
+		if err == nil && len(resp.Entries) != Size() {
+			err = fmt.Errorf("expected %d entries, got %d", Size(), len(resp.Entries))
+		}
+		errCh <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := cli.SearchContext(ctx, req)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.NoError(t, <-errCh)
+}
+
+func TestGroupMembersExtendedContext(t *testing.T) {
+	require.NotNil(t, cli)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := cli.GroupMembersExtendedContext(ctx, TestGroupDN, ldapcli.AttributeCommonName)
+	require.Equal(t, context.Canceled, err)
+
+	resp, err := cli.GroupMembersExtendedContext(context.Background(), TestGroupDN, ldapcli.AttributeCommonName)
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 3)
+}
+
+func TestDialContext(t *testing.T) {
+	conf := ldapcli.NewConfig(testAddress, TestBaseDN)
+	conf.BindUsername = TestBindDN
+	conf.BindPassword = TestBindPW
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := ldapcli.DialContext(ctx, conf)
+	require.Equal(t, context.Canceled, err)
+
+	c, err := ldapcli.DialContext(context.Background(), conf)
+	require.NoError(t, err)
+	c.Close()
+}
+
+func TestConcurrentClient(t *testing.T) {
+	conf := ldapcli.NewConfig(testAddress, TestBaseDN)
+	conf.BindUsername = TestBindDN
+	conf.BindPassword = TestBindPW
+
+	c, err := ldapcli.Dial(conf)
+	require.NoError(t, err)
+	defer c.Close()
+
+	const workers = 8
+	const iterations = 20
+
+	wg := sync.WaitGroup{}
+	errCh := make(chan error, workers*iterations*4)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(4)
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				resp, err := c.Search(c.NewSearchRequest(`(cn=*)`, []string{ldapcli.AttributeCommonName}))
+				if err != nil {
+					errCh <- err
+				} else if len(resp.Entries) != Size() {
+					errCh <- fmt.Errorf("expected %d entries, got %d", Size(), len(resp.Entries))
+				}
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				if _, err := c.GroupMembersExtended(TestGroupDN, ldapcli.AttributeCommonName); err != nil {
+					errCh <- err
+				}
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				if err := c.Bind(TestBindDN, TestBindPW); err != nil {
+					errCh <- err
+				}
+				_ = c.Config().BindUsername
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < iterations; j++ {
+				if err := c.Reconnect(); err != nil {
+					errCh <- err
+				}
+				_ = c.IsClosing()
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		require.NoError(t, err)
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	conf := ldapcli.NewConfig(testAddress, TestBaseDN)
+	conf.BindUsername = TestBindDN
+	conf.BindPassword = TestBindPW
+
+	c, err := ldapcli.Dial(conf)
+	require.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				// searches may fail once the client is closed, but must not race or reconnect
+				c.Search(c.NewSearchRequest(`(cn=*)`, []string{ldapcli.AttributeCommonName}))
+			}
+		}()
+	}
+
+	c.Close()
+	wg.Wait()
+
+	require.True(t, c.IsClosing())
+	require.Error(t, c.Reconnect())
+}
+
+func TestSearchPages(t *testing.T) {
+	require.NotNil(t, cli)
+
+	req := cli.NewSearchRequest(`(cn=*)`, []string{ldapcli.AttributeCommonName})
+
+	entries := []*ldap.Entry{}
+	err := cli.SearchPages(req, func(page *ldap.SearchResult) error {
+		entries = append(entries, page.Entries...)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, Size())
+
+	// the caller's request is not modified by the paging control
+	require.Empty(t, req.Controls)
+
+	// errors from the callback stop the search and are returned as-is
+	stop := fmt.Errorf("stop")
+	err = cli.SearchPages(req, func(page *ldap.SearchResult) error {
+		return stop
+	})
+	require.Equal(t, stop, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = cli.SearchPagesContext(ctx, req, func(page *ldap.SearchResult) error {
+		return nil
+	})
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestOrganizationalUnitMembersPages(t *testing.T) {
+	require.NotNil(t, cli)
+
+	expected, err := cli.OrganizationalUnitMembers(TestBaseDN, ldapcli.AttributeCommonName)
+	require.NoError(t, err)
+
+	entries := []*ldap.Entry{}
+	err = cli.OrganizationalUnitMembersPages(context.Background(), TestBaseDN, []string{ldapcli.AttributeCommonName}, func(page *ldap.SearchResult) error {
+		entries = append(entries, page.Entries...)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, expected.Entries, entries)
+}
+
+func TestFailover(t *testing.T) {
+	// nothing listens on these ports, so connections are refused
+	conf := ldapcli.NewConfig("ldap://127.0.0.1:1", TestBaseDN)
+	conf.Addresses = []string{"ldap://127.0.0.1:2", testAddress}
+	conf.BindUsername = TestBindDN
+	conf.BindPassword = TestBindPW
+
+	failover, err := ldapcli.Dial(conf)
+	require.NoError(t, err)
+	defer failover.Close()
+	require.Equal(t, testAddress, failover.Address())
+
+	resp, err := failover.Search(failover.NewSearchRequest(`(cn=tesla)`, []string{ldapcli.AttributeCommonName}))
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 1)
+
+	// errors that would be the same on every server are not retried
+	conf = ldapcli.NewConfig("ldap://127.0.0.1:1", TestBaseDN)
+	conf.Addresses = []string{testAddress}
+	conf.BindUsername = TestBindDN
+	conf.BindPassword = "wrong"
+
+	_, err = ldapcli.Dial(conf)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "binding to LDAP")
+
+	// all addresses unreachable
+	conf = ldapcli.NewConfig("ldap://127.0.0.1:3", TestBaseDN)
+	conf.Addresses = []string{"ldap://127.0.0.1:4"}
+
+	_, err = ldapcli.Dial(conf)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no LDAP server could be reached")
+}
+
+// srvResolver returns the same SRV records for every lookup.
+type srvResolver []*net.SRV
+
+func (r srvResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return "", r, nil
+}
+
+func TestDiscovery(t *testing.T) {
+	conf := &ldapcli.Config{
+		Domain:       "example.com",
+		BindUsername: TestBindDN,
+		BindPassword: TestBindPW,
+		Resolver: srvResolver{
+			{Target: "127.0.0.1.", Port: 1, Priority: 0},
+			{Target: "127.0.0.1.", Port: 10389, Priority: 10},
+		},
+	}
+
+	discovered, err := ldapcli.Dial(conf)
+	require.NoError(t, err)
+	defer discovered.Close()
+	require.Equal(t, testAddress, discovered.Address())
+	require.Equal(t, TestBaseDN, discovered.Config().BaseDN)
+
+	resp, err := discovered.Search(discovered.NewSearchRequest(`(cn=tesla)`, []string{ldapcli.AttributeCommonName}))
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 1)
+}
+
+func TestRootDSE(t *testing.T) {
+	root, err := cli.RootDSE()
+	require.NoError(t, err)
+	require.Equal(t, TestBaseDN, root.DefaultNamingContext)
+	require.Equal(t, TestBaseDN, root.BaseDN())
+	require.Len(t, root.NamingContexts, 3)
+	require.Equal(t, []int{3, 2}, root.SupportedLDAPVersions)
+	require.True(t, root.SupportsControl(ldapcli.ControlPagedResults))
+	require.True(t, root.SupportsSASLMechanism("gssapi"))
+	require.Equal(t, "dc1.example.com", root.DNSHostName)
+	require.Equal(t, "Windows Server 2016", root.ForestFunctionality.String())
+	require.Equal(t, ldapcli.FunctionalLevel(7), root.DomainFunctionality)
+	require.Empty(t, root.VendorName)
+
+	// the base DN is read from the RootDSE if it isn't given
+	conf := ldapcli.NewConfig(testAddress, "")
+	conf.BindUsername = TestBindDN
+	conf.BindPassword = TestBindPW
+
+	auto, err := ldapcli.Dial(conf)
+	require.NoError(t, err)
+	defer auto.Close()
+	require.Equal(t, TestBaseDN, auto.Config().BaseDN)
+
+	resp, err := auto.Search(auto.NewSearchRequest(`(cn=tesla)`, []string{ldapcli.AttributeCommonName}))
+	require.NoError(t, err)
+	require.Len(t, resp.Entries, 1)
+}